@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -28,6 +29,7 @@ func NewSimpleFinClient(accessToken AccessToken) (*SimpleFinClient, error) {
 	rt := &SimpleFinRoundTripper{
 		username: accessToken.Username,
 		password: accessToken.Password,
+		Base:     NewRetryRoundTripper(nil),
 	}
 	return &SimpleFinClient{
 		client: &http.Client{
@@ -37,7 +39,15 @@ func NewSimpleFinClient(accessToken AccessToken) (*SimpleFinClient, error) {
 	}, nil
 }
 
+// GetAccounts fetches accounts without a deadline; prefer GetAccountsContext
 func (c *SimpleFinClient) GetAccounts(opts *GetAccountsOptions) (*model.GetAccountsResponse, error) {
+	return c.GetAccountsContext(context.Background(), opts)
+}
+
+// GetAccountsContext fetches accounts from the bridge. Non-2xx responses are
+// returned as *HTTPError, which matches ErrAuthRevoked for 403 and
+// ErrPaymentRequired for 402 under errors.Is.
+func (c *SimpleFinClient) GetAccountsContext(ctx context.Context, opts *GetAccountsOptions) (*model.GetAccountsResponse, error) {
 	params := url.Values{}
 	
 	if opts != nil {
@@ -68,7 +78,11 @@ func (c *SimpleFinClient) GetAccounts(opts *GetAccountsOptions) (*model.GetAccou
 		accountsURL = fmt.Sprintf("%s?%s", accountsURL, queryString)
 	}
 	
-	resp, err := c.client.Get(accountsURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, accountsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -77,9 +91,12 @@ func (c *SimpleFinClient) GetAccounts(opts *GetAccountsOptions) (*model.GetAccou
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, newHTTPError(resp, b)
+	}
 	var response model.GetAccountsResponse
 	if err := json.Unmarshal(b, &response); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to decode accounts response: %w", err)
 	}
 	return &response, nil
 }
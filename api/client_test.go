@@ -1,11 +1,14 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/criswit/chi-chi-moni/model"
 )
@@ -47,6 +50,10 @@ func TestNewSimpleFinClient(t *testing.T) {
 	if transport.password != accessToken.Password {
 		t.Errorf("Expected password %s, got %s", accessToken.Password, transport.password)
 	}
+
+	if _, ok := transport.Base.(*RetryRoundTripper); !ok {
+		t.Errorf("Expected base transport to be RetryRoundTripper, got %T", transport.Base)
+	}
 }
 
 func TestSimpleFinClient_GetAccounts_Success(t *testing.T) {
@@ -78,7 +85,7 @@ func TestSimpleFinClient_GetAccounts_Success(t *testing.T) {
 						TransactedAt: 1640995200,
 					},
 				},
-				Holdings: []interface{}{},
+				Holdings: []model.Holding{},
 			},
 		},
 		XAPIMessage: []string{"Success"},
@@ -498,7 +505,7 @@ func TestSimpleFinClient_GetAccounts_WithComplexResponse(t *testing.T) {
 					URL:    "https://testbank.com",
 				},
 				Transactions: []model.Transaction{},
-				Holdings:     []interface{}{},
+				Holdings:     []model.Holding{},
 			},
 			{
 				ID:               "acc2",
@@ -514,7 +521,7 @@ func TestSimpleFinClient_GetAccounts_WithComplexResponse(t *testing.T) {
 					URL:    "https://testbank.com",
 				},
 				Transactions: []model.Transaction{},
-				Holdings:     []interface{}{},
+				Holdings:     []model.Holding{},
 			},
 		},
 		XAPIMessage: []string{"Success", "Data retrieved"},
@@ -576,4 +583,128 @@ func TestSimpleFinClient_GetAccounts_WithComplexResponse(t *testing.T) {
 	if savingsAccount.Name != "Savings Account" {
 		t.Errorf("Expected second account to be 'Savings Account', got %s", savingsAccount.Name)
 	}
-}
\ No newline at end of file
+}
+// newTestClient starts a TLS server with the given handler and returns a
+// client pointed at it
+func newTestClient(t *testing.T, handler http.HandlerFunc) *SimpleFinClient {
+	t.Helper()
+	mockServer := httptest.NewTLSServer(handler)
+	t.Cleanup(mockServer.Close)
+
+	client, err := NewSimpleFinClient(AccessToken{
+		Username: "testuser",
+		Password: "testpass",
+		Url:      strings.TrimPrefix(mockServer.URL, "https://"),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error creating client, got %v", err)
+	}
+	client.client.Transport = &SimpleFinRoundTripper{
+		username: "testuser",
+		password: "testpass",
+		Base:     mockServer.Client().Transport,
+	}
+	return client
+}
+
+func TestSimpleFinClient_GetAccountsContext_StatusErrors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		statusCode int
+		body       string
+		wantIs     error
+		temporary  bool
+	}{
+		{
+			name:       "forbidden is auth revoked",
+			statusCode: http.StatusForbidden,
+			body:       "<html><body>Forbidden</body></html>",
+			wantIs:     ErrAuthRevoked,
+		},
+		{
+			name:       "payment required",
+			statusCode: http.StatusPaymentRequired,
+			body:       "Payment required",
+			wantIs:     ErrPaymentRequired,
+		},
+		{
+			name:       "server error",
+			statusCode: http.StatusInternalServerError,
+			body:       "<html>\n  <h1>Internal Server Error</h1>\n</html>",
+			temporary:  true,
+		},
+		{
+			name:       "too many requests",
+			statusCode: http.StatusTooManyRequests,
+			temporary:  true,
+		},
+		{
+			name:       "not found",
+			statusCode: http.StatusNotFound,
+			body:       "Not Found",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				w.WriteHeader(tc.statusCode)
+				w.Write([]byte(tc.body))
+			})
+
+			_, err := client.GetAccountsContext(context.Background(), nil)
+			if err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+
+			var httpErr *HTTPError
+			if !errors.As(err, &httpErr) {
+				t.Fatalf("Expected *HTTPError, got %T: %v", err, err)
+			}
+			if httpErr.StatusCode != tc.statusCode {
+				t.Errorf("Expected status %d, got %d", tc.statusCode, httpErr.StatusCode)
+			}
+			if strings.Contains(httpErr.Body, "\n") {
+				t.Errorf("Expected body snippet without newlines, got %q", httpErr.Body)
+			}
+			if httpErr.Temporary() != tc.temporary {
+				t.Errorf("Expected Temporary() %v, got %v", tc.temporary, httpErr.Temporary())
+			}
+			if tc.wantIs != nil && !errors.Is(err, tc.wantIs) {
+				t.Errorf("Expected errors.Is(err, %v) to be true", tc.wantIs)
+			}
+			if tc.wantIs == nil && (errors.Is(err, ErrAuthRevoked) || errors.Is(err, ErrPaymentRequired)) {
+				t.Errorf("Expected status %d not to match a sentinel error", tc.statusCode)
+			}
+		})
+	}
+}
+
+func TestSimpleFinClient_GetAccountsContext_Cancelled(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errors": [], "accounts": []}`))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.GetAccountsContext(ctx, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestHTTPError_BodySnippet(t *testing.T) {
+	long := strings.Repeat("é", maxErrorBodyBytes)
+	snippet := bodySnippet([]byte(long))
+	if len(snippet) > maxErrorBodyBytes+len("...") {
+		t.Errorf("Expected snippet to be truncated, got %d bytes", len(snippet))
+	}
+	if !strings.HasSuffix(snippet, "...") {
+		t.Errorf("Expected truncated snippet to end with ..., got %q", snippet)
+	}
+	if !utf8.ValidString(snippet) {
+		t.Error("Expected snippet to be valid UTF-8")
+	}
+}
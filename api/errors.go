@@ -0,0 +1,75 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrAuthRevoked means the bridge rejected the access token (HTTP 403). The
+// token has been revoked or disabled and a new setup token must be claimed.
+var ErrAuthRevoked = errors.New("simplefin access token was revoked or is invalid")
+
+// ErrPaymentRequired means the SimpleFIN subscription needs attention (HTTP 402)
+var ErrPaymentRequired = errors.New("simplefin bridge requires payment")
+
+// maxErrorBodyBytes caps how much of an error response body is kept
+const maxErrorBodyBytes = 256
+
+// HTTPError is returned for any non-2xx response from the bridge. It unwraps
+// to ErrAuthRevoked or ErrPaymentRequired for 403 and 402 responses, so
+// callers can use errors.Is for those and errors.As for everything else.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Body       string // Leading snippet of the response body
+}
+
+func (e *HTTPError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("simplefin request failed: %s", e.Status)
+	}
+	return fmt.Sprintf("simplefin request failed: %s: %s", e.Status, e.Body)
+}
+
+func (e *HTTPError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusForbidden:
+		return ErrAuthRevoked
+	case http.StatusPaymentRequired:
+		return ErrPaymentRequired
+	}
+	return nil
+}
+
+// Temporary reports whether retrying the request later could succeed
+func (e *HTTPError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+func newHTTPError(resp *http.Response, body []byte) *HTTPError {
+	status := resp.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     status,
+		Body:       bodySnippet(body),
+	}
+}
+
+// bodySnippet collapses whitespace and truncates the body on a rune boundary
+func bodySnippet(body []byte) string {
+	snippet := strings.Join(strings.Fields(string(body)), " ")
+	if len(snippet) <= maxErrorBodyBytes {
+		return snippet
+	}
+	cut := maxErrorBodyBytes
+	for cut > 0 && !utf8.RuneStart(snippet[cut]) {
+		cut--
+	}
+	return snippet[:cut] + "..."
+}
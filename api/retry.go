@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultMaxAttempts = 4
+	DefaultBaseDelay   = 500 * time.Millisecond
+	DefaultMaxDelay    = 30 * time.Second
+)
+
+// RetryRoundTripper retries idempotent requests that fail with a transient
+// network error, a 429 or a 5xx response. Delays grow exponentially with
+// jitter, a Retry-After header takes precedence, and no retry is attempted
+// if it would outlive the request context's deadline or the server asks to
+// wait longer than MaxDelay. It is meant to sit
+// below SimpleFinRoundTripper via its Base field.
+type RetryRoundTripper struct {
+	Base        http.RoundTripper
+	MaxAttempts int           // Total attempts including the first, DefaultMaxAttempts if zero
+	BaseDelay   time.Duration // Delay before the first retry, DefaultBaseDelay if zero
+	MaxDelay    time.Duration // Upper bound on any single delay, DefaultMaxDelay if zero
+}
+
+// NewRetryRoundTripper wraps base with the default retry policy
+func NewRetryRoundTripper(base http.RoundTripper) *RetryRoundTripper {
+	return &RetryRoundTripper{Base: base}
+}
+
+func (rt *RetryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isIdempotent(req.Method) || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return rt.base().RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	maxAttempts := rt.maxAttempts()
+	for attempt := 1; ; attempt++ {
+		attemptReq, err := rewindRequest(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := rt.base().RoundTrip(attemptReq)
+		if attempt >= maxAttempts || !shouldRetry(ctx, resp, err) {
+			return resp, err
+		}
+
+		delay := rt.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				if retryAfter > rt.maxDelay() {
+					// Retrying sooner than asked would only be refused again
+					return resp, err
+				}
+				delay = retryAfter
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (rt *RetryRoundTripper) base() http.RoundTripper {
+	if rt.Base != nil {
+		return rt.Base
+	}
+	return http.DefaultTransport
+}
+
+func (rt *RetryRoundTripper) maxAttempts() int {
+	if rt.MaxAttempts > 0 {
+		return rt.MaxAttempts
+	}
+	return DefaultMaxAttempts
+}
+
+func (rt *RetryRoundTripper) maxDelay() time.Duration {
+	if rt.MaxDelay > 0 {
+		return rt.MaxDelay
+	}
+	return DefaultMaxDelay
+}
+
+// backoff returns the jittered delay before the given retry: half of the
+// exponential step is fixed and the other half is random
+func (rt *RetryRoundTripper) backoff(attempt int) time.Duration {
+	baseDelay, maxDelay := rt.BaseDelay, rt.maxDelay()
+	if baseDelay <= 0 {
+		baseDelay = DefaultBaseDelay
+	}
+
+	delay := baseDelay
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxDelay)
+
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+func rewindRequest(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 1 || req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	cloned := req.Clone(req.Context())
+	cloned.Body = body
+	return cloned, nil
+}
+
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return isTransientError(err)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// isTransientError reports whether a transport error is worth retrying, such
+// as a timeout, a refused or reset connection, or a connection closed mid-response
+func isTransientError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header given either as delay seconds
+// or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,322 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// scriptedRoundTripper returns one scripted result per call and repeats the
+// last one once the script runs out
+type scriptedRoundTripper struct {
+	calls    int
+	statuses []int
+	errs     []error
+	headers  []http.Header
+	lastBody string
+}
+
+func (s *scriptedRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	i := min(s.calls, max(len(s.statuses), len(s.errs))-1)
+	s.calls++
+	if req.Body != nil {
+		b, _ := io.ReadAll(req.Body)
+		s.lastBody = string(b)
+	}
+	if i < len(s.errs) && s.errs[i] != nil {
+		return nil, s.errs[i]
+	}
+	header := make(http.Header)
+	if i < len(s.headers) && s.headers[i] != nil {
+		header = s.headers[i]
+	}
+	return &http.Response{
+		StatusCode: s.statuses[i],
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader("body")),
+	}, nil
+}
+
+func newTestRetryRoundTripper(base http.RoundTripper) *RetryRoundTripper {
+	return &RetryRoundTripper{
+		Base:        base,
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    5 * time.Millisecond,
+	}
+}
+
+func TestRetryRoundTripper_RetriesServerErrors(t *testing.T) {
+	base := &scriptedRoundTripper{statuses: []int{503, 502, 200}}
+	rt := newTestRetryRoundTripper(base)
+
+	req := httptest.NewRequest(http.MethodGet, "https://api.example.com/accounts", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if base.calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", base.calls)
+	}
+}
+
+func TestRetryRoundTripper_GivesUpAfterMaxAttempts(t *testing.T) {
+	base := &scriptedRoundTripper{statuses: []int{500}}
+	rt := newTestRetryRoundTripper(base)
+
+	req := httptest.NewRequest(http.MethodGet, "https://api.example.com/accounts", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != 500 {
+		t.Errorf("Expected the last response to be returned, got %d", resp.StatusCode)
+	}
+	if base.calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", base.calls)
+	}
+}
+
+func TestRetryRoundTripper_DoesNotRetryClientErrors(t *testing.T) {
+	for _, status := range []int{400, 402, 403, 404} {
+		base := &scriptedRoundTripper{statuses: []int{status, 200}}
+		rt := newTestRetryRoundTripper(base)
+
+		req := httptest.NewRequest(http.MethodGet, "https://api.example.com/accounts", nil)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if resp.StatusCode != status {
+			t.Errorf("Expected status %d, got %d", status, resp.StatusCode)
+		}
+		if base.calls != 1 {
+			t.Errorf("Expected status %d not to be retried, got %d attempts", status, base.calls)
+		}
+	}
+}
+
+func TestRetryRoundTripper_TransientNetworkErrors(t *testing.T) {
+	base := &scriptedRoundTripper{
+		errs:     []error{syscall.ECONNRESET, io.ErrUnexpectedEOF, nil},
+		statuses: []int{0, 0, 200},
+	}
+	rt := newTestRetryRoundTripper(base)
+
+	req := httptest.NewRequest(http.MethodGet, "https://api.example.com/accounts", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != 200 || base.calls != 3 {
+		t.Errorf("Expected success on attempt 3, got status %d after %d attempts", resp.StatusCode, base.calls)
+	}
+}
+
+func TestRetryRoundTripper_PermanentNetworkError(t *testing.T) {
+	permanent := errors.New("x509: certificate signed by unknown authority")
+	base := &scriptedRoundTripper{errs: []error{permanent}}
+	rt := newTestRetryRoundTripper(base)
+
+	req := httptest.NewRequest(http.MethodGet, "https://api.example.com/accounts", nil)
+	_, err := rt.RoundTrip(req)
+	if !errors.Is(err, permanent) {
+		t.Errorf("Expected permanent error, got %v", err)
+	}
+	if base.calls != 1 {
+		t.Errorf("Expected 1 attempt, got %d", base.calls)
+	}
+}
+
+func TestRetryRoundTripper_HonorsRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	var firstAt, secondAt time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			firstAt = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		secondAt = time.Now()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	rt := newTestRetryRoundTripper(server.Client().Transport)
+	rt.MaxDelay = 2 * time.Second
+	client := &http.Client{Transport: rt}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if wait := secondAt.Sub(firstAt); wait < 900*time.Millisecond {
+		t.Errorf("Expected retry to wait for Retry-After, waited %v", wait)
+	}
+}
+
+func TestRetryRoundTripper_RetryAfterBeyondMaxDelay(t *testing.T) {
+	base := &scriptedRoundTripper{
+		statuses: []int{429, 200},
+		headers:  []http.Header{{"Retry-After": []string{"86400"}}},
+	}
+	rt := newTestRetryRoundTripper(base)
+	req := httptest.NewRequest(http.MethodGet, "https://api.example.com/accounts", nil)
+
+	start := time.Now()
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != 429 {
+		t.Errorf("Expected the 429 to be returned, got %d", resp.StatusCode)
+	}
+	if base.calls != 1 {
+		t.Errorf("Expected no retry when Retry-After exceeds MaxDelay, got %d attempts", base.calls)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected to give up immediately, took %v", elapsed)
+	}
+}
+
+func TestRetryRoundTripper_StopsAtContextDeadline(t *testing.T) {
+	base := &scriptedRoundTripper{
+		statuses: []int{429, 200},
+		headers:  []http.Header{{"Retry-After": []string{"60"}}},
+	}
+	rt := newTestRetryRoundTripper(base)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "https://api.example.com/accounts", nil).WithContext(ctx)
+
+	start := time.Now()
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != 429 {
+		t.Errorf("Expected the 429 to be returned, got %d", resp.StatusCode)
+	}
+	if base.calls != 1 {
+		t.Errorf("Expected no retry past the deadline, got %d attempts", base.calls)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected to give up immediately, took %v", elapsed)
+	}
+}
+
+func TestRetryRoundTripper_CancelledWhileWaiting(t *testing.T) {
+	base := &scriptedRoundTripper{statuses: []int{503}}
+	rt := &RetryRoundTripper{Base: base, MaxAttempts: 3, BaseDelay: time.Minute}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "https://api.example.com/accounts", nil).WithContext(ctx)
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	_, err := rt.RoundTrip(req)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if base.calls != 1 {
+		t.Errorf("Expected 1 attempt, got %d", base.calls)
+	}
+}
+
+func TestRetryRoundTripper_DoesNotRetryPost(t *testing.T) {
+	base := &scriptedRoundTripper{statuses: []int{503, 200}}
+	rt := newTestRetryRoundTripper(base)
+
+	req := httptest.NewRequest(http.MethodPost, "https://api.example.com/claim", strings.NewReader("{}"))
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != 503 || base.calls != 1 {
+		t.Errorf("Expected POST not to be retried, got status %d after %d attempts", resp.StatusCode, base.calls)
+	}
+}
+
+func TestRetryRoundTripper_RewindsBody(t *testing.T) {
+	base := &scriptedRoundTripper{statuses: []int{503, 200}}
+	rt := newTestRetryRoundTripper(base)
+
+	req, err := http.NewRequest(http.MethodPut, "https://api.example.com/resource", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatalf("Expected no error creating request, got %v", err)
+	}
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if base.calls != 2 {
+		t.Errorf("Expected 2 attempts, got %d", base.calls)
+	}
+	if base.lastBody != "payload" {
+		t.Errorf("Expected retried body to be %q, got %q", "payload", base.lastBody)
+	}
+}
+
+func TestRetryRoundTripper_Backoff(t *testing.T) {
+	rt := &RetryRoundTripper{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	testCases := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{attempt: 1, ceiling: 100 * time.Millisecond},
+		{attempt: 2, ceiling: 200 * time.Millisecond},
+		{attempt: 3, ceiling: 400 * time.Millisecond},
+		{attempt: 10, ceiling: time.Second},
+	}
+
+	for _, tc := range testCases {
+		for i := 0; i < 50; i++ {
+			delay := rt.backoff(tc.attempt)
+			if delay < tc.ceiling/2 || delay > tc.ceiling {
+				t.Fatalf("Attempt %d: expected delay in [%v, %v], got %v", tc.attempt, tc.ceiling/2, tc.ceiling, delay)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name  string
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{name: "empty", value: "", ok: false},
+		{name: "seconds", value: "120", want: 2 * time.Minute, ok: true},
+		{name: "negative", value: "-1", ok: false},
+		{name: "http date", value: "Mon, 01 Jan 2024 12:00:30 GMT", want: 30 * time.Second, ok: true},
+		{name: "past date", value: "Mon, 01 Jan 2024 11:00:00 GMT", want: 0, ok: true},
+		{name: "garbage", value: "soon", ok: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tc.value, now)
+			if ok != tc.ok || got != tc.want {
+				t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tc.value, got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
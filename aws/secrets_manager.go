@@ -2,9 +2,14 @@ package aws
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -12,11 +17,32 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/criswit/chi-chi-moni/api"
+	"github.com/criswit/chi-chi-moni/credstore"
 )
 
+var (
+	_ credstore.VersionedStore   = (*SecretsManagerClient)(nil)
+	_ credstore.RecoverableStore = (*SecretsManagerClient)(nil)
+)
+
+// secretsManagerAPI is the subset of the Secrets Manager API used by
+// SecretsManagerClient, so tests can substitute a mock
+type secretsManagerAPI interface {
+	CreateSecret(ctx context.Context, params *secretsmanager.CreateSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error)
+	UpdateSecret(ctx context.Context, params *secretsmanager.UpdateSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.UpdateSecretOutput, error)
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+	DeleteSecret(ctx context.Context, params *secretsmanager.DeleteSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DeleteSecretOutput, error)
+	DescribeSecret(ctx context.Context, params *secretsmanager.DescribeSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error)
+	ListSecrets(ctx context.Context, params *secretsmanager.ListSecretsInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretsOutput, error)
+	PutSecretValue(ctx context.Context, params *secretsmanager.PutSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error)
+	UpdateSecretVersionStage(ctx context.Context, params *secretsmanager.UpdateSecretVersionStageInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.UpdateSecretVersionStageOutput, error)
+	ListSecretVersionIds(ctx context.Context, params *secretsmanager.ListSecretVersionIdsInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretVersionIdsOutput, error)
+	RestoreSecret(ctx context.Context, params *secretsmanager.RestoreSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.RestoreSecretOutput, error)
+}
+
 // SecretsManagerClient wraps AWS Secrets Manager operations
 type SecretsManagerClient struct {
-	client    *secretsmanager.Client
+	client    secretsManagerAPI
 	ssoClient *SSOClient
 	config    aws.Config
 }
@@ -74,10 +100,21 @@ func (sm *SecretsManagerClient) StoreAccessToken(ctx context.Context, secretName
 
 // RetrieveAccessToken retrieves an AccessToken from AWS Secrets Manager
 func (sm *SecretsManagerClient) RetrieveAccessToken(ctx context.Context, secretName string) (api.AccessToken, error) {
-	input := &secretsmanager.GetSecretValueInput{
+	return sm.getAccessToken(ctx, &secretsmanager.GetSecretValueInput{
 		SecretId: aws.String(secretName),
-	}
+	})
+}
 
+// RetrieveAccessTokenVersion retrieves the AccessToken stored in one version
+// of a secret, whatever its stage
+func (sm *SecretsManagerClient) RetrieveAccessTokenVersion(ctx context.Context, secretName string, versionID string) (api.AccessToken, error) {
+	return sm.getAccessToken(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId:  aws.String(secretName),
+		VersionId: aws.String(versionID),
+	})
+}
+
+func (sm *SecretsManagerClient) getAccessToken(ctx context.Context, input *secretsmanager.GetSecretValueInput) (api.AccessToken, error) {
 	result, err := sm.client.GetSecretValue(ctx, input)
 	if err != nil {
 		return api.AccessToken{}, fmt.Errorf("failed to get secret value: %w", err)
@@ -96,21 +133,194 @@ func (sm *SecretsManagerClient) RetrieveAccessToken(ctx context.Context, secretN
 	return token, nil
 }
 
-// DeleteAccessToken deletes an AccessToken from AWS Secrets Manager
-func (sm *SecretsManagerClient) DeleteAccessToken(ctx context.Context, secretName string) error {
+// StagePendingAccessToken stores an AccessToken as a new AWSPENDING version of
+// an existing secret, leaving AWSCURRENT untouched, and returns the version ID
+func (sm *SecretsManagerClient) StagePendingAccessToken(ctx context.Context, secretName string, token api.AccessToken) (string, error) {
+	tokenJSON, err := json.Marshal(token)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal access token: %w", err)
+	}
+	requestToken, err := newClientRequestToken()
+	if err != nil {
+		return "", err
+	}
+
+	result, err := sm.client.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:           aws.String(secretName),
+		SecretString:       aws.String(string(tokenJSON)),
+		ClientRequestToken: aws.String(requestToken),
+		VersionStages:      []string{credstore.StagePending},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to stage pending secret version: %w", err)
+	}
+	return aws.ToString(result.VersionId), nil
+}
+
+// PromoteAccessTokenVersion moves AWSCURRENT to a version of the secret and
+// clears its AWSPENDING label. Secrets Manager labels the version it replaces
+// AWSPREVIOUS, so that token stays available for a rollback.
+func (sm *SecretsManagerClient) PromoteAccessTokenVersion(ctx context.Context, secretName string, versionID string) error {
+	described, err := sm.client.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(secretName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe secret: %w", err)
+	}
+	stages, ok := described.VersionIdsToStages[versionID]
+	if !ok {
+		return fmt.Errorf("secret %s has no version %s", secretName, versionID)
+	}
+	var currentID string
+	for id, idStages := range described.VersionIdsToStages {
+		if slices.Contains(idStages, credstore.StageCurrent) {
+			currentID = id
+		}
+	}
+
+	if currentID != versionID {
+		input := &secretsmanager.UpdateSecretVersionStageInput{
+			SecretId:        aws.String(secretName),
+			VersionStage:    aws.String(credstore.StageCurrent),
+			MoveToVersionId: aws.String(versionID),
+		}
+		if currentID != "" {
+			input.RemoveFromVersionId = aws.String(currentID)
+		}
+		if _, err := sm.client.UpdateSecretVersionStage(ctx, input); err != nil {
+			return fmt.Errorf("failed to promote secret version %s: %w", versionID, err)
+		}
+	}
+
+	if slices.Contains(stages, credstore.StagePending) {
+		_, err := sm.client.UpdateSecretVersionStage(ctx, &secretsmanager.UpdateSecretVersionStageInput{
+			SecretId:            aws.String(secretName),
+			VersionStage:        aws.String(credstore.StagePending),
+			RemoveFromVersionId: aws.String(versionID),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to clear %s from secret version %s: %w", credstore.StagePending, versionID, err)
+		}
+	}
+	return nil
+}
+
+// ListAccessTokenVersions lists the versions of a secret that still have a
+// stage, newest first
+func (sm *SecretsManagerClient) ListAccessTokenVersions(ctx context.Context, secretName string) ([]credstore.Version, error) {
+	var versions []credstore.Version
+	paginator := secretsmanager.NewListSecretVersionIdsPaginator(sm.client, &secretsmanager.ListSecretVersionIdsInput{
+		SecretId: aws.String(secretName),
+	})
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list secret versions: %w", err)
+		}
+		for _, entry := range result.Versions {
+			versions = append(versions, credstore.Version{
+				ID:           aws.ToString(entry.VersionId),
+				Stages:       entry.VersionStages,
+				CreatedAt:    aws.ToTime(entry.CreatedDate),
+				LastAccessed: entry.LastAccessedDate,
+			})
+		}
+	}
+
+	slices.SortStableFunc(versions, func(a, b credstore.Version) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return versions, nil
+}
+
+// newClientRequestToken returns a random version ID for PutSecretValue
+func newClientRequestToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate secret version ID: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// SecretExists reports whether a secret with the given name exists, including
+// one that is scheduled for deletion
+func (sm *SecretsManagerClient) SecretExists(ctx context.Context, secretName string) (bool, error) {
+	_, err := sm.client.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(secretName),
+	})
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to describe secret: %w", err)
+	}
+	return true, nil
+}
+
+// DeleteAccessToken deletes an AccessToken from AWS Secrets Manager. Unless
+// opts.Force is set the secret is only scheduled for deletion, and
+// RestoreAccessToken can bring it back until the recovery window ends. It
+// returns when the deletion takes effect.
+func (sm *SecretsManagerClient) DeleteAccessToken(ctx context.Context, secretName string, opts credstore.DeleteOptions) (time.Time, error) {
 	input := &secretsmanager.DeleteSecretInput{
-		SecretId:                   aws.String(secretName),
-		ForceDeleteWithoutRecovery: aws.Bool(true),
+		SecretId: aws.String(secretName),
+	}
+	if opts.Force {
+		input.ForceDeleteWithoutRecovery = aws.Bool(true)
+	} else {
+		days := opts.RecoveryWindowDays
+		if days == 0 {
+			days = credstore.DefaultRecoveryWindowDays
+		}
+		if days < credstore.MinRecoveryWindowDays || days > credstore.MaxRecoveryWindowDays {
+			return time.Time{}, fmt.Errorf("recovery window must be %d to %d days, got %d",
+				credstore.MinRecoveryWindowDays, credstore.MaxRecoveryWindowDays, days)
+		}
+		input.RecoveryWindowInDays = aws.Int64(int64(days))
 	}
 
-	_, err := sm.client.DeleteSecret(ctx, input)
+	result, err := sm.client.DeleteSecret(ctx, input)
 	if err != nil {
-		return fmt.Errorf("failed to delete secret: %w", err)
+		return time.Time{}, fmt.Errorf("failed to delete secret: %w", err)
 	}
 
+	return aws.ToTime(result.DeletionDate), nil
+}
+
+// RestoreAccessToken cancels the scheduled deletion of a secret
+func (sm *SecretsManagerClient) RestoreAccessToken(ctx context.Context, secretName string) error {
+	_, err := sm.client.RestoreSecret(ctx, &secretsmanager.RestoreSecretInput{
+		SecretId: aws.String(secretName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restore secret: %w", err)
+	}
 	return nil
 }
 
+// DescribeAccessToken returns a secret's ARN, the day it was last read and
+// any scheduled deletion date
+func (sm *SecretsManagerClient) DescribeAccessToken(ctx context.Context, secretName string) (credstore.Info, error) {
+	result, err := sm.client.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(secretName),
+	})
+	if err != nil {
+		return credstore.Info{}, fmt.Errorf("failed to describe secret: %w", err)
+	}
+
+	info := credstore.Info{
+		Name:         secretName,
+		ARN:          aws.ToString(result.ARN),
+		LastAccessed: result.LastAccessedDate,
+		DeletionDate: result.DeletedDate,
+	}
+	if result.Name != nil {
+		info.Name = *result.Name
+	}
+	return info, nil
+}
+
 // ListSecrets lists all secrets with a specific prefix
 func (sm *SecretsManagerClient) ListSecrets(ctx context.Context, prefix string) ([]string, error) {
 	input := &secretsmanager.ListSecretsInput{}
@@ -144,6 +354,77 @@ func (sm *SecretsManagerClient) ListSecrets(ctx context.Context, prefix string)
 	return secretNames, nil
 }
 
+// Get implements credstore.CredentialStore
+func (sm *SecretsManagerClient) Get(ctx context.Context, name string) (api.AccessToken, error) {
+	token, err := sm.RetrieveAccessToken(ctx, name)
+	return token, notFoundAsCredstore(err, name)
+}
+
+// Put implements credstore.CredentialStore
+func (sm *SecretsManagerClient) Put(ctx context.Context, name string, token api.AccessToken) error {
+	return sm.StoreAccessToken(ctx, name, token)
+}
+
+// Delete implements credstore.CredentialStore
+func (sm *SecretsManagerClient) Delete(ctx context.Context, name string) error {
+	_, err := sm.DeleteWithOptions(ctx, name, credstore.DeleteOptions{})
+	return err
+}
+
+// Describe implements credstore.RecoverableStore
+func (sm *SecretsManagerClient) Describe(ctx context.Context, name string) (credstore.Info, error) {
+	info, err := sm.DescribeAccessToken(ctx, name)
+	return info, notFoundAsCredstore(err, name)
+}
+
+// DeleteWithOptions implements credstore.RecoverableStore
+func (sm *SecretsManagerClient) DeleteWithOptions(ctx context.Context, name string, opts credstore.DeleteOptions) (time.Time, error) {
+	deletionDate, err := sm.DeleteAccessToken(ctx, name, opts)
+	return deletionDate, notFoundAsCredstore(err, name)
+}
+
+// Restore implements credstore.RecoverableStore
+func (sm *SecretsManagerClient) Restore(ctx context.Context, name string) error {
+	return notFoundAsCredstore(sm.RestoreAccessToken(ctx, name), name)
+}
+
+// List implements credstore.CredentialStore
+func (sm *SecretsManagerClient) List(ctx context.Context, prefix string) ([]string, error) {
+	return sm.ListSecrets(ctx, prefix)
+}
+
+// Stage implements credstore.VersionedStore
+func (sm *SecretsManagerClient) Stage(ctx context.Context, name string, token api.AccessToken) (string, error) {
+	versionID, err := sm.StagePendingAccessToken(ctx, name, token)
+	return versionID, notFoundAsCredstore(err, name)
+}
+
+// GetVersion implements credstore.VersionedStore
+func (sm *SecretsManagerClient) GetVersion(ctx context.Context, name string, versionID string) (api.AccessToken, error) {
+	token, err := sm.RetrieveAccessTokenVersion(ctx, name, versionID)
+	return token, notFoundAsCredstore(err, name)
+}
+
+// Promote implements credstore.VersionedStore
+func (sm *SecretsManagerClient) Promote(ctx context.Context, name string, versionID string) error {
+	return notFoundAsCredstore(sm.PromoteAccessTokenVersion(ctx, name, versionID), name)
+}
+
+// Versions implements credstore.VersionedStore
+func (sm *SecretsManagerClient) Versions(ctx context.Context, name string) ([]credstore.Version, error) {
+	versions, err := sm.ListAccessTokenVersions(ctx, name)
+	return versions, notFoundAsCredstore(err, name)
+}
+
+// notFoundAsCredstore makes a missing secret match credstore.ErrNotFound
+func notFoundAsCredstore(err error, name string) error {
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return fmt.Errorf("%w: secret %s: %w", credstore.ErrNotFound, name, err)
+	}
+	return err
+}
+
 // NewSecretsManagerClientWithSSO creates a new Secrets Manager client with SSO support
 func NewSecretsManagerClientWithSSO(ctx context.Context, ssoClient *SSOClient) (*SecretsManagerClient, error) {
 	// Check credential status
@@ -154,7 +435,6 @@ func NewSecretsManagerClientWithSSO(ctx context.Context, ssoClient *SSOClient) (
 
 	// If credentials are expired or not found, initiate SSO login
 	if status == CredentialStatusExpired || status == CredentialStatusNotFound {
-		fmt.Println("AWS credentials expired or not found. Initiating SSO login...")
 		authResult, err := ssoClient.InitiateLoginFlow(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("SSO login failed: %w", err)
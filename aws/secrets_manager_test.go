@@ -3,10 +3,15 @@ package aws
 import (
 	"context"
 	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
 	"github.com/criswit/chi-chi-moni/api"
+	"github.com/criswit/chi-chi-moni/credstore"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -48,6 +53,54 @@ func (m *MockSecretsManagerAPI) DeleteSecret(ctx context.Context, params *secret
 	return args.Get(0).(*secretsmanager.DeleteSecretOutput), args.Error(1)
 }
 
+func (m *MockSecretsManagerAPI) DescribeSecret(ctx context.Context, params *secretsmanager.DescribeSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*secretsmanager.DescribeSecretOutput), args.Error(1)
+}
+
+func (m *MockSecretsManagerAPI) ListSecrets(ctx context.Context, params *secretsmanager.ListSecretsInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretsOutput, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*secretsmanager.ListSecretsOutput), args.Error(1)
+}
+
+func (m *MockSecretsManagerAPI) PutSecretValue(ctx context.Context, params *secretsmanager.PutSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*secretsmanager.PutSecretValueOutput), args.Error(1)
+}
+
+func (m *MockSecretsManagerAPI) UpdateSecretVersionStage(ctx context.Context, params *secretsmanager.UpdateSecretVersionStageInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*secretsmanager.UpdateSecretVersionStageOutput), args.Error(1)
+}
+
+func (m *MockSecretsManagerAPI) ListSecretVersionIds(ctx context.Context, params *secretsmanager.ListSecretVersionIdsInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretVersionIdsOutput, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*secretsmanager.ListSecretVersionIdsOutput), args.Error(1)
+}
+
+func (m *MockSecretsManagerAPI) RestoreSecret(ctx context.Context, params *secretsmanager.RestoreSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.RestoreSecretOutput, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*secretsmanager.RestoreSecretOutput), args.Error(1)
+}
+
 // MockSecretsManagerClient wraps the mock for easier testing
 type MockSecretsManagerClient struct {
 	mockAPI *MockSecretsManagerAPI
@@ -212,21 +265,49 @@ func TestAccessToken_JSONMarshaling(t *testing.T) {
 func TestSecretsManagerClient_DeleteAccessToken(t *testing.T) {
 	ctx := context.Background()
 	secretName := "test-secret"
+	deletionDate := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
 
 	tests := []struct {
 		name    string
+		opts    credstore.DeleteOptions
 		setup   func(*MockSecretsManagerAPI)
 		wantErr bool
 	}{
 		{
-			name: "successful deletion",
+			name: "default recovery window",
+			setup: func(m *MockSecretsManagerAPI) {
+				m.On("DeleteSecret", ctx, mock.MatchedBy(func(input *secretsmanager.DeleteSecretInput) bool {
+					return *input.SecretId == secretName && input.ForceDeleteWithoutRecovery == nil && *input.RecoveryWindowInDays == 30
+				})).Return(&secretsmanager.DeleteSecretOutput{DeletionDate: &deletionDate}, nil)
+			},
+			wantErr: false,
+		},
+		{
+			name: "custom recovery window",
+			opts: credstore.DeleteOptions{RecoveryWindowDays: 7},
+			setup: func(m *MockSecretsManagerAPI) {
+				m.On("DeleteSecret", ctx, mock.MatchedBy(func(input *secretsmanager.DeleteSecretInput) bool {
+					return *input.RecoveryWindowInDays == 7
+				})).Return(&secretsmanager.DeleteSecretOutput{DeletionDate: &deletionDate}, nil)
+			},
+			wantErr: false,
+		},
+		{
+			name: "forced deletion",
+			opts: credstore.DeleteOptions{Force: true},
 			setup: func(m *MockSecretsManagerAPI) {
 				m.On("DeleteSecret", ctx, mock.MatchedBy(func(input *secretsmanager.DeleteSecretInput) bool {
-					return *input.SecretId == secretName && *input.ForceDeleteWithoutRecovery == true
-				})).Return(&secretsmanager.DeleteSecretOutput{}, nil)
+					return *input.SecretId == secretName && *input.ForceDeleteWithoutRecovery == true && input.RecoveryWindowInDays == nil
+				})).Return(&secretsmanager.DeleteSecretOutput{DeletionDate: &deletionDate}, nil)
 			},
 			wantErr: false,
 		},
+		{
+			name:    "recovery window out of range",
+			opts:    credstore.DeleteOptions{RecoveryWindowDays: 3},
+			setup:   func(m *MockSecretsManagerAPI) {},
+			wantErr: true,
+		},
 		{
 			name: "deletion fails",
 			setup: func(m *MockSecretsManagerAPI) {
@@ -238,11 +319,224 @@ func TestSecretsManagerClient_DeleteAccessToken(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockClient := NewMockSecretsManagerClient()
-			tt.setup(mockClient.mockAPI)
+			mockAPI := &MockSecretsManagerAPI{}
+			tt.setup(mockAPI)
+			sm := &SecretsManagerClient{client: mockAPI}
+
+			got, err := sm.DeleteAccessToken(ctx, secretName, tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, deletionDate, got)
+			}
+			mockAPI.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSecretsManagerClient_RestoreAndDescribe(t *testing.T) {
+	ctx := context.Background()
+	lastAccessed := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	deletionDate := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	notFound := &types.ResourceNotFoundException{Message: aws.String("not found")}
+
+	mockAPI := &MockSecretsManagerAPI{}
+	mockAPI.On("DescribeSecret", ctx, mock.MatchedBy(func(input *secretsmanager.DescribeSecretInput) bool {
+		return *input.SecretId == "monk-monies"
+	})).Return(&secretsmanager.DescribeSecretOutput{
+		ARN:              aws.String("arn:aws:secretsmanager:us-east-1:123456789012:secret:monk-monies-AbCdEf"),
+		Name:             aws.String("monk-monies"),
+		LastAccessedDate: &lastAccessed,
+		DeletedDate:      &deletionDate,
+	}, nil)
+	mockAPI.On("RestoreSecret", ctx, mock.MatchedBy(func(input *secretsmanager.RestoreSecretInput) bool {
+		return *input.SecretId == "monk-monies"
+	})).Return(&secretsmanager.RestoreSecretOutput{}, nil)
+	mockAPI.On("RestoreSecret", ctx, mock.MatchedBy(func(input *secretsmanager.RestoreSecretInput) bool {
+		return *input.SecretId == "missing"
+	})).Return(nil, notFound)
+
+	var store credstore.RecoverableStore = &SecretsManagerClient{client: mockAPI}
+
+	info, err := store.Describe(ctx, "monk-monies")
+	assert.NoError(t, err)
+	assert.Equal(t, credstore.Info{
+		Name:         "monk-monies",
+		ARN:          "arn:aws:secretsmanager:us-east-1:123456789012:secret:monk-monies-AbCdEf",
+		LastAccessed: &lastAccessed,
+		DeletionDate: &deletionDate,
+	}, info)
 
-			// Test the deletion logic conceptually
-			// In a real implementation, we'd inject the mock properly
+	assert.NoError(t, store.Restore(ctx, "monk-monies"))
+	assert.ErrorIs(t, store.Restore(ctx, "missing"), credstore.ErrNotFound)
+	mockAPI.AssertExpectations(t)
+}
+
+func TestSecretsManagerClient_SecretExists(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		err     error
+		want    bool
+		wantErr bool
+	}{
+		{name: "exists", want: true},
+		{name: "not found", err: &types.ResourceNotFoundException{Message: aws.String("not found")}, want: false},
+		{name: "access denied", err: assert.AnError, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAPI := &MockSecretsManagerAPI{}
+			matcher := mock.MatchedBy(func(input *secretsmanager.DescribeSecretInput) bool {
+				return *input.SecretId == "test-secret"
+			})
+			if tt.err != nil {
+				mockAPI.On("DescribeSecret", ctx, matcher).Return(nil, tt.err)
+			} else {
+				mockAPI.On("DescribeSecret", ctx, matcher).Return(&secretsmanager.DescribeSecretOutput{}, nil)
+			}
+
+			client := &SecretsManagerClient{client: mockAPI}
+			exists, err := client.SecretExists(ctx, "test-secret")
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, exists)
+			}
+			mockAPI.AssertExpectations(t)
 		})
 	}
 }
+
+func TestSecretsManagerClient_CredentialStore(t *testing.T) {
+	ctx := context.Background()
+	notFound := &types.ResourceNotFoundException{Message: aws.String("not found")}
+
+	mockAPI := &MockSecretsManagerAPI{}
+	secretString := `{"Username":"user","Password":"pass","Url":"bridge.example.com"}`
+	mockAPI.On("GetSecretValue", ctx, mock.MatchedBy(func(input *secretsmanager.GetSecretValueInput) bool {
+		return *input.SecretId == "present"
+	})).Return(&secretsmanager.GetSecretValueOutput{SecretString: &secretString}, nil)
+	mockAPI.On("GetSecretValue", ctx, mock.MatchedBy(func(input *secretsmanager.GetSecretValueInput) bool {
+		return *input.SecretId == "missing"
+	})).Return(nil, notFound)
+	mockAPI.On("DeleteSecret", ctx, mock.Anything).Return(nil, notFound)
+
+	var store credstore.CredentialStore = &SecretsManagerClient{client: mockAPI}
+
+	token, err := store.Get(ctx, "present")
+	assert.NoError(t, err)
+	assert.Equal(t, api.AccessToken{Username: "user", Password: "pass", Url: "bridge.example.com"}, token)
+
+	_, err = store.Get(ctx, "missing")
+	assert.ErrorIs(t, err, credstore.ErrNotFound)
+	exists, err := credstore.Exists(ctx, store, "missing")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	assert.ErrorIs(t, store.Delete(ctx, "missing"), credstore.ErrNotFound)
+	mockAPI.AssertExpectations(t)
+}
+
+func TestSecretsManagerClient_StagePendingAccessToken(t *testing.T) {
+	ctx := context.Background()
+	token := api.AccessToken{Username: "user", Password: "pass", Url: "bridge.example.com"}
+
+	mockAPI := &MockSecretsManagerAPI{}
+	mockAPI.On("PutSecretValue", ctx, mock.MatchedBy(func(input *secretsmanager.PutSecretValueInput) bool {
+		return *input.SecretId == "monk-monies" &&
+			len(*input.ClientRequestToken) == 32 &&
+			assert.ObjectsAreEqual([]string{credstore.StagePending}, input.VersionStages) &&
+			strings.Contains(*input.SecretString, `"Username":"user"`)
+	})).Return(&secretsmanager.PutSecretValueOutput{VersionId: aws.String("v2")}, nil)
+
+	sm := &SecretsManagerClient{client: mockAPI}
+	versionID, err := sm.Stage(ctx, "monk-monies", token)
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", versionID)
+	mockAPI.AssertExpectations(t)
+}
+
+func TestSecretsManagerClient_PromoteAccessTokenVersion(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("moves_current_and_clears_pending", func(t *testing.T) {
+		mockAPI := &MockSecretsManagerAPI{}
+		mockAPI.On("DescribeSecret", ctx, mock.Anything).Return(&secretsmanager.DescribeSecretOutput{
+			VersionIdsToStages: map[string][]string{
+				"v1": {credstore.StageCurrent},
+				"v2": {credstore.StagePending},
+			},
+		}, nil)
+		mockAPI.On("UpdateSecretVersionStage", ctx, mock.MatchedBy(func(input *secretsmanager.UpdateSecretVersionStageInput) bool {
+			return *input.VersionStage == credstore.StageCurrent && *input.MoveToVersionId == "v2" && *input.RemoveFromVersionId == "v1"
+		})).Return(&secretsmanager.UpdateSecretVersionStageOutput{}, nil).Once()
+		mockAPI.On("UpdateSecretVersionStage", ctx, mock.MatchedBy(func(input *secretsmanager.UpdateSecretVersionStageInput) bool {
+			return *input.VersionStage == credstore.StagePending && input.MoveToVersionId == nil && *input.RemoveFromVersionId == "v2"
+		})).Return(&secretsmanager.UpdateSecretVersionStageOutput{}, nil).Once()
+
+		sm := &SecretsManagerClient{client: mockAPI}
+		assert.NoError(t, sm.Promote(ctx, "monk-monies", "v2"))
+		mockAPI.AssertExpectations(t)
+	})
+
+	t.Run("rolls_back_to_previous", func(t *testing.T) {
+		mockAPI := &MockSecretsManagerAPI{}
+		mockAPI.On("DescribeSecret", ctx, mock.Anything).Return(&secretsmanager.DescribeSecretOutput{
+			VersionIdsToStages: map[string][]string{
+				"v1": {credstore.StagePrevious},
+				"v2": {credstore.StageCurrent},
+			},
+		}, nil)
+		mockAPI.On("UpdateSecretVersionStage", ctx, mock.MatchedBy(func(input *secretsmanager.UpdateSecretVersionStageInput) bool {
+			return *input.VersionStage == credstore.StageCurrent && *input.MoveToVersionId == "v1" && *input.RemoveFromVersionId == "v2"
+		})).Return(&secretsmanager.UpdateSecretVersionStageOutput{}, nil).Once()
+
+		sm := &SecretsManagerClient{client: mockAPI}
+		assert.NoError(t, sm.Promote(ctx, "monk-monies", "v1"))
+		mockAPI.AssertExpectations(t)
+	})
+
+	t.Run("unknown_version", func(t *testing.T) {
+		mockAPI := &MockSecretsManagerAPI{}
+		mockAPI.On("DescribeSecret", ctx, mock.Anything).Return(&secretsmanager.DescribeSecretOutput{
+			VersionIdsToStages: map[string][]string{"v1": {credstore.StageCurrent}},
+		}, nil)
+
+		sm := &SecretsManagerClient{client: mockAPI}
+		assert.ErrorContains(t, sm.Promote(ctx, "monk-monies", "v9"), "has no version v9")
+		mockAPI.AssertNotCalled(t, "UpdateSecretVersionStage", mock.Anything, mock.Anything)
+	})
+}
+
+func TestSecretsManagerClient_ListAccessTokenVersions(t *testing.T) {
+	ctx := context.Background()
+	older := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	newer := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	mockAPI := &MockSecretsManagerAPI{}
+	mockAPI.On("ListSecretVersionIds", ctx, mock.MatchedBy(func(input *secretsmanager.ListSecretVersionIdsInput) bool {
+		return input.NextToken == nil
+	})).Return(&secretsmanager.ListSecretVersionIdsOutput{
+		Versions:  []types.SecretVersionsListEntry{{VersionId: aws.String("v1"), VersionStages: []string{credstore.StagePrevious}, CreatedDate: &older}},
+		NextToken: aws.String("page2"),
+	}, nil)
+	mockAPI.On("ListSecretVersionIds", ctx, mock.MatchedBy(func(input *secretsmanager.ListSecretVersionIdsInput) bool {
+		return aws.ToString(input.NextToken) == "page2"
+	})).Return(&secretsmanager.ListSecretVersionIdsOutput{
+		Versions: []types.SecretVersionsListEntry{{VersionId: aws.String("v2"), VersionStages: []string{credstore.StageCurrent}, CreatedDate: &newer, LastAccessedDate: &newer}},
+	}, nil)
+
+	sm := &SecretsManagerClient{client: mockAPI}
+	versions, err := sm.Versions(ctx, "monk-monies")
+	assert.NoError(t, err)
+	assert.Equal(t, []credstore.Version{
+		{ID: "v2", Stages: []string{credstore.StageCurrent}, CreatedAt: newer, LastAccessed: &newer},
+		{ID: "v1", Stages: []string{credstore.StagePrevious}, CreatedAt: older},
+	}, versions)
+	mockAPI.AssertExpectations(t)
+}
@@ -0,0 +1,283 @@
+package aws
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EnvConfigFile overrides where the AWS shared config file is read from, as
+// it does for the AWS CLI
+const EnvConfigFile = "AWS_CONFIG_FILE"
+
+// ErrProfileNotFound means the shared config file has no section for a profile
+var ErrProfileNotFound = errors.New("profile not found")
+
+// SharedConfig is a parsed AWS shared config file. Profiles and sso-session
+// blocks map lower-cased keys to values; sections of other kinds, such as
+// services, are skipped.
+type SharedConfig struct {
+	Path        string
+	profiles    map[string]map[string]string
+	ssoSessions map[string]map[string]string
+}
+
+// AssumeRoleConfig is one role_arn hop in a source_profile chain
+type AssumeRoleConfig struct {
+	Profile         string
+	RoleARN         string
+	ExternalID      string
+	RoleSessionName string
+}
+
+// SSOProfile is a profile resolved down to the SSO settings it ultimately
+// gets credentials from. RoleChain lists the roles to assume with those
+// credentials, innermost first; it is empty for a plain SSO profile.
+type SSOProfile struct {
+	Profile    string
+	Region     string
+	SSOSession string
+	StartURL   string
+	SSORegion  string
+	AccountID  string
+	RoleName   string
+	RoleChain  []AssumeRoleConfig
+}
+
+// SharedConfigPath returns $AWS_CONFIG_FILE, or ~/.aws/config when it is unset
+func SharedConfigPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if path := os.Getenv(EnvConfigFile); path != "" {
+		if path == "~" || strings.HasPrefix(path, "~/") {
+			if err != nil {
+				return "", fmt.Errorf("failed to get home directory: %w", err)
+			}
+			path = filepath.Join(homeDir, path[1:])
+		}
+		return path, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".aws", "config"), nil
+}
+
+// LoadSharedConfig reads and parses the shared config file at path
+func LoadSharedConfig(path string) (*SharedConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("AWS config file not found at %s", path)
+		}
+		return nil, fmt.Errorf("failed to read AWS config: %w", err)
+	}
+	cfg, err := ParseSharedConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse AWS config %s: %w", path, err)
+	}
+	cfg.Path = path
+	return cfg, nil
+}
+
+// ParseSharedConfig parses the INI dialect of the AWS shared config file.
+// It accepts \r\n line endings, full-line comments starting with # or ;,
+// inline comments preceded by whitespace, quoted values, extra whitespace in
+// section headers and indented sub-sections such as s3 settings, which are
+// ignored. Sections that repeat are merged, later keys winning.
+func ParseSharedConfig(r io.Reader) (*SharedConfig, error) {
+	cfg := &SharedConfig{
+		profiles:    make(map[string]map[string]string),
+		ssoSessions: make(map[string]map[string]string),
+	}
+
+	scanner := bufio.NewScanner(r)
+	var section map[string]string
+	inSection := false
+	subSection := false
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed[0] == '#' || trimmed[0] == ';' {
+			continue
+		}
+
+		if trimmed[0] == '[' {
+			header := stripInlineComment(trimmed)
+			if !strings.HasSuffix(header, "]") {
+				return nil, fmt.Errorf("line %d: unterminated section header %q", lineNo, trimmed)
+			}
+			section = cfg.section(strings.Fields(header[1 : len(header)-1]))
+			inSection = true
+			subSection = false
+			continue
+		}
+		if !inSection {
+			return nil, fmt.Errorf("line %d: key outside of a section", lineNo)
+		}
+
+		indented := line[0] == ' ' || line[0] == '\t'
+		if indented && subSection {
+			// A nested setting such as "s3 =\n  max_concurrent_requests = 10"
+			continue
+		}
+		key, value, ok := strings.Cut(trimmed, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value, got %q", lineNo, trimmed)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = parseValue(value)
+		subSection = value == ""
+		if section != nil && key != "" {
+			section[key] = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// section returns the key map for a section header split into words, or
+// nil for kinds of section that are not kept
+func (c *SharedConfig) section(words []string) map[string]string {
+	var sections map[string]map[string]string
+	var name string
+	switch {
+	case len(words) == 1 && words[0] == "default":
+		sections, name = c.profiles, "default"
+	case len(words) == 2 && words[0] == "profile":
+		sections, name = c.profiles, words[1]
+	case len(words) == 2 && words[0] == "sso-session":
+		sections, name = c.ssoSessions, words[1]
+	default:
+		return nil
+	}
+	if sections[name] == nil {
+		sections[name] = make(map[string]string)
+	}
+	return sections[name]
+}
+
+// parseValue trims a raw value, removes a quoted value's quotes and drops an
+// inline comment from an unquoted one
+func parseValue(raw string) string {
+	value := strings.TrimSpace(raw)
+	if len(value) > 0 && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			return value[1 : end+1]
+		}
+	}
+	return stripInlineComment(value)
+}
+
+// stripInlineComment cuts a # or ; comment that follows whitespace, so values
+// such as URLs with fragments survive
+func stripInlineComment(s string) string {
+	for i := 1; i < len(s); i++ {
+		if (s[i] == '#' || s[i] == ';') && (s[i-1] == ' ' || s[i-1] == '\t') {
+			return strings.TrimSpace(s[:i])
+		}
+	}
+	return s
+}
+
+// Profile returns the settings of a profile; "default" is the [default] section
+func (c *SharedConfig) Profile(name string) (map[string]string, bool) {
+	profile, ok := c.profiles[name]
+	return profile, ok
+}
+
+// SSOSession returns the settings of an [sso-session] block
+func (c *SharedConfig) SSOSession(name string) (map[string]string, bool) {
+	session, ok := c.ssoSessions[name]
+	return session, ok
+}
+
+// ResolveSSOProfile follows name through source_profile/role_arn hops to the
+// profile whose SSO settings supply the base credentials, reading the start
+// URL and SSO region from its sso-session block when it has one
+func (c *SharedConfig) ResolveSSOProfile(name string) (*SSOProfile, error) {
+	resolved := &SSOProfile{Profile: name}
+	var chain []AssumeRoleConfig
+	visited := make(map[string]bool)
+
+	current := name
+resolve:
+	for {
+		profile, ok := c.Profile(current)
+		if !ok {
+			if current == name {
+				return nil, fmt.Errorf("%w: %s in %s", ErrProfileNotFound, current, c.Path)
+			}
+			return nil, fmt.Errorf("%w: source_profile %s of %s in %s", ErrProfileNotFound, current, name, c.Path)
+		}
+		if resolved.Region == "" {
+			resolved.Region = profile["region"]
+		}
+		visited[current] = true
+
+		roleARN := profile["role_arn"]
+		if roleARN == "" {
+			break
+		}
+		chain = append(chain, AssumeRoleConfig{
+			Profile:         current,
+			RoleARN:         roleARN,
+			ExternalID:      profile["external_id"],
+			RoleSessionName: profile["role_session_name"],
+		})
+
+		source := profile["source_profile"]
+		switch {
+		case source == "" && profile["credential_source"] != "":
+			return nil, fmt.Errorf("profile %s uses credential_source, which is not supported; use source_profile", current)
+		case source == "":
+			return nil, fmt.Errorf("profile %s sets role_arn without source_profile", current)
+		case source == current:
+			// A profile may assume its role with its own SSO credentials
+			break resolve
+		case visited[source]:
+			return nil, fmt.Errorf("source_profile loop through profile %s", source)
+		}
+		current = source
+	}
+
+	profile := c.profiles[current]
+	resolved.AccountID = profile["sso_account_id"]
+	resolved.RoleName = profile["sso_role_name"]
+	resolved.StartURL = profile["sso_start_url"]
+	resolved.SSORegion = profile["sso_region"]
+	if sessionName := profile["sso_session"]; sessionName != "" {
+		session, ok := c.SSOSession(sessionName)
+		if !ok {
+			return nil, fmt.Errorf("sso-session %s of profile %s not found in %s", sessionName, current, c.Path)
+		}
+		resolved.SSOSession = sessionName
+		if session["sso_start_url"] != "" {
+			resolved.StartURL = session["sso_start_url"]
+		}
+		if session["sso_region"] != "" {
+			resolved.SSORegion = session["sso_region"]
+		}
+	}
+	// The hops were collected outermost first; credentials flow the other way
+	for i := len(chain) - 1; i >= 0; i-- {
+		resolved.RoleChain = append(resolved.RoleChain, chain[i])
+	}
+
+	if resolved.StartURL == "" || resolved.AccountID == "" || resolved.RoleName == "" {
+		return nil, fmt.Errorf("incomplete SSO configuration for profile %s (start_url: %s, account_id: %s, role_name: %s)",
+			current, resolved.StartURL, resolved.AccountID, resolved.RoleName)
+	}
+	return resolved, nil
+}
@@ -0,0 +1,242 @@
+package aws
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSharedConfig(t *testing.T) {
+	content := "\ufeff# leading comment\r\n" +
+		"; another comment\r\n" +
+		"[default]\r\n" +
+		"region = us-east-1\r\n" +
+		"\r\n" +
+		"[ profile   spaced ]   # comment after header\r\n" +
+		"Region=eu-west-1\r\n" +
+		"sso_start_url = \"https://spaced.awsapps.com/start\"\r\n" +
+		"sso_role_name = 'Quoted # not a comment'\r\n" +
+		"sso_account_id = 123456789012 ; trailing comment\r\n" +
+		"s3 =\r\n" +
+		"  max_concurrent_requests = 20\r\n" +
+		"  region = ignored\r\n" +
+		"output = json\r\n" +
+		"[services local]\r\n" +
+		"dynamodb =\r\n" +
+		"  endpoint_url = http://localhost:8000\r\n" +
+		"[sso-session corp]\r\n" +
+		"sso_start_url = https://corp.awsapps.com/start#fragment\r\n" +
+		"[profile spaced]\r\n" +
+		"output = text\r\n"
+
+	cfg, err := ParseSharedConfig(strings.NewReader(content))
+	require.NoError(t, err)
+
+	defaults, ok := cfg.Profile("default")
+	require.True(t, ok)
+	assert.Equal(t, "us-east-1", defaults["region"])
+
+	spaced, ok := cfg.Profile("spaced")
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{
+		"region":         "eu-west-1",
+		"sso_start_url":  "https://spaced.awsapps.com/start",
+		"sso_role_name":  "Quoted # not a comment",
+		"sso_account_id": "123456789012",
+		"s3":             "",
+		"output":         "text",
+	}, spaced)
+
+	session, ok := cfg.SSOSession("corp")
+	require.True(t, ok)
+	assert.Equal(t, "https://corp.awsapps.com/start#fragment", session["sso_start_url"])
+
+	_, ok = cfg.Profile("local")
+	assert.False(t, ok, "services sections are not profiles")
+}
+
+func TestParseSharedConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "key_outside_section", content: "region = us-east-1\n", wantErr: "line 1: key outside of a section"},
+		{name: "unterminated_header", content: "[profile broken\nregion = us-east-1\n", wantErr: "line 1: unterminated section header"},
+		{name: "missing_equals", content: "[default]\nregion\n", wantErr: "line 2: expected key = value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSharedConfig(strings.NewReader(tt.content))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestSharedConfigPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	t.Setenv(EnvConfigFile, "")
+	path, err := SharedConfigPath()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, ".aws", "config"), path)
+
+	t.Setenv(EnvConfigFile, "/etc/aws/config")
+	path, err = SharedConfigPath()
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/aws/config", path)
+
+	t.Setenv(EnvConfigFile, "~/team/aws-config")
+	path, err = SharedConfigPath()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, "team", "aws-config"), path)
+}
+
+func TestLoadSharedConfig_NotFound(t *testing.T) {
+	_, err := LoadSharedConfig(filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorContains(t, err, "AWS config file not found")
+}
+
+func TestSharedConfig_ResolveSSOProfile(t *testing.T) {
+	const content = `
+[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = eu-west-1
+
+[profile sso]
+sso_session = corp
+sso_account_id = 123456789012
+sso_role_name = Base
+region = eu-west-1
+
+[profile legacy]
+sso_start_url = https://legacy.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 210987654321
+sso_role_name = Legacy
+
+[profile ops]
+role_arn = arn:aws:iam::111111111111:role/Ops
+source_profile = sso
+external_id = ops-external
+region = us-west-2
+
+[profile admin]
+role_arn = arn:aws:iam::222222222222:role/Admin
+source_profile = ops
+role_session_name = admin-session
+
+[profile self]
+role_arn = arn:aws:iam::333333333333:role/Self
+source_profile = self
+sso_session = corp
+sso_account_id = 123456789012
+sso_role_name = Base
+
+[profile loop-a]
+role_arn = arn:aws:iam::444444444444:role/A
+source_profile = loop-b
+
+[profile loop-b]
+role_arn = arn:aws:iam::444444444444:role/B
+source_profile = loop-a
+
+[profile instance]
+role_arn = arn:aws:iam::555555555555:role/Instance
+credential_source = Ec2InstanceMetadata
+
+[profile dangling]
+role_arn = arn:aws:iam::666666666666:role/Dangling
+source_profile = missing
+
+[profile no-session]
+sso_session = missing
+sso_account_id = 123456789012
+sso_role_name = Base
+`
+	cfg, err := ParseSharedConfig(strings.NewReader(content))
+	require.NoError(t, err)
+	cfg.Path = "config"
+
+	tests := []struct {
+		name    string
+		profile string
+		want    *SSOProfile
+		wantErr string
+	}{
+		{
+			name:    "sso_session",
+			profile: "sso",
+			want: &SSOProfile{
+				Profile: "sso", Region: "eu-west-1", SSOSession: "corp",
+				StartURL: "https://corp.awsapps.com/start", SSORegion: "eu-west-1",
+				AccountID: "123456789012", RoleName: "Base",
+			},
+		},
+		{
+			name:    "legacy",
+			profile: "legacy",
+			want: &SSOProfile{
+				Profile:  "legacy",
+				StartURL: "https://legacy.awsapps.com/start", SSORegion: "us-east-1",
+				AccountID: "210987654321", RoleName: "Legacy",
+			},
+		},
+		{
+			name:    "two_hop_chain",
+			profile: "admin",
+			want: &SSOProfile{
+				Profile: "admin", Region: "us-west-2", SSOSession: "corp",
+				StartURL: "https://corp.awsapps.com/start", SSORegion: "eu-west-1",
+				AccountID: "123456789012", RoleName: "Base",
+				RoleChain: []AssumeRoleConfig{
+					{Profile: "ops", RoleARN: "arn:aws:iam::111111111111:role/Ops", ExternalID: "ops-external"},
+					{Profile: "admin", RoleARN: "arn:aws:iam::222222222222:role/Admin", RoleSessionName: "admin-session"},
+				},
+			},
+		},
+		{
+			name:    "self_sourced",
+			profile: "self",
+			want: &SSOProfile{
+				Profile: "self", SSOSession: "corp",
+				StartURL: "https://corp.awsapps.com/start", SSORegion: "eu-west-1",
+				AccountID: "123456789012", RoleName: "Base",
+				RoleChain: []AssumeRoleConfig{{Profile: "self", RoleARN: "arn:aws:iam::333333333333:role/Self"}},
+			},
+		},
+		{name: "missing", profile: "nope", wantErr: "profile not found: nope in config"},
+		{name: "loop", profile: "loop-a", wantErr: "source_profile loop through profile loop-a"},
+		{name: "credential_source", profile: "instance", wantErr: "credential_source, which is not supported"},
+		{name: "dangling_source", profile: "dangling", wantErr: "profile not found: source_profile missing of dangling"},
+		{name: "missing_session", profile: "no-session", wantErr: "sso-session missing of profile no-session not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cfg.ResolveSSOProfile(tt.profile)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSharedConfig_ResolveSSOProfileNotFound(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte("[profile other]\nregion = us-east-1\n"), 0644))
+	cfg, err := LoadSharedConfig(path)
+	require.NoError(t, err)
+
+	_, err = cfg.ResolveSSOProfile("work")
+	assert.ErrorIs(t, err, ErrProfileNotFound)
+}
@@ -0,0 +1,120 @@
+package aws
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// tokenExpiryWindow is how long before its expiry a cached access token or
+// client registration stops being reused, so it cannot lapse mid-request
+const tokenExpiryWindow = 5 * time.Minute
+
+// ssoTokenCache is an entry in ~/.aws/sso/cache, in the format the AWS CLI and
+// SDKs share. Besides the access token it carries the OIDC client
+// registration and refresh token, so a session can be renewed without
+// registering a new client or prompting the user.
+type ssoTokenCache struct {
+	StartURL              string     `json:"startUrl"`
+	Region                string     `json:"region"`
+	AccessToken           string     `json:"accessToken"`
+	ExpiresAt             time.Time  `json:"expiresAt"`
+	ClientID              string     `json:"clientId,omitempty"`
+	ClientSecret          string     `json:"clientSecret,omitempty"`
+	RegistrationExpiresAt *time.Time `json:"registrationExpiresAt,omitempty"`
+	RefreshToken          string     `json:"refreshToken,omitempty"`
+}
+
+// accessTokenValid reports whether the access token can still be used at now
+func (t *ssoTokenCache) accessTokenValid(now time.Time) bool {
+	return t.AccessToken != "" && now.Add(tokenExpiryWindow).Before(t.ExpiresAt)
+}
+
+// registrationValid reports whether the client registration can still be
+// used at now
+func (t *ssoTokenCache) registrationValid(now time.Time) bool {
+	return t.ClientID != "" && t.ClientSecret != "" &&
+		t.RegistrationExpiresAt != nil && now.Add(tokenExpiryWindow).Before(*t.RegistrationExpiresAt)
+}
+
+// canRefresh reports whether the access token can be renewed silently at now
+func (t *ssoTokenCache) canRefresh(now time.Time) bool {
+	return t.RefreshToken != "" && t.registrationValid(now)
+}
+
+// tokenCachePath returns where the SSO token for this client is cached. Like
+// the AWS CLI, profiles that use an sso-session are keyed by the session name
+// and legacy profiles by the start URL.
+func (c *SSOClient) tokenCachePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	key := c.startURL
+	if c.ssoSession != "" {
+		key = c.ssoSession
+	}
+	return filepath.Join(homeDir, ".aws", "sso", "cache", fmt.Sprintf("%x.json", sha1.Sum([]byte(key)))), nil
+}
+
+// loadSSOToken reads the cached SSO token. It returns nil without an error
+// when there is no cache entry, or the entry belongs to another start URL.
+func (c *SSOClient) loadSSOToken() (*ssoTokenCache, error) {
+	cachePath, err := c.tokenCachePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read SSO token cache: %w", err)
+	}
+
+	var token ssoTokenCache
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("failed to parse SSO token cache %s: %w", cachePath, err)
+	}
+	if token.StartURL != "" && token.StartURL != c.startURL {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+func (c *SSOClient) storeSSOToken(token *ssoTokenCache) error {
+	if token == nil || token.AccessToken == "" {
+		return fmt.Errorf("invalid token data")
+	}
+
+	cachePath, err := c.tokenCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0700); err != nil {
+		return fmt.Errorf("failed to create SSO cache directory: %w", err)
+	}
+
+	token.StartURL = c.startURL
+	token.Region = c.ssoServiceRegion()
+	token.ExpiresAt = token.ExpiresAt.UTC().Truncate(time.Second)
+	if token.RegistrationExpiresAt != nil {
+		registrationExpiresAt := token.RegistrationExpiresAt.UTC().Truncate(time.Second)
+		token.RegistrationExpiresAt = &registrationExpiresAt
+	}
+
+	data, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal SSO token: %w", err)
+	}
+
+	if err := os.WriteFile(cachePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write SSO token cache: %w", err)
+	}
+
+	return nil
+}
@@ -2,8 +2,8 @@ package aws
 
 import (
 	"context"
-	"crypto/sha1"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -15,8 +15,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/sso"
 	"github.com/aws/aws-sdk-go-v2/service/sso/types"
 	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	oidctypes "github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
-	"github.com/pkg/browser"
 )
 
 type CredentialStatus int
@@ -28,14 +28,42 @@ const (
 	CredentialStatusError
 )
 
+// ssoAPI is the subset of the SSO API used by SSOClient, so tests can substitute a fake
+type ssoAPI interface {
+	GetRoleCredentials(ctx context.Context, params *sso.GetRoleCredentialsInput, optFns ...func(*sso.Options)) (*sso.GetRoleCredentialsOutput, error)
+}
+
+// stsAPI is the subset of the STS API used to follow a role_arn chain
+type stsAPI interface {
+	AssumeRole(ctx context.Context, params *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error)
+}
+
+// oidcAPI is the subset of the SSO OIDC API used by the device login flow
+type oidcAPI interface {
+	RegisterClient(ctx context.Context, params *ssooidc.RegisterClientInput, optFns ...func(*ssooidc.Options)) (*ssooidc.RegisterClientOutput, error)
+	StartDeviceAuthorization(ctx context.Context, params *ssooidc.StartDeviceAuthorizationInput, optFns ...func(*ssooidc.Options)) (*ssooidc.StartDeviceAuthorizationOutput, error)
+	CreateToken(ctx context.Context, params *ssooidc.CreateTokenInput, optFns ...func(*ssooidc.Options)) (*ssooidc.CreateTokenOutput, error)
+}
+
 type SSOClient struct {
 	profile    string
 	region     string
 	startURL   string
 	roleName   string
 	accountID  string
-	ssoClient  *sso.Client
-	oidcClient *ssooidc.Client
+	ssoSession string
+	ssoRegion  string
+	roleChain  []AssumeRoleConfig
+	ssoClient  ssoAPI
+	oidcClient oidcAPI
+	// newSTS returns the client that assumes the roles in roleChain
+	newSTS func(aws.Config) stsAPI
+
+	// Login controls how InitiateLoginFlow reaches a human
+	Login LoginOptions
+	// pollUnit scales the polling interval, which the service gives in
+	// seconds; tests shrink it
+	pollUnit time.Duration
 }
 
 type SSOConfig struct {
@@ -63,9 +91,6 @@ func NewSSOClient(profile, region string) (*SSOClient, error) {
 
 	if region == "" {
 		region = os.Getenv("AWS_REGION")
-		if region == "" {
-			region = "us-east-1"
-		}
 	}
 
 	client := &SSOClient{
@@ -76,15 +101,18 @@ func NewSSOClient(profile, region string) (*SSOClient, error) {
 	if err := client.LoadSSOConfig(); err != nil {
 		return nil, fmt.Errorf("failed to load SSO config: %w", err)
 	}
+	if client.region == "" {
+		client.region = "us-east-1"
+	}
 
 	// Create a basic config for OIDC operations (doesn't require credentials)
 	cfg, err := config.LoadDefaultConfig(context.Background(),
-		config.WithRegion(client.region),
+		config.WithRegion(client.ssoServiceRegion()),
 	)
 	if err != nil {
 		// Even if we can't load config, we can still try to create clients with minimal config
 		cfg = aws.Config{
-			Region: client.region,
+			Region: client.ssoServiceRegion(),
 		}
 	}
 
@@ -94,117 +122,40 @@ func NewSSOClient(profile, region string) (*SSOClient, error) {
 	return client, nil
 }
 
-func (c *SSOClient) LoadSSOConfig() error {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("failed to get home directory: %w", err)
+// ssoServiceRegion is where the SSO and OIDC endpoints live: sso_region when
+// the profile or its sso-session sets one, the client's region otherwise
+func (c *SSOClient) ssoServiceRegion() string {
+	if c.ssoRegion != "" {
+		return c.ssoRegion
 	}
+	return c.region
+}
 
-	configPath := filepath.Join(homeDir, ".aws", "config")
-	data, err := os.ReadFile(configPath)
+// LoadSSOConfig resolves the client's profile from the shared config file,
+// following sso-session blocks and source_profile/role_arn chains
+func (c *SSOClient) LoadSSOConfig() error {
+	configPath, err := SharedConfigPath()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("AWS config file not found at %s", configPath)
-		}
-		return fmt.Errorf("failed to read AWS config: %w", err)
-	}
-
-	// Parse AWS config file (simplified parsing for SSO profile)
-	// In production, use a proper INI parser
-	profileSection := fmt.Sprintf("[profile %s]", c.profile)
-	if c.profile == "default" {
-		profileSection = "[default]"
-	}
-
-	content := string(data)
-	profileStart := -1
-	for i, line := range splitLines(content) {
-		if line == profileSection {
-			profileStart = i
-			break
-		}
-	}
-
-	if profileStart == -1 {
-		return fmt.Errorf("profile %s not found in AWS config", c.profile)
+		return err
 	}
-
-	// Extract SSO configuration from profile
-	lines := splitLines(content)
-	var ssoSessionName string
-	for i := profileStart + 1; i < len(lines); i++ {
-		line := lines[i]
-		if line == "" {
-			continue
-		}
-		if line[0] == '[' {
-			break // Next profile section
-		}
-
-		if kv := parseConfigLine(line); kv != nil {
-			switch kv[0] {
-			case "sso_session":
-				ssoSessionName = kv[1]
-			case "sso_start_url":
-				c.startURL = kv[1]
-			case "sso_region":
-				if c.region == "" {
-					c.region = kv[1]
-				}
-			case "sso_account_id":
-				c.accountID = kv[1]
-			case "sso_role_name":
-				c.roleName = kv[1]
-			case "region":
-				if c.region == "" {
-					c.region = kv[1]
-				}
-			}
-		}
+	sharedConfig, err := LoadSharedConfig(configPath)
+	if err != nil {
+		return err
 	}
-
-	// If using sso-session, look for the session configuration
-	if ssoSessionName != "" {
-		sessionSection := fmt.Sprintf("[sso-session %s]", ssoSessionName)
-		sessionStart := -1
-		for i, line := range lines {
-			if line == sessionSection {
-				sessionStart = i
-				break
-			}
-		}
-
-		if sessionStart != -1 {
-			for i := sessionStart + 1; i < len(lines); i++ {
-				line := lines[i]
-				if line == "" {
-					continue
-				}
-				if line[0] == '[' {
-					break // Next section
-				}
-
-				if kv := parseConfigLine(line); kv != nil {
-					switch kv[0] {
-					case "sso_start_url":
-						if c.startURL == "" {
-							c.startURL = kv[1]
-						}
-					case "sso_region":
-						if c.region == "" {
-							c.region = kv[1]
-						}
-					}
-				}
-			}
-		}
+	profile, err := sharedConfig.ResolveSSOProfile(c.profile)
+	if err != nil {
+		return err
 	}
 
-	if c.startURL == "" || c.accountID == "" || c.roleName == "" {
-		return fmt.Errorf("incomplete SSO configuration for profile %s (start_url: %s, account_id: %s, role_name: %s)",
-			c.profile, c.startURL, c.accountID, c.roleName)
+	c.startURL = profile.StartURL
+	c.ssoRegion = profile.SSORegion
+	c.ssoSession = profile.SSOSession
+	c.accountID = profile.AccountID
+	c.roleName = profile.RoleName
+	c.roleChain = profile.RoleChain
+	if c.region == "" {
+		c.region = profile.Region
 	}
-
 	return nil
 }
 
@@ -244,23 +195,48 @@ func (c *SSOClient) CheckCredentialStatus(ctx context.Context) (CredentialStatus
 	return CredentialStatusValid, nil
 }
 
+// InitiateLoginFlow renews the SSO session, prompting only when it has to.
+// A valid cached access token is used to fetch role credentials directly, and
+// an expired one is refreshed silently while the cached refresh token and
+// client registration last. Otherwise it runs the OIDC device authorization
+// flow, reusing the cached client registration when it has not expired: the
+// verification URL and code go to c.Login.Notify, then the token endpoint is
+// polled at the interval the service asks for, backing off on slow_down,
+// until the code is approved, expires, c.Login.Timeout passes or ctx is
+// cancelled. Failures that need a human to act match ErrLoginRequired.
 func (c *SSOClient) InitiateLoginFlow(ctx context.Context) (*SSOAuthResult, error) {
-	// Register client for device authorization
-	registerResp, err := c.oidcClient.RegisterClient(ctx, &ssooidc.RegisterClientInput{
-		ClientName: aws.String("chi-chi-moni-cli"),
-		ClientType: aws.String("public"),
-	})
+	token, err := c.loadSSOToken()
 	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring cached SSO token: %v\n", err)
+		token = nil
+	}
+	if token != nil {
+		if result, ok := c.loginFromCache(ctx, token); ok {
+			return result, nil
+		}
+	}
+
+	if c.Login.Disabled {
 		return &SSOAuthResult{
 			Success: false,
-			Error:   fmt.Errorf("failed to register client: %w", err),
+			Error:   fmt.Errorf("%w: credentials for profile %s are missing or expired and interactive login is disabled", ErrLoginRequired, c.profile),
 		}, nil
 	}
 
+	if token == nil || !token.registrationValid(time.Now()) {
+		token, err = c.registerClient(ctx)
+		if err != nil {
+			return &SSOAuthResult{
+				Success: false,
+				Error:   err,
+			}, nil
+		}
+	}
+
 	// Start device authorization
 	startResp, err := c.oidcClient.StartDeviceAuthorization(ctx, &ssooidc.StartDeviceAuthorizationInput{
-		ClientId:     registerResp.ClientId,
-		ClientSecret: registerResp.ClientSecret,
+		ClientId:     aws.String(token.ClientID),
+		ClientSecret: aws.String(token.ClientSecret),
 		StartUrl:     aws.String(c.startURL),
 	})
 	if err != nil {
@@ -270,88 +246,262 @@ func (c *SSOClient) InitiateLoginFlow(ctx context.Context) (*SSOAuthResult, erro
 		}, nil
 	}
 
-	// Open browser for user authentication
-	fmt.Printf("Opening browser for SSO authentication...\n")
-	fmt.Printf("Verification URL: %s\n", *startResp.VerificationUriComplete)
-	fmt.Printf("User Code: %s\n", *startResp.UserCode)
-
-	if err := browser.OpenURL(*startResp.VerificationUriComplete); err != nil {
-		fmt.Printf("Failed to open browser automatically. Please visit the URL manually.\n")
+	auth := DeviceAuthorization{
+		Profile:                 c.profile,
+		VerificationURI:         aws.ToString(startResp.VerificationUri),
+		VerificationURIComplete: aws.ToString(startResp.VerificationUriComplete),
+		UserCode:                aws.ToString(startResp.UserCode),
+		ExpiresAt:               time.Now().Add(time.Duration(startResp.ExpiresIn) * time.Second),
+	}
+	notify := c.Login.Notify
+	if notify == nil {
+		notify = BrowserNotifier(os.Stdout)
+	}
+	if err := notify(ctx, auth); err != nil {
+		return &SSOAuthResult{
+			Success: false,
+			Error:   fmt.Errorf("%w: failed to deliver the login prompt: %w", ErrLoginRequired, err),
+		}, nil
 	}
 
-	// Poll for authorization completion
-	fmt.Println("Waiting for authorization...")
+	tokenResp, err := c.pollForToken(ctx, token, startResp, auth.ExpiresAt)
+	if err != nil {
+		return &SSOAuthResult{
+			Success: false,
+			Error:   err,
+		}, nil
+	}
 
-	interval := time.Duration(startResp.Interval) * time.Second
-	expiresAt := time.Now().Add(time.Duration(startResp.ExpiresIn) * time.Second)
+	// Store SSO access token first
+	c.updateSSOToken(token, tokenResp)
 
-	for time.Now().Before(expiresAt) {
-		time.Sleep(interval)
+	return c.loginWithToken(ctx, token.AccessToken), nil
+}
 
-		tokenResp, err := c.oidcClient.CreateToken(ctx, &ssooidc.CreateTokenInput{
-			ClientId:     registerResp.ClientId,
-			ClientSecret: registerResp.ClientSecret,
-			DeviceCode:   startResp.DeviceCode,
-			GrantType:    aws.String("urn:ietf:params:oauth:grant-type:device_code"),
-		})
+// loginFromCache tries to get role credentials without a prompt, using the
+// cached access token while it is valid and the refresh token once it is not.
+// It reports false when only a device login can help; any other failure is
+// returned as the result.
+func (c *SSOClient) loginFromCache(ctx context.Context, token *ssoTokenCache) (*SSOAuthResult, bool) {
+	if token.accessTokenValid(time.Now()) {
+		result := c.loginWithToken(ctx, token.AccessToken)
+		var unauthorized *types.UnauthorizedException
+		if result.Success || !errors.As(result.Error, &unauthorized) {
+			return result, true
+		}
+		// The token was revoked before it expired; try to refresh it
+	}
+	if !token.canRefresh(time.Now()) {
+		return nil, false
+	}
 
-		if err != nil {
-			if contains(err.Error(), "AuthorizationPending") {
-				continue
-			}
+	tokenResp, err := c.oidcClient.CreateToken(ctx, &ssooidc.CreateTokenInput{
+		ClientId:     aws.String(token.ClientID),
+		ClientSecret: aws.String(token.ClientSecret),
+		RefreshToken: aws.String(token.RefreshToken),
+		GrantType:    aws.String("refresh_token"),
+	})
+	if err != nil {
+		if ctx.Err() != nil {
 			return &SSOAuthResult{
 				Success: false,
-				Error:   fmt.Errorf("failed to create token: %w", err),
-			}, nil
+				Error:   fmt.Errorf("SSO token refresh cancelled: %w", ctx.Err()),
+			}, true
 		}
+		// The refresh token was rejected or has lapsed, so the user has to log in again
+		return nil, false
+	}
+	c.updateSSOToken(token, tokenResp)
+
+	return c.loginWithToken(ctx, token.AccessToken), true
+}
 
-		// Store SSO access token first
-		expiresIn := tokenResp.ExpiresIn
-		if err := c.storeSSOToken(tokenResp.AccessToken, &expiresIn); err != nil {
-			fmt.Printf("Warning: failed to cache SSO token: %v\n", err)
+// registerClient registers a new OIDC client for the device login. The
+// refresh_token grant lets later sessions be renewed without a prompt.
+func (c *SSOClient) registerClient(ctx context.Context) (*ssoTokenCache, error) {
+	registerResp, err := c.oidcClient.RegisterClient(ctx, &ssooidc.RegisterClientInput{
+		ClientName: aws.String("chi-chi-moni-cli"),
+		ClientType: aws.String("public"),
+		GrantTypes: []string{"urn:ietf:params:oauth:grant-type:device_code", "refresh_token"},
+		Scopes:     []string{"sso:account:access"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register client: %w", err)
+	}
+
+	token := &ssoTokenCache{
+		ClientID:     aws.ToString(registerResp.ClientId),
+		ClientSecret: aws.ToString(registerResp.ClientSecret),
+	}
+	if registerResp.ClientSecretExpiresAt > 0 {
+		registrationExpiresAt := time.Unix(registerResp.ClientSecretExpiresAt, 0)
+		token.RegistrationExpiresAt = &registrationExpiresAt
+	}
+	return token, nil
+}
+
+// updateSSOToken copies a new access token, and refresh token when the
+// service rotates it, into token and caches it. Failing to cache only costs
+// a prompt next time, so it is reported as a warning.
+func (c *SSOClient) updateSSOToken(token *ssoTokenCache, tokenResp *ssooidc.CreateTokenOutput) {
+	token.AccessToken = aws.ToString(tokenResp.AccessToken)
+	token.ExpiresAt = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	if tokenResp.RefreshToken != nil {
+		token.RefreshToken = aws.ToString(tokenResp.RefreshToken)
+	}
+	if err := c.storeSSOToken(token); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to cache SSO token: %v\n", err)
+	}
+}
+
+// loginWithToken exchanges an SSO access token for role credentials, caches
+// them and returns a config that uses them
+func (c *SSOClient) loginWithToken(ctx context.Context, accessToken string) *SSOAuthResult {
+	// Get role credentials
+	roleResp, err := c.ssoClient.GetRoleCredentials(ctx, &sso.GetRoleCredentialsInput{
+		RoleName:    aws.String(c.roleName),
+		AccountId:   aws.String(c.accountID),
+		AccessToken: aws.String(accessToken),
+	})
+	if err != nil {
+		return &SSOAuthResult{
+			Success: false,
+			Error:   fmt.Errorf("failed to get role credentials: %w", err),
 		}
+	}
 
-		// Get role credentials
-		roleResp, err := c.ssoClient.GetRoleCredentials(ctx, &sso.GetRoleCredentialsInput{
-			RoleName:    aws.String(c.roleName),
-			AccountId:   aws.String(c.accountID),
-			AccessToken: tokenResp.AccessToken,
-		})
-		if err != nil {
-			return &SSOAuthResult{
-				Success: false,
-				Error:   fmt.Errorf("failed to get role credentials: %w", err),
-			}, nil
+	// Store credentials in cache
+	if err := c.storeCachedCredentials(roleResp); err != nil {
+		return &SSOAuthResult{
+			Success: false,
+			Error:   fmt.Errorf("failed to cache credentials: %w", err),
 		}
+	}
 
-		// Store credentials in cache
-		if err := c.storeCachedCredentials(roleResp); err != nil {
-			return &SSOAuthResult{
-				Success: false,
-				Error:   fmt.Errorf("failed to cache credentials: %w", err),
-			}, nil
+	// Create new config with the fresh credentials
+	cfg, err := c.CreateConfigWithCredentials(ctx, roleResp.RoleCredentials)
+	if err != nil {
+		return &SSOAuthResult{
+			Success: false,
+			Error:   err,
 		}
+	}
+	expiresAt := time.UnixMilli(roleResp.RoleCredentials.Expiration)
 
-		// Create new config with the fresh credentials
-		cfg, err := c.CreateConfigWithCredentials(ctx, roleResp.RoleCredentials)
+	// Follow the profile's source_profile chain, if any
+	for _, role := range c.roleChain {
+		cfg, expiresAt, err = c.assumeRole(ctx, cfg, role)
 		if err != nil {
 			return &SSOAuthResult{
 				Success: false,
 				Error:   err,
-			}, nil
+			}
 		}
-
-		return &SSOAuthResult{
-			Success:   true,
-			Config:    cfg,
-			ExpiresAt: time.UnixMilli(roleResp.RoleCredentials.Expiration),
-		}, nil
 	}
 
 	return &SSOAuthResult{
-		Success: false,
-		Error:   fmt.Errorf("device authorization expired"),
-	}, nil
+		Success:   true,
+		Config:    cfg,
+		ExpiresAt: expiresAt,
+	}
+}
+
+// assumeRole assumes one role of a source_profile chain with the credentials
+// in cfg and returns a config that uses the role's credentials
+func (c *SSOClient) assumeRole(ctx context.Context, cfg aws.Config, role AssumeRoleConfig) (aws.Config, time.Time, error) {
+	newSTS := c.newSTS
+	if newSTS == nil {
+		newSTS = func(cfg aws.Config) stsAPI { return sts.NewFromConfig(cfg) }
+	}
+	sessionName := role.RoleSessionName
+	if sessionName == "" {
+		sessionName = fmt.Sprintf("chi-chi-moni-%d", time.Now().Unix())
+	}
+	input := &sts.AssumeRoleInput{
+		RoleArn:         aws.String(role.RoleARN),
+		RoleSessionName: aws.String(sessionName),
+	}
+	if role.ExternalID != "" {
+		input.ExternalId = aws.String(role.ExternalID)
+	}
+
+	resp, err := newSTS(cfg).AssumeRole(ctx, input)
+	if err != nil {
+		return aws.Config{}, time.Time{}, fmt.Errorf("failed to assume role %s for profile %s: %w", role.RoleARN, role.Profile, err)
+	}
+	if resp.Credentials == nil {
+		return aws.Config{}, time.Time{}, fmt.Errorf("assuming role %s returned no credentials", role.RoleARN)
+	}
+
+	roleCfg, err := c.CreateConfigWithCredentials(ctx, &types.RoleCredentials{
+		AccessKeyId:     resp.Credentials.AccessKeyId,
+		SecretAccessKey: resp.Credentials.SecretAccessKey,
+		SessionToken:    resp.Credentials.SessionToken,
+	})
+	if err != nil {
+		return aws.Config{}, time.Time{}, err
+	}
+	return roleCfg, aws.ToTime(resp.Credentials.Expiration), nil
+}
+
+// pollForToken waits for the user to approve the device code. It sleeps
+// before every attempt, adds slowDownStep to the interval on each slow_down
+// as RFC 8628 requires, and gives up once the next attempt would fall after
+// the code's expiry or c.Login.Timeout.
+func (c *SSOClient) pollForToken(ctx context.Context, token *ssoTokenCache, startResp *ssooidc.StartDeviceAuthorizationOutput, expiresAt time.Time) (*ssooidc.CreateTokenOutput, error) {
+	unit := c.pollUnit
+	if unit <= 0 {
+		unit = time.Second
+	}
+	interval := time.Duration(startResp.Interval) * unit
+	if interval <= 0 {
+		interval = defaultPollInterval * unit
+	}
+	deadline := expiresAt
+	if c.Login.Timeout > 0 {
+		if timeout := time.Now().Add(c.Login.Timeout); timeout.Before(deadline) {
+			deadline = timeout
+		}
+	}
+
+	for {
+		if time.Now().Add(interval).After(deadline) {
+			return nil, ErrLoginTimeout
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("SSO login cancelled: %w", ctx.Err())
+		case <-timer.C:
+		}
+
+		tokenResp, err := c.oidcClient.CreateToken(ctx, &ssooidc.CreateTokenInput{
+			ClientId:     aws.String(token.ClientID),
+			ClientSecret: aws.String(token.ClientSecret),
+			DeviceCode:   startResp.DeviceCode,
+			GrantType:    aws.String("urn:ietf:params:oauth:grant-type:device_code"),
+		})
+		var pending *oidctypes.AuthorizationPendingException
+		var slowDown *oidctypes.SlowDownException
+		var expired *oidctypes.ExpiredTokenException
+		var denied *oidctypes.AccessDeniedException
+		switch {
+		case err == nil:
+			return tokenResp, nil
+		case errors.As(err, &pending):
+		case errors.As(err, &slowDown):
+			interval += slowDownStep * unit
+		case errors.As(err, &expired):
+			return nil, ErrLoginTimeout
+		case errors.As(err, &denied):
+			return nil, ErrLoginDenied
+		case ctx.Err() != nil:
+			return nil, fmt.Errorf("SSO login cancelled: %w", ctx.Err())
+		default:
+			return nil, fmt.Errorf("failed to create token: %w", err)
+		}
+	}
 }
 
 func (c *SSOClient) CreateConfigWithSSO(ctx context.Context) (aws.Config, error) {
@@ -389,54 +539,6 @@ func (c *SSOClient) CreateConfigWithCredentials(ctx context.Context, roleCreds *
 	return cfg, nil
 }
 
-func (c *SSOClient) storeSSOToken(accessToken *string, expiresIn *int32) error {
-	if accessToken == nil || expiresIn == nil {
-		return fmt.Errorf("invalid token data")
-	}
-
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("failed to get home directory: %w", err)
-	}
-
-	// Create SSO cache directory
-	ssoDir := filepath.Join(homeDir, ".aws", "sso", "cache")
-	if err := os.MkdirAll(ssoDir, 0700); err != nil {
-		return fmt.Errorf("failed to create SSO cache directory: %w", err)
-	}
-
-	// Calculate expiration time
-	expiresAt := time.Now().Add(time.Duration(*expiresIn) * time.Second)
-
-	// Create SSO token cache structure
-	tokenCache := map[string]interface{}{
-		"startUrl":    c.startURL,
-		"region":      c.region,
-		"accessToken": *accessToken,
-		"expiresAt":   expiresAt.Format(time.RFC3339),
-	}
-
-	// Generate cache filename using SHA1 hash of the start URL
-	hasher := sha1.New()
-	hasher.Write([]byte(c.startURL))
-	hash := fmt.Sprintf("%x", hasher.Sum(nil))
-	cacheFile := fmt.Sprintf("%s.json", hash)
-	cachePath := filepath.Join(ssoDir, cacheFile)
-
-	// Marshal and write the token cache
-	data, err := json.MarshalIndent(tokenCache, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal SSO token: %w", err)
-	}
-
-	if err := os.WriteFile(cachePath, data, 0600); err != nil {
-		return fmt.Errorf("failed to write SSO token cache: %w", err)
-	}
-
-	fmt.Printf("SSO token cached successfully at: %s\n", cachePath)
-	return nil
-}
-
 func (c *SSOClient) storeCachedCredentials(creds *sso.GetRoleCredentialsOutput) error {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -476,44 +578,6 @@ func (c *SSOClient) storeCachedCredentials(creds *sso.GetRoleCredentialsOutput)
 }
 
 // Helper functions
-func splitLines(s string) []string {
-	var lines []string
-	start := 0
-	for i := 0; i < len(s); i++ {
-		if s[i] == '\n' {
-			lines = append(lines, s[start:i])
-			start = i + 1
-		}
-	}
-	if start < len(s) {
-		lines = append(lines, s[start:])
-	}
-	return lines
-}
-
-func parseConfigLine(line string) []string {
-	for i := 0; i < len(line); i++ {
-		if line[i] == '=' {
-			key := trim(line[:i])
-			value := trim(line[i+1:])
-			return []string{key, value}
-		}
-	}
-	return nil
-}
-
-func trim(s string) string {
-	start := 0
-	end := len(s)
-	for start < end && (s[start] == ' ' || s[start] == '\t') {
-		start++
-	}
-	for end > start && (s[end-1] == ' ' || s[end-1] == '\t') {
-		end--
-	}
-	return s[start:end]
-}
-
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && containsHelper(s, substr)
 }
@@ -0,0 +1,111 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/pkg/browser"
+)
+
+const (
+	// defaultPollInterval is used when the service does not give one, in seconds
+	defaultPollInterval = 5
+	// slowDownStep is added to the polling interval on each slow_down, in seconds
+	slowDownStep = 5
+)
+
+// ErrLoginRequired means SSO credentials are missing or expired and nobody
+// completed the device login, so the command cannot continue unattended
+var ErrLoginRequired = errors.New("AWS SSO login required")
+
+// ErrLoginTimeout means the device code expired or LoginOptions.Timeout
+// passed before the login was approved
+var ErrLoginTimeout = fmt.Errorf("%w: the device code was not approved in time", ErrLoginRequired)
+
+// ErrLoginDenied means the user rejected the device login
+var ErrLoginDenied = fmt.Errorf("%w: the device login was denied", ErrLoginRequired)
+
+// DeviceAuthorization is what a human needs to approve an SSO device login
+type DeviceAuthorization struct {
+	Profile                 string    `json:"profile"`
+	VerificationURI         string    `json:"verification_uri"`
+	VerificationURIComplete string    `json:"verification_uri_complete"`
+	UserCode                string    `json:"user_code"`
+	ExpiresAt               time.Time `json:"expires_at"`
+}
+
+// DeviceCodeNotifier delivers a DeviceAuthorization to a human. An error
+// aborts the login.
+type DeviceCodeNotifier func(ctx context.Context, auth DeviceAuthorization) error
+
+// LoginOptions controls how SSOClient.InitiateLoginFlow reaches a human
+type LoginOptions struct {
+	// Notify receives the verification URL and code, BrowserNotifier(os.Stdout) if nil
+	Notify DeviceCodeNotifier
+	// Disabled fails the login straight away with ErrLoginRequired, for
+	// unattended runs where nobody could approve it
+	Disabled bool
+	// Timeout bounds the wait for approval; zero waits until the code expires
+	Timeout time.Duration
+}
+
+// BrowserNotifier prints the code to w and opens the verification URL in
+// the default browser, for interactive use
+func BrowserNotifier(w io.Writer) DeviceCodeNotifier {
+	return func(ctx context.Context, auth DeviceAuthorization) error {
+		fmt.Fprintf(w, "Opening browser for SSO authentication...\n")
+		fmt.Fprintf(w, "Verification URL: %s\n", auth.VerificationURIComplete)
+		fmt.Fprintf(w, "User Code: %s\n", auth.UserCode)
+		if err := browser.OpenURL(auth.VerificationURIComplete); err != nil {
+			fmt.Fprintf(w, "Failed to open browser automatically. Please visit the URL manually.\n")
+		}
+		fmt.Fprintln(w, "Waiting for authorization...")
+		return nil
+	}
+}
+
+// LogNotifier writes the URL and code as a single log line, for headless
+// hosts whose logs someone watches
+func LogNotifier(logger *log.Logger) DeviceCodeNotifier {
+	return func(ctx context.Context, auth DeviceAuthorization) error {
+		logger.Printf("AWS SSO login required for profile %s: visit %s and enter code %s before %s",
+			auth.Profile, auth.VerificationURI, auth.UserCode, auth.ExpiresAt.Format(time.RFC3339))
+		return nil
+	}
+}
+
+// WebhookNotifier POSTs the DeviceAuthorization as JSON to url, for example
+// a chat webhook. A non-2xx response aborts the login since nobody was told.
+func WebhookNotifier(url string, client *http.Client) DeviceCodeNotifier {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return func(ctx context.Context, auth DeviceAuthorization) error {
+		body, err := json.Marshal(auth)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("invalid login webhook URL: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("login webhook failed: %w", err)
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("login webhook returned %s", resp.Status)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,395 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+	"github.com/aws/aws-sdk-go-v2/service/sso/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	oidctypes "github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOIDC answers CreateToken from a list of errors, then succeeds
+type fakeOIDC struct {
+	interval   int32
+	expiresIn  int32
+	tokenErrs  []error
+	registered int
+	tokenCalls []time.Time
+	grants     []string
+}
+
+func (f *fakeOIDC) RegisterClient(ctx context.Context, params *ssooidc.RegisterClientInput, optFns ...func(*ssooidc.Options)) (*ssooidc.RegisterClientOutput, error) {
+	f.registered++
+	return &ssooidc.RegisterClientOutput{
+		ClientId:              aws.String("client"),
+		ClientSecret:          aws.String("secret"),
+		ClientSecretExpiresAt: time.Now().Add(90 * 24 * time.Hour).Unix(),
+	}, nil
+}
+
+func (f *fakeOIDC) StartDeviceAuthorization(ctx context.Context, params *ssooidc.StartDeviceAuthorizationInput, optFns ...func(*ssooidc.Options)) (*ssooidc.StartDeviceAuthorizationOutput, error) {
+	return &ssooidc.StartDeviceAuthorizationOutput{
+		DeviceCode:              aws.String("device"),
+		UserCode:                aws.String("ABCD-EFGH"),
+		VerificationUri:         aws.String("https://device.sso.example.com/"),
+		VerificationUriComplete: aws.String("https://device.sso.example.com/?user_code=ABCD-EFGH"),
+		Interval:                f.interval,
+		ExpiresIn:               f.expiresIn,
+	}, nil
+}
+
+func (f *fakeOIDC) CreateToken(ctx context.Context, params *ssooidc.CreateTokenInput, optFns ...func(*ssooidc.Options)) (*ssooidc.CreateTokenOutput, error) {
+	f.tokenCalls = append(f.tokenCalls, time.Now())
+	f.grants = append(f.grants, aws.ToString(params.GrantType))
+	if len(f.tokenErrs) > 0 {
+		err := f.tokenErrs[0]
+		f.tokenErrs = f.tokenErrs[1:]
+		return nil, err
+	}
+	return &ssooidc.CreateTokenOutput{AccessToken: aws.String("sso-token"), RefreshToken: aws.String("refresh"), ExpiresIn: 3600}, nil
+}
+
+// fakeSSO records the access tokens it is given and rejects the ones in unauthorized
+type fakeSSO struct {
+	tokens       []string
+	unauthorized map[string]bool
+}
+
+func (f *fakeSSO) GetRoleCredentials(ctx context.Context, params *sso.GetRoleCredentialsInput, optFns ...func(*sso.Options)) (*sso.GetRoleCredentialsOutput, error) {
+	f.tokens = append(f.tokens, aws.ToString(params.AccessToken))
+	if f.unauthorized[aws.ToString(params.AccessToken)] {
+		return nil, &types.UnauthorizedException{}
+	}
+	return &sso.GetRoleCredentialsOutput{RoleCredentials: &types.RoleCredentials{
+		AccessKeyId:     aws.String("AKIA"),
+		SecretAccessKey: aws.String("secret"),
+		SessionToken:    aws.String("session"),
+		Expiration:      time.Now().Add(time.Hour).UnixMilli(),
+	}}, nil
+}
+
+// newTestLoginClient returns an SSOClient whose polling interval is counted in milliseconds
+func newTestLoginClient(t *testing.T, oidc *fakeOIDC) (*SSOClient, *[]DeviceAuthorization) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	var notified []DeviceAuthorization
+	client := &SSOClient{
+		profile:    "test",
+		region:     "us-east-1",
+		startURL:   "https://example.awsapps.com/start",
+		roleName:   "Role",
+		accountID:  "123456789012",
+		ssoClient:  &fakeSSO{},
+		oidcClient: oidc,
+		pollUnit:   time.Millisecond,
+		Login: LoginOptions{Notify: func(ctx context.Context, auth DeviceAuthorization) error {
+			notified = append(notified, auth)
+			return nil
+		}},
+	}
+	return client, &notified
+}
+
+func TestInitiateLoginFlow_PendingAndSlowDown(t *testing.T) {
+	oidc := &fakeOIDC{interval: 2, expiresIn: 60, tokenErrs: []error{
+		&oidctypes.AuthorizationPendingException{},
+		&oidctypes.SlowDownException{},
+		&oidctypes.AuthorizationPendingException{},
+	}}
+	client, notified := newTestLoginClient(t, oidc)
+
+	result, err := client.InitiateLoginFlow(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	assert.True(t, result.Success)
+
+	require.Len(t, *notified, 1)
+	assert.Equal(t, "ABCD-EFGH", (*notified)[0].UserCode)
+	assert.Equal(t, "test", (*notified)[0].Profile)
+
+	require.Len(t, oidc.tokenCalls, 4)
+	afterSlowDown := oidc.tokenCalls[2].Sub(oidc.tokenCalls[1])
+	assert.GreaterOrEqual(t, afterSlowDown, 7*time.Millisecond, "slow_down must add five intervals' worth of delay")
+}
+
+func TestInitiateLoginFlow_Failures(t *testing.T) {
+	tests := []struct {
+		name    string
+		oidc    *fakeOIDC
+		login   func(*LoginOptions)
+		ctx     func() context.Context
+		wantErr error
+	}{
+		{
+			name:    "expired_code",
+			oidc:    &fakeOIDC{interval: 1, expiresIn: 60, tokenErrs: []error{&oidctypes.ExpiredTokenException{}}},
+			wantErr: ErrLoginTimeout,
+		},
+		{
+			name:    "denied",
+			oidc:    &fakeOIDC{interval: 1, expiresIn: 60, tokenErrs: []error{&oidctypes.AccessDeniedException{}}},
+			wantErr: ErrLoginDenied,
+		},
+		{
+			name: "timeout",
+			oidc: &fakeOIDC{interval: 1, expiresIn: 60, tokenErrs: []error{
+				&oidctypes.AuthorizationPendingException{}, &oidctypes.AuthorizationPendingException{},
+				&oidctypes.AuthorizationPendingException{}, &oidctypes.AuthorizationPendingException{},
+			}},
+			login:   func(o *LoginOptions) { o.Timeout = 3 * time.Millisecond },
+			wantErr: ErrLoginTimeout,
+		},
+		{
+			name: "notifier_fails",
+			oidc: &fakeOIDC{interval: 1, expiresIn: 60},
+			login: func(o *LoginOptions) {
+				o.Notify = func(context.Context, DeviceAuthorization) error { return errors.New("webhook down") }
+			},
+			wantErr: ErrLoginRequired,
+		},
+		{
+			name: "cancelled",
+			oidc: &fakeOIDC{interval: 3600, expiresIn: 7200},
+			ctx: func() context.Context {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+				t.Cleanup(cancel)
+				return ctx
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newTestLoginClient(t, tt.oidc)
+			if tt.login != nil {
+				tt.login(&client.Login)
+			}
+			ctx := context.Background()
+			if tt.ctx != nil {
+				ctx = tt.ctx()
+			}
+
+			started := time.Now()
+			result, err := client.InitiateLoginFlow(ctx)
+			require.NoError(t, err)
+			assert.False(t, result.Success)
+			assert.ErrorIs(t, result.Error, tt.wantErr)
+			assert.Less(t, time.Since(started), time.Second)
+		})
+	}
+}
+
+func TestInitiateLoginFlow_Disabled(t *testing.T) {
+	oidc := &fakeOIDC{}
+	client, notified := newTestLoginClient(t, oidc)
+	client.Login.Disabled = true
+
+	result, err := client.InitiateLoginFlow(context.Background())
+	require.NoError(t, err)
+	assert.ErrorIs(t, result.Error, ErrLoginRequired)
+	assert.Zero(t, oidc.registered, "A disabled login must not start device authorization")
+	assert.Empty(t, *notified)
+}
+
+func TestInitiateLoginFlow_CachedToken(t *testing.T) {
+	hour := time.Now().Add(time.Hour)
+	yesterday := time.Now().Add(-24 * time.Hour)
+
+	tests := []struct {
+		name         string
+		cached       ssoTokenCache
+		unauthorized []string
+		disabled     bool
+		tokenErrs    []error
+		wantErr      error
+		wantPrompt   bool
+		wantRegister int
+		wantGrants   []string
+		wantTokens   []string
+	}{
+		{
+			name:       "valid_token",
+			cached:     ssoTokenCache{AccessToken: "cached", ExpiresAt: hour},
+			disabled:   true,
+			wantTokens: []string{"cached"},
+		},
+		{
+			name:       "refresh_expired_token",
+			cached:     ssoTokenCache{AccessToken: "cached", ExpiresAt: yesterday, ClientID: "client", ClientSecret: "secret", RegistrationExpiresAt: &hour, RefreshToken: "refresh"},
+			disabled:   true,
+			wantGrants: []string{"refresh_token"},
+			wantTokens: []string{"sso-token"},
+		},
+		{
+			name:         "refresh_revoked_token",
+			cached:       ssoTokenCache{AccessToken: "cached", ExpiresAt: hour, ClientID: "client", ClientSecret: "secret", RegistrationExpiresAt: &hour, RefreshToken: "refresh"},
+			unauthorized: []string{"cached"},
+			disabled:     true,
+			wantGrants:   []string{"refresh_token"},
+			wantTokens:   []string{"cached", "sso-token"},
+		},
+		{
+			name:       "refresh_rejected",
+			cached:     ssoTokenCache{AccessToken: "cached", ExpiresAt: yesterday, ClientID: "client", ClientSecret: "secret", RegistrationExpiresAt: &hour, RefreshToken: "refresh"},
+			disabled:   true,
+			tokenErrs:  []error{&oidctypes.InvalidGrantException{}},
+			wantErr:    ErrLoginRequired,
+			wantGrants: []string{"refresh_token"},
+		},
+		{
+			name:       "reuse_registration",
+			cached:     ssoTokenCache{AccessToken: "cached", ExpiresAt: yesterday, ClientID: "client", ClientSecret: "secret", RegistrationExpiresAt: &hour},
+			wantPrompt: true,
+			wantGrants: []string{"urn:ietf:params:oauth:grant-type:device_code"},
+			wantTokens: []string{"sso-token"},
+		},
+		{
+			name:         "registration_expired",
+			cached:       ssoTokenCache{AccessToken: "cached", ExpiresAt: yesterday, ClientID: "client", ClientSecret: "secret", RegistrationExpiresAt: &yesterday, RefreshToken: "refresh"},
+			wantPrompt:   true,
+			wantRegister: 1,
+			wantGrants:   []string{"urn:ietf:params:oauth:grant-type:device_code"},
+			wantTokens:   []string{"sso-token"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oidc := &fakeOIDC{interval: 1, expiresIn: 60, tokenErrs: tt.tokenErrs}
+			client, notified := newTestLoginClient(t, oidc)
+			client.Login.Disabled = tt.disabled
+			ssoFake := client.ssoClient.(*fakeSSO)
+			ssoFake.unauthorized = make(map[string]bool)
+			for _, token := range tt.unauthorized {
+				ssoFake.unauthorized[token] = true
+			}
+			cached := tt.cached
+			require.NoError(t, client.storeSSOToken(&cached))
+
+			result, err := client.InitiateLoginFlow(context.Background())
+			require.NoError(t, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, result.Error, tt.wantErr)
+			} else {
+				require.NoError(t, result.Error)
+				assert.True(t, result.Success)
+			}
+			assert.Equal(t, tt.wantPrompt, len(*notified) > 0)
+			assert.Equal(t, tt.wantRegister, oidc.registered)
+			assert.Equal(t, tt.wantGrants, oidc.grants)
+			assert.Equal(t, tt.wantTokens, ssoFake.tokens)
+		})
+	}
+}
+
+func TestInitiateLoginFlow_CachesRegistrationAndRefreshToken(t *testing.T) {
+	oidc := &fakeOIDC{interval: 1, expiresIn: 60}
+	client, notified := newTestLoginClient(t, oidc)
+	client.ssoSession = "my-sso"
+
+	result, err := client.InitiateLoginFlow(context.Background())
+	require.NoError(t, err)
+	require.True(t, result.Success)
+	require.Len(t, *notified, 1)
+
+	cachePath, err := client.tokenCachePath()
+	require.NoError(t, err)
+	assert.Equal(t, "0ad374308c5a4e22f723adf10145eafad7c4031c.json", filepath.Base(cachePath))
+	token, err := client.loadSSOToken()
+	require.NoError(t, err)
+	require.NotNil(t, token)
+	assert.Equal(t, "sso-token", token.AccessToken)
+	assert.Equal(t, "refresh", token.RefreshToken)
+	assert.Equal(t, "client", token.ClientID)
+	assert.True(t, token.canRefresh(time.Now()))
+
+	// A second run finds the token in the cache and does not prompt again
+	result, err = client.InitiateLoginFlow(context.Background())
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Len(t, *notified, 1)
+	assert.Equal(t, 1, oidc.registered)
+}
+
+func TestLogNotifier(t *testing.T) {
+	var buf bytes.Buffer
+	err := LogNotifier(log.New(&buf, "", 0))(context.Background(), DeviceAuthorization{
+		Profile:         "prod",
+		VerificationURI: "https://device.sso.example.com/",
+		UserCode:        "ABCD-EFGH",
+		ExpiresAt:       time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "AWS SSO login required for profile prod: visit https://device.sso.example.com/ and enter code ABCD-EFGH before 2024-01-01T12:00:00Z\n", buf.String())
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var received DeviceAuthorization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		if received.UserCode == "FAIL" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	notify := WebhookNotifier(server.URL, server.Client())
+	require.NoError(t, notify(context.Background(), DeviceAuthorization{Profile: "prod", UserCode: "ABCD-EFGH"}))
+	assert.Equal(t, "ABCD-EFGH", received.UserCode)
+
+	err := notify(context.Background(), DeviceAuthorization{UserCode: "FAIL"})
+	assert.ErrorContains(t, err, "500")
+}
+
+// fakeSTS records the roles it is asked to assume
+type fakeSTS struct {
+	assumed []string
+}
+
+func (f *fakeSTS) AssumeRole(ctx context.Context, params *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	f.assumed = append(f.assumed, aws.ToString(params.RoleArn)+" "+aws.ToString(params.ExternalId))
+	return &sts.AssumeRoleOutput{Credentials: &ststypes.Credentials{
+		AccessKeyId:     aws.String("ASIA"),
+		SecretAccessKey: aws.String("secret"),
+		SessionToken:    aws.String("session"),
+		Expiration:      aws.Time(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}}, nil
+}
+
+func TestInitiateLoginFlow_RoleChain(t *testing.T) {
+	client, _ := newTestLoginClient(t, &fakeOIDC{interval: 1, expiresIn: 60})
+	stsFake := &fakeSTS{}
+	client.newSTS = func(aws.Config) stsAPI { return stsFake }
+	client.roleChain = []AssumeRoleConfig{
+		{Profile: "ops", RoleARN: "arn:aws:iam::111111111111:role/Ops", ExternalID: "ops-external"},
+		{Profile: "admin", RoleARN: "arn:aws:iam::222222222222:role/Admin"},
+	}
+
+	result, err := client.InitiateLoginFlow(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, result.Error)
+	assert.Equal(t, []string{"arn:aws:iam::111111111111:role/Ops ops-external", "arn:aws:iam::222222222222:role/Admin "}, stsFake.assumed)
+	assert.Equal(t, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), result.ExpiresAt)
+
+	creds, err := result.Config.Credentials.Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "ASIA", creds.AccessKeyID)
+}
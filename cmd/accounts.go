@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newAccountsCommand() *cobra.Command {
+	accountsCmd := &cobra.Command{
+		Use:   "accounts",
+		Short: "Inspect stored bank accounts",
+	}
+	var connection string
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List every stored bank account",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbClient, err := openConnectionDatabase(connection)
+			if err != nil {
+				return err
+			}
+			defer dbClient.Close()
+
+			accounts, err := dbClient.ListBankAccounts()
+			if err != nil {
+				return err
+			}
+
+			w := newTabWriter(cmd.OutOrStdout())
+			fmt.Fprintln(w, "ID\tNAME\tINSTITUTION\tSTATUS")
+			for _, account := range accounts {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", account.ID, account.Name, account.InstitutionName, account.Status)
+			}
+			return w.Flush()
+		},
+	}
+	listCmd.Flags().StringVar(&connection, "connection", "", "only list the named connection's accounts")
+	accountsCmd.AddCommand(listCmd)
+	accountsCmd.AddCommand(&cobra.Command{
+		Use:   "history <account-id>",
+		Short: "Show recorded renames and status changes for an account",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbClient, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer dbClient.Close()
+
+			history, err := dbClient.GetBankAccountHistory(args[0])
+			if err != nil {
+				return err
+			}
+
+			w := newTabWriter(cmd.OutOrStdout())
+			fmt.Fprintln(w, "CHANGED\tFIELD\tOLD\tNEW\tRUN")
+			for _, change := range history {
+				run := change.RunID
+				if run == "" {
+					run = "manual"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+					change.ChangedAt.Local().Format(time.DateTime), change.Field, change.OldValue, change.NewValue, run)
+			}
+			return w.Flush()
+		},
+	})
+	accountsCmd.AddCommand(&cobra.Command{
+		Use:   "reactivate <account-id>",
+		Short: "Mark a missing or closed account active again",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbClient, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer dbClient.Close()
+
+			if err := dbClient.ReactivateBankAccount(args[0]); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Account %s is active\n", args[0])
+			return nil
+		},
+	})
+	return accountsCmd
+}
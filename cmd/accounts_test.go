@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountsList(t *testing.T) {
+	dbClient := setupTestHome(t)
+	require.NoError(t, dbClient.PutBankAccount(model.Account{
+		ID:   "acc_1",
+		Name: "Checking",
+		Org:  model.Organization{Name: "Test Bank"},
+	}))
+	require.NoError(t, dbClient.PutBankAccount(model.Account{
+		ID:   "acc_2",
+		Name: "Brokerage",
+		Org:  model.Organization{Name: "Another Bank"},
+	}))
+
+	out, err := executeCommand(t, "accounts", "list")
+	require.NoError(t, err)
+
+	assert.Contains(t, out, "ID")
+	assert.Contains(t, out, "acc_1")
+	assert.Contains(t, out, "Checking")
+	assert.Contains(t, out, "Test Bank")
+	assert.Less(t, strings.Index(out, "Another Bank"), strings.Index(out, "Test Bank"), "Accounts should be ordered by institution")
+}
+
+func TestBalancesHistory(t *testing.T) {
+	dbClient := setupTestHome(t)
+	require.NoError(t, dbClient.PutAccountBalance("acc_1", "run_1", "100.00"))
+	require.NoError(t, dbClient.PutAccountBalance("acc_1", "run_2", "125.50"))
+	require.NoError(t, dbClient.PutAccountBalance("acc_2", "run_2", "9.99"))
+
+	out, err := executeCommand(t, "balances", "history", "--account", "acc_1")
+	require.NoError(t, err)
+	assert.Contains(t, out, "100.00")
+	assert.Contains(t, out, "125.50")
+	assert.NotContains(t, out, "9.99")
+
+	out, err = executeCommand(t, "balances", "history", "--limit", "1")
+	require.NoError(t, err)
+	assert.Contains(t, out, "9.99", "Newest balance should be shown first")
+	assert.NotContains(t, out, "100.00")
+}
+
+func TestAccountsHistoryAndReactivate(t *testing.T) {
+	dbClient := setupTestHome(t)
+	_, _, err := dbClient.UpsertBankAccount(model.Account{ID: "acc_1", Name: "Checking", Org: model.Organization{Name: "Test Bank"}}, "run_1")
+	require.NoError(t, err)
+	_, _, err = dbClient.UpsertBankAccount(model.Account{ID: "acc_1", Name: "Everyday Checking", Org: model.Organization{Name: "Test Bank"}}, "run_2")
+	require.NoError(t, err)
+	_, err = dbClient.MarkMissingAccounts("run_3", nil, 1)
+	require.NoError(t, err)
+
+	out, err := executeCommand(t, "accounts", "list")
+	require.NoError(t, err)
+	assert.Contains(t, out, "closed")
+
+	out, err = executeCommand(t, "accounts", "reactivate", "acc_1")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Account acc_1 is active")
+
+	out, err = executeCommand(t, "accounts", "history", "acc_1")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Everyday Checking")
+	assert.Contains(t, out, "run_2")
+	assert.Contains(t, out, "closed")
+	assert.Contains(t, out, "manual")
+
+	_, err = executeCommand(t, "accounts", "reactivate", "acc_missing")
+	assert.Error(t, err)
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/api"
+	"github.com/criswit/chi-chi-moni/db"
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/spf13/cobra"
+)
+
+func newBackfillCommand() *cobra.Command {
+	var since string
+	var restart bool
+	var connections []string
+	backfillCmd := &cobra.Command{
+		Use:   "backfill",
+		Short: "Fetch transaction history back to a given date",
+		Long: `Fetch transaction history back to a given date.
+
+History is requested newest first in windows of backfill.window, pausing
+backfill.pause between requests. Each window is stored in one database
+transaction together with a checkpoint, so an interrupted backfill resumes
+where it stopped when run again with the same --since. Windows in which the
+bridge reported errors are recorded and fetched again by the next run with
+the same --since. Use --restart to discard the checkpoint and fetch every
+window again.
+
+When the config defines connections, each is backfilled in turn with its own
+access token, AWS profile, database and checkpoint; --connection limits the
+backfill to the named ones.`,
+		Example: "  monies backfill --since 2023-01-01",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sinceTime, err := time.ParseInLocation(time.DateOnly, since, time.Local)
+			if err != nil {
+				return fmt.Errorf("invalid --since date %q, expected YYYY-MM-DD", since)
+			}
+			return runBackfillConnections(cmd.Context(), cmd.OutOrStdout(), connections, sinceTime, time.Now(), restart)
+		},
+	}
+	backfillCmd.Flags().StringVar(&since, "since", "", "oldest date to fetch (YYYY-MM-DD)")
+	backfillCmd.Flags().BoolVar(&restart, "restart", false, "discard any saved progress and start from now")
+	backfillCmd.Flags().StringSliceVar(&connections, "connection", nil, "backfill only the named connection (repeatable)")
+	backfillCmd.MarkFlagRequired("since")
+	return backfillCmd
+}
+
+// runBackfillConnections backfills the selected connections one after
+// another with their resolved settings as the effective configuration. A
+// failing connection does not stop the others.
+func runBackfillConnections(ctx context.Context, out io.Writer, names []string, since time.Time, until time.Time, restart bool) error {
+	if !since.Before(until) {
+		return fmt.Errorf("--since %s is not in the past", since.Format(time.DateOnly))
+	}
+	connections, err := cfg.ResolveConnections(names)
+	if err != nil {
+		return err
+	}
+	if len(cfg.Connections) == 0 {
+		return runBackfill(ctx, out, connections[0].Tenant, since, until, restart)
+	}
+
+	base := cfg
+	defer func() { cfg = base }()
+
+	var results []connectionResult
+	for _, conn := range connections {
+		result := connectionResult{name: conn.Name}
+		if result.err = ctx.Err(); result.err == nil {
+			fmt.Fprintf(out, "== %s ==\n", conn.Name)
+			cfg = conn.Config
+			result.err = runBackfill(ctx, out, conn.Tenant, since, until, restart)
+		}
+		results = append(results, result)
+	}
+	return connectionsError(results)
+}
+
+// runBackfill retries the failed windows of the tenant's backfill, then walks
+// back from the saved checkpoint, or until, to since. Bridge errors are
+// reported but do not stop the backfill; it then returns an *ExitError with
+// ExitBridgeErrors.
+func runBackfill(ctx context.Context, out io.Writer, tenant string, since time.Time, until time.Time, restart bool) error {
+	if !since.Before(until) {
+		return fmt.Errorf("--since %s is not in the past", since.Format(time.DateOnly))
+	}
+
+	dbClient, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Close()
+	dbClient = dbClient.WithTenant(tenant)
+
+	progress, err := dbClient.BeginBackfill(since.Unix(), until.Unix(), restart)
+	if err != nil {
+		return err
+	}
+	failed, err := dbClient.ListFailedBackfillWindows(progress.Since)
+	if err != nil {
+		return err
+	}
+	if progress.Done() && len(failed) == 0 {
+		fmt.Fprintf(out, "Backfill to %s is already complete; use --restart to fetch it again\n", since.Format(time.DateOnly))
+		return nil
+	}
+	if progress.Chunks > 0 {
+		fmt.Fprintf(out, "Resuming backfill to %s from %s\n", since.Format(time.DateOnly), formatEpochDate(progress.NextEnd))
+	}
+
+	fetcher, err := newAccountsFetcher(ctx)
+	if err != nil {
+		return err
+	}
+
+	requests := 0
+	fetch := func(windowStart int64, windowEnd int64) (*model.GetAccountsResponse, error) {
+		if requests > 0 {
+			if err := sleepContext(ctx, cfg.Backfill.Pause); err != nil {
+				return nil, err
+			}
+		}
+		requests++
+		resp, err := fetcher.GetAccountsContext(ctx, &api.GetAccountsOptions{
+			StartDate: &windowStart,
+			EndDate:   &windowEnd,
+		})
+		return resp, withAuthHint(err)
+	}
+
+	bridgeErrors := 0
+	report := func(label string, windowStart int64, windowEnd int64, resp *model.GetAccountsResponse, previous db.BackfillProgress, current db.BackfillProgress) {
+		fmt.Fprintf(out, "%s %s..%s: %d accounts, %d new transactions, %d updated\n",
+			label, formatEpochDate(windowStart), formatEpochDate(windowEnd), len(resp.Accounts),
+			current.TransactionsInserted-previous.TransactionsInserted,
+			current.TransactionsUpdated-previous.TransactionsUpdated)
+		for _, message := range resp.Errors {
+			fmt.Fprintf(out, "SimpleFIN error: %s\n", message)
+		}
+		bridgeErrors += len(resp.Errors)
+	}
+
+	for _, window := range failed {
+		resp, err := fetch(window.Start, window.End)
+		if err != nil {
+			return backfillStopped(out, err)
+		}
+		previous := progress
+		progress, err = dbClient.RetryBackfillWindow(progress.Since, window, resp.Accounts, len(resp.Errors) > 0)
+		if err != nil {
+			return backfillStopped(out, err)
+		}
+		report("Retried window", window.Start, window.End, resp, previous, progress)
+	}
+
+	window := int64(cfg.Backfill.Window / time.Second)
+	for !progress.Done() {
+		windowEnd := progress.NextEnd
+		windowStart := max(windowEnd-window, progress.Since)
+
+		resp, err := fetch(windowStart, windowEnd)
+		if err != nil {
+			return backfillStopped(out, err)
+		}
+		previous := progress
+		progress, err = dbClient.PutBackfillChunk(progress.Since, windowStart, resp.Accounts, len(resp.Errors) > 0)
+		if err != nil {
+			return backfillStopped(out, err)
+		}
+		report("Window", windowStart, windowEnd, resp, previous, progress)
+	}
+
+	fmt.Fprintf(out, "Backfill to %s complete: %d windows, %d new transactions, %d updated\n",
+		since.Format(time.DateOnly), progress.Chunks, progress.TransactionsInserted, progress.TransactionsUpdated)
+	if bridgeErrors > 0 {
+		return &ExitError{
+			Code: ExitBridgeErrors,
+			Err:  fmt.Errorf("simplefin reported %d account error(s) during the backfill; run the same command again to fetch those windows again", bridgeErrors),
+		}
+	}
+	return nil
+}
+
+// backfillStopped tells the user how to resume before returning err
+func backfillStopped(out io.Writer, err error) error {
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(out, "Backfill interrupted; run the same command again to resume")
+	} else {
+		fmt.Fprintln(out, "Backfill stopped; progress is saved and the same command resumes it")
+	}
+	return err
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func formatEpochDate(epoch int64) string {
+	return time.Unix(epoch, 0).Local().Format(time.DateOnly)
+}
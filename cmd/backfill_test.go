@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/api"
+	"github.com/criswit/chi-chi-moni/config"
+	"github.com/criswit/chi-chi-moni/db"
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFetcher serves GetAccountsContext from a function and records each request
+type fakeFetcher struct {
+	requests []api.GetAccountsOptions
+	respond  func(opts *api.GetAccountsOptions) (*model.GetAccountsResponse, error)
+}
+
+func (f *fakeFetcher) GetAccountsContext(ctx context.Context, opts *api.GetAccountsOptions) (*model.GetAccountsResponse, error) {
+	f.requests = append(f.requests, *opts)
+	return f.respond(opts)
+}
+
+func useFakeFetcher(t *testing.T, fetcher *fakeFetcher) {
+	t.Helper()
+	original := newAccountsFetcher
+	newAccountsFetcher = func(ctx context.Context) (accountsFetcher, error) {
+		return fetcher, nil
+	}
+	t.Cleanup(func() { newAccountsFetcher = original })
+}
+
+// oneTransactionPerWindow answers each request with a transaction posted at its start date
+func oneTransactionPerWindow(opts *api.GetAccountsOptions) (*model.GetAccountsResponse, error) {
+	return &model.GetAccountsResponse{
+		Accounts: []model.Account{{
+			ID:   "acc_1",
+			Name: "Checking",
+			Transactions: []model.Transaction{
+				{ID: time.Unix(*opts.StartDate, 0).UTC().Format(time.DateOnly), Posted: *opts.StartDate, Amount: "-1.00"},
+			},
+		}},
+	}, nil
+}
+
+func TestRunBackfill(t *testing.T) {
+	dbClient := setupTestHome(t)
+	cfg.Backfill.Window = 10 * 24 * time.Hour
+	cfg.Backfill.Pause = 0
+	fetcher := &fakeFetcher{respond: oneTransactionPerWindow}
+	useFakeFetcher(t, fetcher)
+
+	since := time.Date(2023, 1, 1, 0, 0, 0, 0, time.Local)
+	until := since.AddDate(0, 0, 25)
+
+	var out bytes.Buffer
+	require.NoError(t, runBackfill(context.Background(), &out, "", since, until, false))
+
+	require.Len(t, fetcher.requests, 3)
+	assert.Equal(t, until.Unix(), *fetcher.requests[0].EndDate)
+	assert.Equal(t, until.AddDate(0, 0, -10).Unix(), *fetcher.requests[0].StartDate)
+	assert.Equal(t, since.Unix(), *fetcher.requests[2].StartDate, "The last window stops at --since")
+	assert.Equal(t, *fetcher.requests[1].StartDate, *fetcher.requests[2].EndDate)
+
+	transactions, err := dbClient.ListTransactions(db.TransactionFilter{})
+	require.NoError(t, err)
+	assert.Len(t, transactions, 3)
+	assert.Contains(t, out.String(), "complete: 3 windows, 3 new transactions, 0 updated")
+
+	// Running it again has nothing left to fetch
+	out.Reset()
+	require.NoError(t, runBackfill(context.Background(), &out, "", since, until, false))
+	assert.Len(t, fetcher.requests, 3)
+	assert.Contains(t, out.String(), "already complete")
+}
+
+func TestRunBackfill_Resume(t *testing.T) {
+	dbClient := setupTestHome(t)
+	cfg.Backfill.Window = 10 * 24 * time.Hour
+	cfg.Backfill.Pause = 0
+	failAfter := 2
+	fetcher := &fakeFetcher{}
+	fetcher.respond = func(opts *api.GetAccountsOptions) (*model.GetAccountsResponse, error) {
+		if len(fetcher.requests) > failAfter {
+			return nil, errors.New("connection reset")
+		}
+		return oneTransactionPerWindow(opts)
+	}
+	useFakeFetcher(t, fetcher)
+
+	since := time.Date(2023, 1, 1, 0, 0, 0, 0, time.Local)
+	until := since.AddDate(0, 0, 35)
+
+	var out bytes.Buffer
+	err := runBackfill(context.Background(), &out, "", since, until, false)
+	require.Error(t, err)
+	assert.Contains(t, out.String(), "progress is saved")
+
+	progress, err := dbClient.GetBackfillProgress(since.Unix())
+	require.NoError(t, err)
+	assert.Equal(t, 2, progress.Chunks)
+
+	// A later run resumes from the checkpoint rather than from its own start time
+	failAfter = 10
+	fetcher.requests = nil
+	out.Reset()
+	require.NoError(t, runBackfill(context.Background(), &out, "", since, until.AddDate(0, 0, 1), false))
+	require.Len(t, fetcher.requests, 2)
+	assert.Equal(t, progress.NextEnd, *fetcher.requests[0].EndDate)
+	assert.Contains(t, out.String(), "Resuming backfill to 2023-01-01")
+
+	transactions, err := dbClient.ListTransactions(db.TransactionFilter{})
+	require.NoError(t, err)
+	assert.Len(t, transactions, 4)
+}
+
+func TestRunBackfill_Interrupted(t *testing.T) {
+	setupTestHome(t)
+	cfg.Backfill.Window = 10 * 24 * time.Hour
+	cfg.Backfill.Pause = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	fetcher := &fakeFetcher{respond: func(opts *api.GetAccountsOptions) (*model.GetAccountsResponse, error) {
+		cancel()
+		return oneTransactionPerWindow(opts)
+	}}
+	useFakeFetcher(t, fetcher)
+
+	since := time.Date(2023, 1, 1, 0, 0, 0, 0, time.Local)
+	var out bytes.Buffer
+	err := runBackfill(ctx, &out, "", since, since.AddDate(0, 0, 30), false)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, fetcher.requests, 1, "The pause must end when the context is cancelled")
+	assert.Contains(t, out.String(), "Backfill interrupted")
+}
+
+func TestRunBackfill_BridgeErrors(t *testing.T) {
+	dbClient := setupTestHome(t)
+	cfg.Backfill.Window = 10 * 24 * time.Hour
+	cfg.Backfill.Pause = 0
+	since := time.Date(2023, 1, 1, 0, 0, 0, 0, time.Local)
+	until := since.AddDate(0, 0, 25)
+	failing := until.AddDate(0, 0, -10).Unix()
+
+	fetcher := &fakeFetcher{}
+	fetcher.respond = func(opts *api.GetAccountsOptions) (*model.GetAccountsResponse, error) {
+		resp, _ := oneTransactionPerWindow(opts)
+		if *opts.StartDate == failing {
+			resp.Errors = []string{"Connection to Test Bank may need attention"}
+		}
+		return resp, nil
+	}
+	useFakeFetcher(t, fetcher)
+
+	var out bytes.Buffer
+	err := runBackfill(context.Background(), &out, "", since, until, false)
+	assert.Equal(t, ExitBridgeErrors, ExitCode(err))
+	assert.Contains(t, out.String(), "SimpleFIN error: Connection to Test Bank may need attention")
+	require.Len(t, fetcher.requests, 3, "The other windows are still fetched")
+
+	// A normal resume fetches only the failed window again
+	failing = 0
+	fetcher.requests = nil
+	out.Reset()
+	require.NoError(t, runBackfill(context.Background(), &out, "", since, until, false))
+	require.Len(t, fetcher.requests, 1)
+	assert.Equal(t, until.AddDate(0, 0, -10).Unix(), *fetcher.requests[0].StartDate)
+	assert.Equal(t, until.Unix(), *fetcher.requests[0].EndDate)
+	assert.Contains(t, out.String(), "Retried window")
+
+	windows, err := dbClient.ListFailedBackfillWindows(since.Unix())
+	require.NoError(t, err)
+	assert.Empty(t, windows)
+
+	out.Reset()
+	require.NoError(t, runBackfill(context.Background(), &out, "", since, until, false))
+	assert.Contains(t, out.String(), "already complete")
+}
+
+func TestBackfill_Connections(t *testing.T) {
+	setupTestHome(t)
+	t.Cleanup(func() { cfg = config.Default() })
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	configPath := filepath.Join(home, "config.yaml")
+	content := `backfill:
+  pause: 0s
+connections:
+  - name: personal
+  - name: business
+    secret_name: business-monies
+`
+	require.NoError(t, os.WriteFile(configPath, []byte(content), 0600))
+
+	businessErr := errors.New("bridge down")
+	useFetcherPerSecret(t, map[string]func() (*model.GetAccountsResponse, error){
+		"monk-monies": func() (*model.GetAccountsResponse, error) {
+			return &model.GetAccountsResponse{Accounts: []model.Account{{ID: "acc_personal", Name: "Checking"}}}, nil
+		},
+		"business-monies": func() (*model.GetAccountsResponse, error) {
+			if businessErr != nil {
+				return nil, businessErr
+			}
+			return &model.GetAccountsResponse{Accounts: []model.Account{{ID: "acc_business", Name: "Operating"}}}, nil
+		},
+	})
+
+	since := time.Now().AddDate(0, 0, -30).Format(time.DateOnly)
+	out, err := executeCommand(t, "--config", configPath, "backfill", "--since", since)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 of 2 connection(s) had errors")
+	assert.Contains(t, err.Error(), "connection business: bridge down")
+	assert.Contains(t, out, "== personal ==")
+	assert.Contains(t, out, "== business ==")
+	assert.Equal(t, "monk-monies", cfg.SecretName, "The base configuration is restored")
+
+	// Each connection resumes its own checkpoint in the shared database
+	businessErr = nil
+	out, err = executeCommand(t, "--config", configPath, "backfill", "--since", since, "--connection", "business")
+	require.NoError(t, err)
+	assert.NotContains(t, out, "== personal ==")
+	assert.NotContains(t, out, "already complete")
+
+	out, err = executeCommand(t, "--config", configPath, "backfill", "--since", since, "--connection", "personal")
+	require.NoError(t, err)
+	assert.Contains(t, out, "already complete")
+}
+
+func TestRunBackfill_SinceInFuture(t *testing.T) {
+	setupTestHome(t)
+	now := time.Now()
+	err := runBackfill(context.Background(), &bytes.Buffer{}, "", now.AddDate(0, 0, 1), now, false)
+	assert.ErrorContains(t, err, "not in the past")
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newBalancesCommand() *cobra.Command {
+	balancesCmd := &cobra.Command{
+		Use:   "balances",
+		Short: "Inspect recorded account balances",
+	}
+
+	var accountId string
+	var limit int
+	var connection string
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show balance history, newest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbClient, err := openConnectionDatabase(connection)
+			if err != nil {
+				return err
+			}
+			defer dbClient.Close()
+
+			balances, err := dbClient.GetBalanceHistory(accountId, limit)
+			if err != nil {
+				return err
+			}
+
+			w := newTabWriter(cmd.OutOrStdout())
+			fmt.Fprintln(w, "RECORDED\tACCOUNT\tBALANCE\tRUN")
+			for _, balance := range balances {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+					balance.CreatedAt.Local().Format(time.DateTime), balance.BankAccountID, balance.Balance, balance.RunID)
+			}
+			return w.Flush()
+		},
+	}
+	historyCmd.Flags().StringVar(&accountId, "account", "", "only show balances for this account ID")
+	historyCmd.Flags().IntVar(&limit, "limit", 50, "maximum number of rows to show (0 for all)")
+	historyCmd.Flags().StringVar(&connection, "connection", "", "only show the named connection's balances")
+
+	var currentConnection string
+
+	currentCmd := &cobra.Command{
+		Use:   "current",
+		Short: "Show each active account's latest balance with pending transactions added",
+		Long: `Show each active account's latest balance with pending transactions added.
+
+Only pending transactions that have neither posted nor expired are added, so a
+transaction is never counted both as pending and in the posted balance.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbClient, err := openConnectionDatabase(currentConnection)
+			if err != nil {
+				return err
+			}
+			defer dbClient.Close()
+
+			balances, err := dbClient.GetPendingBalances()
+			if err != nil {
+				return err
+			}
+
+			w := newTabWriter(cmd.OutOrStdout())
+			fmt.Fprintln(w, "ACCOUNT\tNAME\tBALANCE\tPENDING\tWITH PENDING")
+			for _, balance := range balances {
+				withPending, err := balance.WithPending()
+				if err != nil {
+					return fmt.Errorf("account %s: %w", balance.BankAccountID, err)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s (%d)\t%s\n",
+					balance.BankAccountID, balance.Name, balance.Balance, balance.Pending, balance.PendingCount, withPending)
+			}
+			return w.Flush()
+		},
+	}
+
+	currentCmd.Flags().StringVar(&currentConnection, "connection", "", "only show the named connection's accounts")
+
+	balancesCmd.AddCommand(historyCmd, currentCmd)
+	return balancesCmd
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/criswit/chi-chi-moni/api"
+	"github.com/criswit/chi-chi-moni/aws"
+	"github.com/criswit/chi-chi-moni/config"
+	"github.com/criswit/chi-chi-moni/credstore"
+	"github.com/criswit/chi-chi-moni/db"
+	"github.com/criswit/chi-chi-moni/model"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func getDbFilePath() (string, error) {
+	return cfg.DatabasePath()
+}
+
+// openDatabase opens the local database, creating its directory on first use
+func openDatabase() (*db.DatabaseClient, error) {
+	dbPath, err := getDbFilePath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+	return db.NewDatabaseClient(dbPath)
+}
+
+// openConnectionDatabase opens the database of the named connection scoped to
+// its tenant. An empty name opens the top-level database unscoped, so every
+// tenant stored in it is read.
+func openConnectionDatabase(name string) (*db.DatabaseClient, error) {
+	if name == "" {
+		return openDatabase()
+	}
+	connections, err := cfg.ResolveConnections([]string{name})
+	if err != nil {
+		return nil, err
+	}
+
+	base := cfg
+	defer func() { cfg = base }()
+	cfg = connections[0].Config
+	dbClient, err := openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return dbClient.WithTenant(connections[0].Tenant), nil
+}
+
+func newSecretsManagerClient(ctx context.Context) (*aws.SecretsManagerClient, error) {
+	ssoClient, err := aws.NewSSOClient(cfg.AWS.Profile, cfg.AWS.Region)
+	if err != nil {
+		return nil, err
+	}
+	ssoClient.Login = loginOptions(cfg.AWS)
+	return aws.NewSecretsManagerClientWithSSO(ctx, ssoClient)
+}
+
+// loginOptions maps aws.login to how the SSO device login reaches a human.
+// Everything but the browser mode keeps stdout free for command output.
+func loginOptions(awsConfig config.AWSConfig) aws.LoginOptions {
+	options := aws.LoginOptions{Timeout: awsConfig.LoginTimeout}
+	switch awsConfig.Login {
+	case config.LoginLog:
+		options.Notify = aws.LogNotifier(log.New(os.Stderr, "", log.LstdFlags))
+	case config.LoginWebhook:
+		options.Notify = aws.WebhookNotifier(awsConfig.LoginWebhook, nil)
+	case config.LoginNone:
+		options.Disabled = true
+	default:
+		options.Notify = aws.BrowserNotifier(os.Stdout)
+	}
+	return options
+}
+
+// openCredentialStore opens the backend selected by credentials.backend.
+// Tests replace it to avoid AWS.
+var openCredentialStore = func(ctx context.Context) (credstore.CredentialStore, error) {
+	switch cfg.Credentials.Backend {
+	case config.CredentialsFile:
+		path, err := cfg.CredentialsFilePath()
+		if err != nil {
+			return nil, err
+		}
+		passphrase, ok := os.LookupEnv(config.EnvCredentialsPassphrase)
+		if !ok {
+			return nil, fmt.Errorf("the file credential backend needs its passphrase in %s", config.EnvCredentialsPassphrase)
+		}
+		return credstore.NewFileStore(path, passphrase)
+	case config.CredentialsEnv:
+		store := credstore.NewEnvStore()
+		// Each named connection must have its own variable
+		store.NoFallback = cfg.Connection != ""
+		return store, nil
+	default:
+		return newSecretsManagerClient(ctx)
+	}
+}
+
+func getAccessToken(ctx context.Context) (api.AccessToken, error) {
+	store, err := openCredentialStore(ctx)
+	if err != nil {
+		return api.AccessToken{}, err
+	}
+	return store.Get(ctx, cfg.SecretName)
+}
+
+// accountsFetcher is the part of the SimpleFIN client that sync and backfill use
+type accountsFetcher interface {
+	GetAccountsContext(ctx context.Context, opts *api.GetAccountsOptions) (*model.GetAccountsResponse, error)
+}
+
+// newAccountsFetcher loads the access token and builds a SimpleFIN client.
+// Tests replace it to avoid AWS and the bridge.
+var newAccountsFetcher = func(ctx context.Context) (accountsFetcher, error) {
+	accessToken, err := getAccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return api.NewSimpleFinClient(accessToken)
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newDBCommand() *cobra.Command {
+	dbCmd := &cobra.Command{
+		Use:   "db",
+		Short: "Manage the local database",
+	}
+	dbCmd.AddCommand(&cobra.Command{
+		Use:   "migrate",
+		Short: "Apply pending schema migrations and show the migration history",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Opening the database applies any pending migrations
+			dbClient, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer dbClient.Close()
+
+			applied, err := dbClient.AppliedMigrations()
+			if err != nil {
+				return err
+			}
+
+			w := newTabWriter(cmd.OutOrStdout())
+			fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+			for _, m := range applied {
+				fmt.Fprintf(w, "%04d\t%s\t%s\n", m.Version, m.Name, m.AppliedAt.Local().Format(time.DateTime))
+			}
+			return w.Flush()
+		},
+	})
+	return dbCmd
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/criswit/chi-chi-moni/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDBMigrate(t *testing.T) {
+	setupTestHome(t)
+
+	out, err := executeCommand(t, "db", "migrate")
+	require.NoError(t, err)
+
+	migrations, err := db.Migrations()
+	require.NoError(t, err)
+	for _, m := range migrations {
+		assert.Contains(t, out, m.Name)
+	}
+}
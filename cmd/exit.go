@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/criswit/chi-chi-moni/aws"
+)
+
+// Process exit codes, so cron wrappers can tell failures apart
+const (
+	ExitOK      = 0
+	ExitFailure = 1 // The command failed
+	// ExitBridgeErrors means the sync completed but SimpleFIN reported
+	// account-level errors, such as an institution needing re-authentication
+	ExitBridgeErrors = 3
+	// ExitLoginRequired means AWS SSO credentials expired and the device
+	// login could not be completed, so a human has to run it
+	ExitLoginRequired = 4
+)
+
+// ExitError attaches a specific exit code to an error
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the process exit code for an error returned by Execute
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	if errors.Is(err, aws.ErrLoginRequired) {
+		return ExitLoginRequired
+	}
+	return ExitFailure
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newHoldingsCommand() *cobra.Command {
+	holdingsCmd := &cobra.Command{
+		Use:   "holdings",
+		Short: "Inspect investment positions recorded for brokerage and retirement accounts",
+	}
+
+	var accountId string
+	var runId string
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "Show an account's holdings from the latest run, or from --run",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbClient, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer dbClient.Close()
+
+			holdings, err := dbClient.GetHoldings(accountId, runId)
+			if err != nil {
+				return err
+			}
+
+			w := newTabWriter(cmd.OutOrStdout())
+			fmt.Fprintln(w, "SYMBOL\tSHARES\tCOST BASIS\tMARKET VALUE\tCURRENCY\tDESCRIPTION")
+			for _, holding := range holdings {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+					holding.Symbol, holding.Shares, holding.CostBasis, holding.MarketValue, holding.Currency, holding.Description)
+			}
+			return w.Flush()
+		},
+	}
+	listCmd.Flags().StringVar(&accountId, "account", "", "account ID (required)")
+	listCmd.Flags().StringVar(&runId, "run", "", "show the snapshot from this sync run instead of the latest")
+	listCmd.MarkFlagRequired("account")
+
+	var historyAccountId string
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show an account's total market value per run, newest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbClient, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer dbClient.Close()
+
+			history, err := dbClient.GetPortfolioHistory(historyAccountId)
+			if err != nil {
+				return err
+			}
+
+			w := newTabWriter(cmd.OutOrStdout())
+			fmt.Fprintln(w, "RECORDED\tHOLDINGS\tMARKET VALUE\tRUN")
+			for _, value := range history {
+				fmt.Fprintf(w, "%s\t%d\t%s\t%s\n",
+					value.CreatedAt.Local().Format(time.DateTime), value.Holdings,
+					value.MarketValue.FormatLocale("en-US"), value.RunID)
+			}
+			return w.Flush()
+		},
+	}
+	historyCmd.Flags().StringVar(&historyAccountId, "account", "", "account ID (required)")
+	historyCmd.MarkFlagRequired("account")
+
+	holdingsCmd.AddCommand(listCmd, historyCmd)
+	return holdingsCmd
+}
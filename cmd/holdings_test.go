@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHoldings(t *testing.T) {
+	dbClient := setupTestHome(t)
+	require.NoError(t, dbClient.PutBankAccount(model.Account{ID: "acc_1", Name: "Brokerage", Org: model.Organization{Name: "Test Bank"}}))
+	require.NoError(t, dbClient.PutHoldings("acc_1", "run_1", "USD", []model.Holding{
+		{ID: "h_1", Symbol: "VTI", Shares: "10", MarketValue: "2500.00"},
+		{ID: "h_2", Symbol: "AAPL", Shares: "5", MarketValue: "1000.50"},
+	}))
+	require.NoError(t, dbClient.PutHoldings("acc_1", "run_2", "USD", []model.Holding{
+		{ID: "h_1", Symbol: "VTI", Shares: "12", MarketValue: "3100.00"},
+	}))
+
+	out, err := executeCommand(t, "holdings", "list", "--account", "acc_1")
+	require.NoError(t, err)
+	assert.Contains(t, out, "VTI")
+	assert.Contains(t, out, "3100.00")
+	assert.NotContains(t, out, "AAPL", "Only the latest snapshot should be shown")
+
+	out, err = executeCommand(t, "holdings", "list", "--account", "acc_1", "--run", "run_1")
+	require.NoError(t, err)
+	assert.Contains(t, out, "AAPL")
+
+	out, err = executeCommand(t, "holdings", "history", "--account", "acc_1")
+	require.NoError(t, err)
+	assert.Contains(t, out, "$3,100.00")
+	assert.Contains(t, out, "$3,500.50")
+
+	_, err = executeCommand(t, "holdings", "list")
+	assert.Error(t, err, "--account is required")
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/db"
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/spf13/cobra"
+)
+
+func newInstitutionsCommand() *cobra.Command {
+	institutionsCmd := &cobra.Command{
+		Use:   "institutions",
+		Short: "Inspect accounts and balances grouped by financial institution",
+	}
+
+	institutionsCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List institutions and when each last returned data",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbClient, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer dbClient.Close()
+
+			orgs, err := dbClient.ListOrganizations()
+			if err != nil {
+				return err
+			}
+
+			w := newTabWriter(cmd.OutOrStdout())
+			fmt.Fprintln(w, "ID\tNAME\tDOMAIN\tACCOUNTS\tLAST SEEN\tSTATUS")
+			for _, org := range orgs {
+				status := "ok"
+				if org.Stale {
+					status = "stale"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n",
+					org.ID, org.Name, org.Domain, org.Accounts, org.LastSeenAt.Local().Format(time.DateTime), status)
+			}
+			return w.Flush()
+		},
+	})
+
+	institutionsCmd.AddCommand(&cobra.Command{
+		Use:   "balances",
+		Short: "Show each account's latest balance grouped by institution, with subtotals",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbClient, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer dbClient.Close()
+
+			balances, err := dbClient.GetInstitutionBalances()
+			if err != nil {
+				return err
+			}
+			return printInstitutionBalances(cmd.OutOrStdout(), balances)
+		},
+	})
+	return institutionsCmd
+}
+
+// printInstitutionBalances writes one row per account followed by a subtotal
+// row for each currency the institution holds. Balances that cannot be parsed
+// are shown but left out of the subtotals.
+func printInstitutionBalances(out io.Writer, balances []db.InstitutionBalance) error {
+	w := newTabWriter(out)
+	fmt.Fprintln(w, "INSTITUTION\tACCOUNT\tNAME\tBALANCE\tCURRENCY\tRECORDED")
+
+	var currencies []model.Currency
+	subtotals := make(map[model.Currency]model.Money)
+	for i, balance := range balances {
+		recorded := "-"
+		if balance.RecordedAt != nil {
+			recorded = balance.RecordedAt.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			balance.OrganizationName, balance.BankAccountID, balance.BankAccountName, balance.Balance, balance.Currency, recorded)
+
+		currency := model.Currency(balance.Currency)
+		if amount, err := model.ParseMoney(balance.Balance, currency); err == nil {
+			subtotal, ok := subtotals[currency]
+			if !ok {
+				currencies = append(currencies, currency)
+				subtotal = model.NewMoneyFromMinor(0, currency)
+			}
+			if subtotals[currency], err = subtotal.Add(amount); err != nil {
+				return fmt.Errorf("failed to total %s: %w", balance.OrganizationName, err)
+			}
+		}
+
+		last := i == len(balances)-1
+		if last || balances[i+1].OrganizationName != balance.OrganizationName {
+			for _, currency := range currencies {
+				fmt.Fprintf(w, "%s\tTOTAL\t\t%s\t%s\t\n", balance.OrganizationName, subtotals[currency], currency)
+			}
+			currencies = nil
+			subtotals = make(map[model.Currency]model.Money)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstitutionsList(t *testing.T) {
+	dbClient := setupTestHome(t)
+	_, err := dbClient.PutOrganization(model.Organization{ID: "chase", Name: "Chase", Domain: "chase.com"}, "run_1")
+	require.NoError(t, err)
+	_, err = dbClient.PutOrganization(model.Organization{ID: "ally", Name: "Ally", Domain: "ally.com"}, "run_2")
+	require.NoError(t, err)
+
+	out, err := executeCommand(t, "institutions", "list")
+	require.NoError(t, err)
+	assert.Contains(t, out, "chase.com")
+	assert.Regexp(t, regexp.MustCompile(`Chase.*stale`), out)
+	assert.Regexp(t, regexp.MustCompile(`Ally.*ok`), out)
+}
+
+func TestInstitutionsBalances(t *testing.T) {
+	dbClient := setupTestHome(t)
+	var out bytes.Buffer
+	resp := &model.GetAccountsResponse{
+		Accounts: []model.Account{
+			{ID: "acc_1", Name: "Checking", Balance: "100.10", Org: model.Organization{ID: "chase", Name: "Chase"}},
+			{ID: "acc_2", Name: "Savings", Balance: "50.05", Org: model.Organization{ID: "chase", Name: "Chase"}},
+			{ID: "acc_3", Name: "Card", Balance: "-20.00", Org: model.Organization{ID: "ally", Name: "Ally"}},
+			{ID: "acc_4", Name: "US Checking", Currency: "USD", Balance: "10.00", Org: model.Organization{ID: "wise", Name: "Wise"}},
+			{ID: "acc_5", Name: "Euro Account", Currency: "EUR", Balance: "30.00", Org: model.Organization{ID: "wise", Name: "Wise"}},
+			{ID: "acc_6", Name: "US Savings", Currency: "USD", Balance: "5.50", Org: model.Organization{ID: "wise", Name: "Wise"}},
+		},
+	}
+	_, err := recordSync(&out, dbClient, "run_1", resp, nil)
+	require.NoError(t, err)
+
+	output, err := executeCommand(t, "institutions", "balances")
+	require.NoError(t, err)
+
+	assert.Regexp(t, regexp.MustCompile(`Chase\s+TOTAL\s+150.15`), output)
+	assert.Regexp(t, regexp.MustCompile(`Ally\s+TOTAL\s+-20.00`), output)
+	assert.Regexp(t, regexp.MustCompile(`Wise\s+TOTAL\s+30.00\s+EUR`), output, "Each currency should get its own subtotal")
+	assert.Regexp(t, regexp.MustCompile(`Wise\s+TOTAL\s+15.50\s+USD`), output)
+	assert.Less(t, strings.Index(output, "Ally"), strings.Index(output, "Chase"), "Institutions should be ordered by name")
+}
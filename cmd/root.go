@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"text/tabwriter"
+
+	"github.com/criswit/chi-chi-moni/config"
+	"github.com/spf13/cobra"
+)
+
+// cfg is the effective configuration, loaded before any command runs
+var cfg = config.Default()
+
+type rootFlags struct {
+	configPath string
+	profile    string
+	region     string
+	login      string
+	secretName string
+	dbPath     string
+}
+
+// NewRootCommand builds the full command tree
+func NewRootCommand(version string) *cobra.Command {
+	flags := &rootFlags{}
+	root := &cobra.Command{
+		Use:          "monies",
+		Short:        "Sync SimpleFIN accounts, balances and transactions into a local SQLite database",
+		Version:      version,
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return loadConfig(cmd, flags)
+		},
+	}
+
+	root.PersistentFlags().StringVar(&flags.configPath, "config", "", "config file (default ~/.config/chi-chi-moni/config.yaml)")
+	root.PersistentFlags().StringVar(&flags.profile, "profile", "", "AWS SSO profile")
+	root.PersistentFlags().StringVar(&flags.region, "region", "", "AWS region")
+	root.PersistentFlags().StringVar(&flags.login, "login", "", "how to renew an expired SSO session: browser, log, webhook or none")
+	root.PersistentFlags().StringVar(&flags.secretName, "secret-name", "", "name of the stored SimpleFIN access token")
+	root.PersistentFlags().StringVar(&flags.dbPath, "db", "", "SQLite database path")
+
+	root.AddCommand(
+		newSyncCommand(),
+		newBackfillCommand(),
+		newAccountsCommand(),
+		newInstitutionsCommand(),
+		newBalancesCommand(),
+		newTransactionsCommand(),
+		newHoldingsCommand(),
+		newRunsCommand(),
+		newSetupCommand(),
+		newSecretsCommand(),
+		newDBCommand(),
+	)
+	return root
+}
+
+// loadConfig layers defaults, the config file, CHICHIMONI_* environment
+// variables and explicitly set flags, then validates the result
+func loadConfig(cmd *cobra.Command, flags *rootFlags) error {
+	loaded, err := config.Load(flags.configPath)
+	if err != nil {
+		return err
+	}
+
+	overrides := map[string]struct {
+		value string
+		field *string
+	}{
+		"profile":     {flags.profile, &loaded.AWS.Profile},
+		"region":      {flags.region, &loaded.AWS.Region},
+		"login":       {flags.login, &loaded.AWS.Login},
+		"secret-name": {flags.secretName, &loaded.SecretName},
+		"db":          {flags.dbPath, &loaded.Database.Path},
+	}
+	for name, override := range overrides {
+		if cmd.Flags().Changed(name) {
+			*override.field = override.value
+		}
+	}
+
+	if err := loaded.Validate(); err != nil {
+		return err
+	}
+	cfg = loaded
+	return nil
+}
+
+// Execute runs the command tree with the given context, which is cancelled
+// when the process receives an interrupt
+func Execute(ctx context.Context, version string) error {
+	return NewRootCommand(version).ExecuteContext(ctx)
+}
+
+func newTabWriter(out io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+}
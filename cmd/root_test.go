@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/criswit/chi-chi-moni/config"
+	"github.com/criswit/chi-chi-moni/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// executeCommand runs the root command with args and returns its combined output
+func executeCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	root := NewRootCommand("test")
+	var out bytes.Buffer
+	root.SetOut(&out)
+	root.SetErr(&out)
+	root.SetArgs(args)
+	err := root.Execute()
+	return out.String(), err
+}
+
+// executeCommandWithInput is executeCommand with stdin read from input
+func executeCommandWithInput(t *testing.T, input string, args ...string) (string, error) {
+	t.Helper()
+	root := NewRootCommand("test")
+	var out bytes.Buffer
+	root.SetIn(strings.NewReader(input))
+	root.SetOut(&out)
+	root.SetErr(&out)
+	root.SetArgs(args)
+	err := root.Execute()
+	return out.String(), err
+}
+
+// setupTestHome points HOME at a temporary directory and opens the database
+// the commands will use
+func setupTestHome(t *testing.T) *db.DatabaseClient {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	cfg = config.Default()
+	dbClient, err := openDatabase()
+	require.NoError(t, err)
+	t.Cleanup(dbClient.Close)
+	return dbClient
+}
+
+func TestRootCommand(t *testing.T) {
+	root := NewRootCommand("test")
+
+	expected := [][]string{
+		{"sync"},
+		{"backfill"},
+		{"accounts", "list"},
+		{"accounts", "history"},
+		{"accounts", "reactivate"},
+		{"institutions", "list"},
+		{"institutions", "balances"},
+		{"balances", "history"},
+		{"balances", "current"},
+		{"transactions", "list"},
+		{"holdings", "list"},
+		{"holdings", "history"},
+		{"runs", "list"},
+		{"setup", "claim"},
+		{"secrets", "store"},
+		{"secrets", "rotate"},
+		{"secrets", "versions"},
+		{"secrets", "promote"},
+		{"secrets", "delete"},
+		{"secrets", "restore"},
+		{"db", "migrate"},
+	}
+	for _, path := range expected {
+		found, _, err := root.Find(path)
+		require.NoError(t, err, "command %v should exist", path)
+		assert.Equal(t, path[len(path)-1], found.Name())
+		assert.NotNil(t, found.RunE, "command %v should be runnable", path)
+	}
+}
+
+func TestRootCommand_Version(t *testing.T) {
+	out, err := executeCommand(t, "--version")
+	require.NoError(t, err)
+	assert.Contains(t, out, "test")
+}
+
+func TestRootCommand_UnknownCommand(t *testing.T) {
+	_, err := executeCommand(t, "does-not-exist")
+	assert.Error(t, err)
+}
+
+// TestGetDbFilePath tests the getDbFilePath function
+func TestGetDbFilePath(t *testing.T) {
+	tests := []struct {
+		name     string
+		home     string
+		wantPath string
+	}{
+		{
+			name: "valid_home_directory",
+		},
+		{
+			name:     "custom_home_directory",
+			home:     "/custom/home",
+			wantPath: "/custom/home/data/monk.db",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.home != "" {
+				t.Setenv("HOME", tt.home)
+			}
+
+			path, err := getDbFilePath()
+
+			assert.NoError(t, err)
+			assert.NotEmpty(t, path)
+			assert.Contains(t, path, "data/monk.db")
+			if tt.wantPath != "" {
+				assert.Equal(t, tt.wantPath, path)
+			}
+		})
+	}
+}
+
+// TestOpenDatabase tests that the database directory is created on first use
+func TestOpenDatabase(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cfg = config.Default()
+
+	dbClient, err := openDatabase()
+	require.NoError(t, err)
+	defer dbClient.Close()
+
+	_, err = os.Stat(filepath.Join(home, "data", "monk.db"))
+	assert.NoError(t, err)
+}
+
+// TestLoadConfig tests that flags override environment variables, which
+// override the config file
+func TestLoadConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("CHICHIMONI_CONFIG", "")
+	t.Cleanup(func() { cfg = config.Default() })
+
+	configPath := filepath.Join(home, "config.yaml")
+	content := `aws:
+  profile: file-profile
+  region: eu-west-1
+secret_name: file-secret
+database:
+  path: ` + filepath.Join(home, "file.db") + `
+`
+	require.NoError(t, os.WriteFile(configPath, []byte(content), 0600))
+	t.Setenv("CHICHIMONI_SECRET_NAME", "env-secret")
+	t.Setenv("CHICHIMONI_AWS_REGION", "us-west-2")
+
+	_, err := executeCommand(t, "--config", configPath, "--region", "ap-southeast-2", "db", "migrate")
+	require.NoError(t, err)
+
+	assert.Equal(t, "file-profile", cfg.AWS.Profile, "File value should apply when not overridden")
+	assert.Equal(t, "env-secret", cfg.SecretName, "Environment should override the file")
+	assert.Equal(t, "ap-southeast-2", cfg.AWS.Region, "Flags should override the environment")
+	assert.Equal(t, filepath.Join(home, "file.db"), cfg.Database.Path)
+	assert.FileExists(t, filepath.Join(home, "file.db"))
+}
+
+func TestLoadConfig_InvalidField(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Cleanup(func() { cfg = config.Default() })
+
+	_, err := executeCommand(t, "--region", "mars-1", "accounts", "list")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "aws.region")
+}
+
+func TestLoadConfig_MissingExplicitFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Cleanup(func() { cfg = config.Default() })
+
+	_, err := executeCommand(t, "--config", "/does/not/exist.yaml", "accounts", "list")
+	assert.Error(t, err)
+}
+
+func BenchmarkGetDbFilePath(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_, _ = getDbFilePath()
+	}
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/db"
+	"github.com/spf13/cobra"
+)
+
+func newRunsCommand() *cobra.Command {
+	runsCmd := &cobra.Command{
+		Use:   "runs",
+		Short: "Inspect the sync run ledger",
+	}
+
+	var limit int
+	var connection string
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "Show recent sync runs, newest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbClient, err := openConnectionDatabase(connection)
+			if err != nil {
+				return err
+			}
+			defer dbClient.Close()
+
+			runs, err := dbClient.ListSyncRuns(limit)
+			if err != nil {
+				return err
+			}
+
+			w := newTabWriter(cmd.OutOrStdout())
+			fmt.Fprintln(w, "STARTED\tRUN\tTENANT\tSTATUS\tDURATION\tACCOUNTS\tBALANCES\tNEW\tUPDATED\tWINDOW\tERROR")
+			for _, run := range runs {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\t%s\n",
+					run.StartedAt.Local().Format(time.DateTime),
+					run.ID,
+					formatRunTenant(run),
+					run.Status,
+					formatRunDuration(run),
+					run.AccountsSeen,
+					run.BalancesWritten,
+					run.TransactionsInserted,
+					run.TransactionsUpdated,
+					formatRunWindow(run),
+					run.Error,
+				)
+			}
+			return w.Flush()
+		},
+	}
+	listCmd.Flags().IntVar(&limit, "limit", 20, "maximum number of runs to show (0 for all)")
+	listCmd.Flags().StringVar(&connection, "connection", "", "only show the named connection's runs")
+
+	runsCmd.AddCommand(listCmd)
+	return runsCmd
+}
+
+func formatRunDuration(run db.SyncRunRecord) string {
+	if run.FinishedAt == nil {
+		return "-"
+	}
+	return run.Duration().Round(time.Millisecond).String()
+}
+
+// formatRunTenant shows the tenant of the connection the run synced, or "-"
+// for the single unnamed one
+func formatRunTenant(run db.SyncRunRecord) string {
+	if run.Tenant == "" {
+		return "-"
+	}
+	return run.Tenant
+}
+
+// formatRunWindow shows the request window as start..end dates, with "*"
+// for a bound left to the bridge default
+func formatRunWindow(run db.SyncRunRecord) string {
+	format := func(epoch *int64) string {
+		if epoch == nil {
+			return "*"
+		}
+		return time.Unix(*epoch, 0).UTC().Format(time.DateOnly)
+	}
+	return format(run.WindowStart) + ".." + format(run.WindowEnd)
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/criswit/chi-chi-moni/api"
+	"github.com/criswit/chi-chi-moni/credstore"
+	"github.com/spf13/cobra"
+)
+
+// The claim flow's external dependencies besides openCredentialStore, replaced in tests
+var (
+	claimSetupToken = func(ctx context.Context, setupToken string) (api.AccessToken, error) {
+		return api.NewAccessTokenResolver(setupToken).ResolveContext(ctx)
+	}
+	validateAccessToken = func(ctx context.Context, accessToken api.AccessToken) (int, error) {
+		finClient, err := api.NewSimpleFinClient(accessToken)
+		if err != nil {
+			return 0, err
+		}
+		resp, err := finClient.GetAccountsContext(ctx, &api.GetAccountsOptions{BalancesOnly: true})
+		if err != nil {
+			return 0, err
+		}
+		return len(resp.Accounts), nil
+	}
+)
+
+func newSetupCommand() *cobra.Command {
+	setupCmd := &cobra.Command{
+		Use:   "setup",
+		Short: "Connect the tool to a SimpleFIN account",
+	}
+
+	var force bool
+	claimCmd := &cobra.Command{
+		Use:   "claim <setup-token>",
+		Short: "Claim a SimpleFIN setup token and store the access token",
+		Long: "Claim a SimpleFIN setup token, check the resulting credentials with a\n" +
+			"balances-only request, then store them under the configured secret name.\n" +
+			"A setup token can only be claimed once, so an existing secret is checked\n" +
+			"before claiming and is never overwritten unless --force is given.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			store, err := openCredentialStore(ctx)
+			if err != nil {
+				return err
+			}
+			if !force {
+				exists, err := credstore.Exists(ctx, store, cfg.SecretName)
+				if err != nil {
+					return err
+				}
+				if exists {
+					return fmt.Errorf("secret %s already exists, pass --force to overwrite it or use secrets rotate", cfg.SecretName)
+				}
+			}
+			return claimAndStore(ctx, cmd.OutOrStdout(), cmd.ErrOrStderr(), store, args[0], cfg.SecretName)
+		},
+	}
+	claimCmd.Flags().BoolVar(&force, "force", false, "overwrite an existing secret")
+
+	setupCmd.AddCommand(claimCmd)
+	return setupCmd
+}
+
+// claimAndStore claims the setup token exactly once, validates the credentials
+// and stores them. Once the claim succeeds the setup token is spent, so if a
+// later step fails the access URL is printed to errOut rather than lost.
+func claimAndStore(ctx context.Context, out io.Writer, errOut io.Writer, store credstore.CredentialStore, setupToken string, secretName string) error {
+	accessToken, err := claimSetupToken(ctx, setupToken)
+	if err != nil {
+		return fmt.Errorf("failed to claim setup token: %w", err)
+	}
+
+	accountCount, err := validateAccessToken(ctx, accessToken)
+	if err != nil {
+		printUnsavedAccessUrl(errOut, accessToken)
+		return fmt.Errorf("claimed access token failed validation: %w", err)
+	}
+	fmt.Fprintf(out, "Access token is valid: %d accounts visible\n", accountCount)
+
+	if err := store.Put(ctx, secretName, accessToken); err != nil {
+		printUnsavedAccessUrl(errOut, accessToken)
+		return err
+	}
+	fmt.Fprintf(out, "Stored SimpleFIN access token in secret %s\n", secretName)
+	return nil
+}
+
+func printUnsavedAccessUrl(w io.Writer, accessToken api.AccessToken) {
+	fmt.Fprintln(w, "The setup token has been claimed and cannot be claimed again.")
+	fmt.Fprintln(w, "Keep this access URL and store it with `monies secrets store` once the problem is fixed:")
+	fmt.Fprintf(w, "  %s\n", accessToken.AccessUrl())
+}
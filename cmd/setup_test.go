@@ -0,0 +1,573 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/api"
+	"github.com/criswit/chi-chi-moni/config"
+	"github.com/criswit/chi-chi-moni/credstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAccessTokenStore struct {
+	secrets  map[string]api.AccessToken
+	storeErr error
+}
+
+func (f *fakeAccessTokenStore) Get(ctx context.Context, name string) (api.AccessToken, error) {
+	token, ok := f.secrets[name]
+	if !ok {
+		return api.AccessToken{}, credstore.ErrNotFound
+	}
+	return token, nil
+}
+
+func (f *fakeAccessTokenStore) Put(ctx context.Context, name string, token api.AccessToken) error {
+	if f.storeErr != nil {
+		return f.storeErr
+	}
+	f.secrets[name] = token
+	return nil
+}
+
+func (f *fakeAccessTokenStore) Delete(ctx context.Context, name string) error {
+	if _, ok := f.secrets[name]; !ok {
+		return credstore.ErrNotFound
+	}
+	delete(f.secrets, name)
+	return nil
+}
+
+func (f *fakeAccessTokenStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	for name := range f.secrets {
+		if strings.Contains(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// fakeVersionedStore keeps every token it is given as a version, moving stage
+// labels the way Secrets Manager does
+type fakeVersionedStore struct {
+	*fakeAccessTokenStore
+	versions []credstore.Version
+	tokens   map[string]api.AccessToken
+}
+
+func (f *fakeVersionedStore) Stage(ctx context.Context, name string, token api.AccessToken) (string, error) {
+	if f.storeErr != nil {
+		return "", f.storeErr
+	}
+	id := fmt.Sprintf("v%d", len(f.versions)+1)
+	f.moveStage(credstore.StagePending, "")
+	f.versions = append(f.versions, credstore.Version{ID: id, Stages: []string{credstore.StagePending}, CreatedAt: time.Now()})
+	f.tokens[id] = token
+	return id, nil
+}
+
+func (f *fakeVersionedStore) GetVersion(ctx context.Context, name string, versionID string) (api.AccessToken, error) {
+	token, ok := f.tokens[versionID]
+	if !ok {
+		return api.AccessToken{}, credstore.ErrNotFound
+	}
+	return token, nil
+}
+
+func (f *fakeVersionedStore) Promote(ctx context.Context, name string, versionID string) error {
+	if _, ok := f.tokens[versionID]; !ok {
+		return fmt.Errorf("no version %s", versionID)
+	}
+	for _, version := range f.versions {
+		if slices.Contains(version.Stages, credstore.StageCurrent) && version.ID != versionID {
+			f.moveStage(credstore.StagePrevious, version.ID)
+		}
+	}
+	f.moveStage(credstore.StageCurrent, versionID)
+	for i := range f.versions {
+		if f.versions[i].ID == versionID {
+			f.versions[i].Stages = slices.DeleteFunc(f.versions[i].Stages, func(stage string) bool { return stage == credstore.StagePending })
+		}
+	}
+	f.secrets[name] = f.tokens[versionID]
+	return nil
+}
+
+func (f *fakeVersionedStore) Versions(ctx context.Context, name string) ([]credstore.Version, error) {
+	versions := slices.Clone(f.versions)
+	slices.Reverse(versions)
+	return versions, nil
+}
+
+// moveStage removes stage from every version and adds it to versionID, if set
+func (f *fakeVersionedStore) moveStage(stage string, versionID string) {
+	for i := range f.versions {
+		f.versions[i].Stages = slices.DeleteFunc(f.versions[i].Stages, func(s string) bool { return s == stage })
+		if f.versions[i].ID == versionID {
+			f.versions[i].Stages = append(f.versions[i].Stages, stage)
+		}
+	}
+}
+
+// fakeRecoverableStore schedules deletions instead of deleting at once
+type fakeRecoverableStore struct {
+	*fakeAccessTokenStore
+	info      credstore.Info
+	deletions []credstore.DeleteOptions
+	restored  []string
+}
+
+func (f *fakeRecoverableStore) Describe(ctx context.Context, name string) (credstore.Info, error) {
+	if _, ok := f.secrets[name]; !ok {
+		return credstore.Info{}, credstore.ErrNotFound
+	}
+	info := f.info
+	info.Name = name
+	return info, nil
+}
+
+func (f *fakeRecoverableStore) DeleteWithOptions(ctx context.Context, name string, opts credstore.DeleteOptions) (time.Time, error) {
+	f.deletions = append(f.deletions, opts)
+	if opts.Force {
+		return time.Now(), f.Delete(ctx, name)
+	}
+	deletionDate := time.Date(2024, 7, 1, 12, 0, 0, 0, time.Local)
+	f.info.DeletionDate = &deletionDate
+	return deletionDate, nil
+}
+
+func (f *fakeRecoverableStore) Restore(ctx context.Context, name string) error {
+	f.restored = append(f.restored, name)
+	f.info.DeletionDate = nil
+	return nil
+}
+
+// useRecoverableStore wraps the claim fakes' store in a fakeRecoverableStore
+// holding an existing secret
+func useRecoverableStore(t *testing.T, store *fakeAccessTokenStore) *fakeRecoverableStore {
+	t.Helper()
+	store.secrets[config.DefaultSecretName] = api.AccessToken{Username: "old"}
+	lastAccessed := time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local)
+	recoverable := &fakeRecoverableStore{
+		fakeAccessTokenStore: store,
+		info: credstore.Info{
+			ARN:          "arn:aws:secretsmanager:us-east-1:123456789012:secret:monk-monies-AbCdEf",
+			LastAccessed: &lastAccessed,
+		},
+	}
+	openCredentialStore = func(ctx context.Context) (credstore.CredentialStore, error) {
+		return recoverable, nil
+	}
+	return recoverable
+}
+
+// useVersionedStore wraps the claim fakes' store in a fakeVersionedStore whose
+// first version holds the existing secret
+func useVersionedStore(t *testing.T, store *fakeAccessTokenStore, existing api.AccessToken) *fakeVersionedStore {
+	t.Helper()
+	store.secrets[config.DefaultSecretName] = existing
+	versioned := &fakeVersionedStore{
+		fakeAccessTokenStore: store,
+		versions: []credstore.Version{{
+			ID:        "v1",
+			Stages:    []string{credstore.StageCurrent},
+			CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local),
+		}},
+		tokens: map[string]api.AccessToken{"v1": existing},
+	}
+	openCredentialStore = func(ctx context.Context) (credstore.CredentialStore, error) {
+		return versioned, nil
+	}
+	return versioned
+}
+
+var claimedToken = api.AccessToken{Username: "user", Password: "pass", Url: "bridge.example.com/simplefin"}
+
+// setupClaimFakes replaces the claim flow's dependencies and returns the fake
+// store and a pointer to the number of claims made
+func setupClaimFakes(t *testing.T, validateErr error) (*fakeAccessTokenStore, *int) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	cfg = config.Default()
+
+	store := &fakeAccessTokenStore{secrets: map[string]api.AccessToken{}}
+	claims := 0
+
+	originalOpen, originalClaim, originalValidate := openCredentialStore, claimSetupToken, validateAccessToken
+	t.Cleanup(func() {
+		openCredentialStore, claimSetupToken, validateAccessToken = originalOpen, originalClaim, originalValidate
+	})
+
+	openCredentialStore = func(ctx context.Context) (credstore.CredentialStore, error) {
+		return store, nil
+	}
+	claimSetupToken = func(ctx context.Context, setupToken string) (api.AccessToken, error) {
+		claims++
+		return claimedToken, nil
+	}
+	validateAccessToken = func(ctx context.Context, accessToken api.AccessToken) (int, error) {
+		if validateErr != nil {
+			return 0, validateErr
+		}
+		return 3, nil
+	}
+	return store, &claims
+}
+
+func TestSetupClaim(t *testing.T) {
+	t.Run("stores_validated_token", func(t *testing.T) {
+		store, claims := setupClaimFakes(t, nil)
+
+		out, err := executeCommand(t, "setup", "claim", "setup-token")
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, *claims)
+		assert.Equal(t, claimedToken, store.secrets[config.DefaultSecretName])
+		assert.Contains(t, out, "3 accounts")
+	})
+
+	t.Run("refuses_existing_secret_without_claiming", func(t *testing.T) {
+		store, claims := setupClaimFakes(t, nil)
+		existing := api.AccessToken{Username: "old", Password: "old", Url: "old.example.com"}
+		store.secrets[config.DefaultSecretName] = existing
+
+		_, err := executeCommand(t, "setup", "claim", "setup-token")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--force")
+
+		assert.Equal(t, 0, *claims, "Setup token must not be spent when the secret already exists")
+		assert.Equal(t, existing, store.secrets[config.DefaultSecretName])
+	})
+
+	t.Run("force_overwrites_existing_secret", func(t *testing.T) {
+		store, claims := setupClaimFakes(t, nil)
+		store.secrets[config.DefaultSecretName] = api.AccessToken{Username: "old"}
+
+		_, err := executeCommand(t, "setup", "claim", "--force", "setup-token")
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, *claims)
+		assert.Equal(t, claimedToken, store.secrets[config.DefaultSecretName])
+	})
+
+	t.Run("validation_failure_does_not_store", func(t *testing.T) {
+		store, claims := setupClaimFakes(t, errors.New("bridge unavailable"))
+
+		out, err := executeCommand(t, "setup", "claim", "setup-token")
+		require.Error(t, err)
+
+		assert.Equal(t, 1, *claims)
+		assert.Empty(t, store.secrets)
+		assert.Contains(t, out, claimedToken.AccessUrl(), "Spent credentials should be shown so they are not lost")
+	})
+
+	t.Run("store_failure_prints_access_url", func(t *testing.T) {
+		store, _ := setupClaimFakes(t, nil)
+		store.storeErr = errors.New("access denied")
+
+		out, err := executeCommand(t, "setup", "claim", "setup-token")
+		require.Error(t, err)
+		assert.Contains(t, out, claimedToken.AccessUrl())
+	})
+}
+
+func TestSecretsRotate(t *testing.T) {
+	t.Run("requires_existing_secret", func(t *testing.T) {
+		_, claims := setupClaimFakes(t, nil)
+
+		_, err := executeCommand(t, "secrets", "rotate", "setup-token")
+		require.Error(t, err)
+		assert.Equal(t, 0, *claims)
+	})
+
+	t.Run("replaces_existing_secret", func(t *testing.T) {
+		store, claims := setupClaimFakes(t, nil)
+		store.secrets[config.DefaultSecretName] = api.AccessToken{Username: "old"}
+
+		_, err := executeCommand(t, "secrets", "rotate", "setup-token")
+		require.NoError(t, err)
+		assert.Equal(t, 1, *claims)
+		assert.Equal(t, claimedToken, store.secrets[config.DefaultSecretName])
+	})
+}
+
+func TestSecretsRotate_Versioned(t *testing.T) {
+	oldToken := api.AccessToken{Username: "old", Password: "old", Url: "old.example.com"}
+
+	t.Run("stages_validates_and_promotes", func(t *testing.T) {
+		store, claims := setupClaimFakes(t, nil)
+		versioned := useVersionedStore(t, store, oldToken)
+
+		out, err := executeCommand(t, "secrets", "rotate", "setup-token")
+		require.NoError(t, err)
+		assert.Equal(t, 1, *claims)
+		assert.Contains(t, out, "Stored new access token as AWSPENDING version v2")
+		assert.Contains(t, out, "Promoted version v2 to AWSCURRENT")
+
+		assert.Equal(t, claimedToken, store.secrets[config.DefaultSecretName])
+		assert.Equal(t, []string{credstore.StagePrevious}, versioned.versions[0].Stages)
+		assert.Equal(t, []string{credstore.StageCurrent}, versioned.versions[1].Stages)
+	})
+
+	t.Run("validation_failure_leaves_current_token", func(t *testing.T) {
+		store, _ := setupClaimFakes(t, errors.New("bridge unavailable"))
+		versioned := useVersionedStore(t, store, oldToken)
+
+		out, err := executeCommand(t, "secrets", "rotate", "setup-token")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "secrets promote v2")
+		assert.NotContains(t, out, claimedToken.AccessUrl(), "The claimed token is stored, so it need not be printed")
+
+		assert.Equal(t, oldToken, store.secrets[config.DefaultSecretName])
+		assert.Equal(t, []string{credstore.StageCurrent}, versioned.versions[0].Stages)
+		assert.Equal(t, []string{credstore.StagePending}, versioned.versions[1].Stages)
+		assert.Equal(t, claimedToken, versioned.tokens["v2"])
+	})
+
+	t.Run("stage_failure_prints_access_url", func(t *testing.T) {
+		store, _ := setupClaimFakes(t, nil)
+		useVersionedStore(t, store, oldToken)
+		store.storeErr = errors.New("access denied")
+
+		out, err := executeCommand(t, "secrets", "rotate", "setup-token")
+		require.Error(t, err)
+		assert.Contains(t, out, claimedToken.AccessUrl())
+		assert.Equal(t, oldToken, store.secrets[config.DefaultSecretName])
+	})
+}
+
+func TestSecretsVersionsAndPromote(t *testing.T) {
+	oldToken := api.AccessToken{Username: "old", Password: "old", Url: "old.example.com"}
+	store, _ := setupClaimFakes(t, nil)
+	versioned := useVersionedStore(t, store, oldToken)
+	lastAccessed := time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local)
+	versioned.versions[0].LastAccessed = &lastAccessed
+
+	_, err := executeCommand(t, "secrets", "rotate", "setup-token")
+	require.NoError(t, err)
+
+	out, err := executeCommand(t, "secrets", "versions")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3)
+	assert.Regexp(t, `^VERSION\s+STAGES\s+CREATED\s+LAST ACCESSED$`, lines[0])
+	assert.Regexp(t, `^v2\s+AWSCURRENT\s+\S+ \S+\s+never$`, lines[1])
+	assert.Regexp(t, `^v1\s+AWSPREVIOUS\s+2024-01-02 03:04:05\s+2024-02-01$`, lines[2])
+
+	// Roll back to the replaced token
+	out, err = executeCommand(t, "secrets", "promote", "v1")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Promoted version v1")
+	assert.Equal(t, oldToken, store.secrets[config.DefaultSecretName])
+}
+
+func TestSecretsVersions_Unversioned(t *testing.T) {
+	setupClaimFakes(t, nil)
+
+	_, err := executeCommand(t, "secrets", "versions")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not keep versions")
+}
+
+func TestSecretsDelete(t *testing.T) {
+	t.Run("shows_details_and_schedules_deletion", func(t *testing.T) {
+		store, _ := setupClaimFakes(t, nil)
+		recoverable := useRecoverableStore(t, store)
+
+		out, err := executeCommandWithInput(t, config.DefaultSecretName+"\n", "secrets", "delete")
+		require.NoError(t, err)
+		assert.Contains(t, out, "ARN:           arn:aws:secretsmanager:us-east-1:123456789012:secret:monk-monies-AbCdEf")
+		assert.Contains(t, out, "Last accessed: 2024-06-01")
+		assert.Contains(t, out, "deleted after 30 days")
+		assert.Contains(t, out, "Scheduled secret monk-monies for deletion on 2024-07-01")
+		assert.Equal(t, []credstore.DeleteOptions{{RecoveryWindowDays: 30}}, recoverable.deletions)
+	})
+
+	t.Run("wrong_confirmation_aborts", func(t *testing.T) {
+		store, _ := setupClaimFakes(t, nil)
+		recoverable := useRecoverableStore(t, store)
+
+		out, err := executeCommandWithInput(t, "monk-moneys\n", "secrets", "delete")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not confirmed")
+		assert.Contains(t, out, "ARN:", "Details must be shown before asking")
+		assert.Empty(t, recoverable.deletions)
+	})
+
+	t.Run("force_skips_recovery_window", func(t *testing.T) {
+		store, _ := setupClaimFakes(t, nil)
+		recoverable := useRecoverableStore(t, store)
+
+		out, err := executeCommand(t, "secrets", "delete", "--force", "--yes")
+		require.NoError(t, err)
+		assert.Contains(t, out, "deleted permanently")
+		assert.Equal(t, []credstore.DeleteOptions{{RecoveryWindowDays: 30, Force: true}}, recoverable.deletions)
+		assert.Empty(t, store.secrets)
+	})
+
+	t.Run("already_scheduled", func(t *testing.T) {
+		store, _ := setupClaimFakes(t, nil)
+		recoverable := useRecoverableStore(t, store)
+		deletionDate := time.Date(2024, 7, 1, 12, 0, 0, 0, time.Local)
+		recoverable.info.DeletionDate = &deletionDate
+
+		_, err := executeCommand(t, "secrets", "delete", "--yes")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already scheduled for deletion on 2024-07-01")
+		assert.Empty(t, recoverable.deletions)
+	})
+
+	t.Run("recovery_window_out_of_range", func(t *testing.T) {
+		store, _ := setupClaimFakes(t, nil)
+		recoverable := useRecoverableStore(t, store)
+
+		_, err := executeCommand(t, "secrets", "delete", "--yes", "--recovery-window", "3")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "7 to 30 days")
+		assert.Empty(t, recoverable.deletions)
+	})
+
+	t.Run("unrecoverable_backend_deletes_permanently", func(t *testing.T) {
+		store, _ := setupClaimFakes(t, nil)
+		store.secrets["other"] = api.AccessToken{Username: "other"}
+
+		out, err := executeCommandWithInput(t, "other\n", "secrets", "delete", "other")
+		require.NoError(t, err)
+		assert.Contains(t, out, "deleted permanently")
+		assert.NotContains(t, store.secrets, "other")
+	})
+
+	t.Run("missing_secret", func(t *testing.T) {
+		setupClaimFakes(t, nil)
+
+		_, err := executeCommand(t, "secrets", "delete", "--yes")
+		assert.ErrorIs(t, err, credstore.ErrNotFound)
+	})
+}
+
+func TestSecretsRestore(t *testing.T) {
+	t.Run("restores_scheduled_secret", func(t *testing.T) {
+		store, _ := setupClaimFakes(t, nil)
+		recoverable := useRecoverableStore(t, store)
+
+		out, err := executeCommand(t, "secrets", "restore")
+		require.NoError(t, err)
+		assert.Contains(t, out, "Restored secret monk-monies")
+		assert.Equal(t, []string{config.DefaultSecretName}, recoverable.restored)
+	})
+
+	t.Run("unrecoverable_backend", func(t *testing.T) {
+		setupClaimFakes(t, nil)
+
+		_, err := executeCommand(t, "secrets", "restore")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot restore")
+	})
+}
+
+func TestSecretsStore(t *testing.T) {
+	store, claims := setupClaimFakes(t, nil)
+
+	root := NewRootCommand("test")
+	root.SetIn(strings.NewReader(claimedToken.AccessUrl() + "\n"))
+	root.SetArgs([]string{"secrets", "store"})
+	root.SetOut(&strings.Builder{})
+	require.NoError(t, root.Execute())
+
+	assert.Equal(t, 0, *claims)
+	assert.Equal(t, claimedToken, store.secrets[config.DefaultSecretName])
+}
+
+func TestOpenCredentialStore(t *testing.T) {
+	t.Run("env", func(t *testing.T) {
+		setupTestHome(t)
+		cfg.Credentials.Backend = config.CredentialsEnv
+		t.Setenv("CHICHIMONI_ACCESS_URL_MONK_MONIES", claimedToken.AccessUrl())
+
+		token, err := getAccessToken(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, claimedToken, token)
+	})
+
+	t.Run("env_named_connection", func(t *testing.T) {
+		setupTestHome(t)
+		cfg.Credentials.Backend = config.CredentialsEnv
+		cfg.Connections = []config.ConnectionConfig{{Name: "business", SecretName: "business-monies"}}
+		connections, err := cfg.ResolveConnections(nil)
+		require.NoError(t, err)
+		cfg = connections[0].Config
+		t.Setenv("CHICHIMONI_ACCESS_URL", claimedToken.AccessUrl())
+
+		_, err = getAccessToken(context.Background())
+		assert.ErrorIs(t, err, credstore.ErrNotFound, "A named connection must not use the generic variable")
+
+		t.Setenv("CHICHIMONI_ACCESS_URL_BUSINESS_MONIES", claimedToken.AccessUrl())
+		token, err := getAccessToken(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, claimedToken, token)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		setupTestHome(t)
+		cfg.Credentials.Backend = config.CredentialsFile
+		t.Setenv(config.EnvCredentialsPassphrase, "correct horse")
+
+		configPath := filepath.Join(os.Getenv("HOME"), "config.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte("credentials:\n  backend: file\n"), 0600))
+		root := NewRootCommand("test")
+		root.SetIn(strings.NewReader(claimedToken.AccessUrl() + "\n"))
+		root.SetArgs([]string{"secrets", "store", "--config", configPath})
+		root.SetOut(&strings.Builder{})
+		require.NoError(t, root.Execute())
+
+		_, err := os.Stat(filepath.Join(os.Getenv("HOME"), ".config", "chi-chi-moni", "credentials.enc"))
+		require.NoError(t, err)
+
+		token, err := getAccessToken(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, claimedToken, token)
+	})
+
+	t.Run("file_without_passphrase", func(t *testing.T) {
+		setupTestHome(t)
+		cfg.Credentials.Backend = config.CredentialsFile
+		t.Setenv(config.EnvCredentialsPassphrase, "")
+		os.Unsetenv(config.EnvCredentialsPassphrase)
+
+		_, err := getAccessToken(context.Background())
+		assert.ErrorContains(t, err, config.EnvCredentialsPassphrase)
+	})
+}
+
+func TestLoginOptions(t *testing.T) {
+	options := loginOptions(config.AWSConfig{Login: config.LoginNone})
+	assert.True(t, options.Disabled)
+
+	options = loginOptions(config.AWSConfig{Login: config.LoginLog, LoginTimeout: time.Minute})
+	assert.False(t, options.Disabled)
+	assert.NotNil(t, options.Notify)
+	assert.Equal(t, time.Minute, options.Timeout)
+
+	options = loginOptions(config.AWSConfig{Login: config.LoginWebhook, LoginWebhook: "https://hooks.example.com/sso"})
+	assert.NotNil(t, options.Notify)
+}
+
+func TestLoginFlag(t *testing.T) {
+	setupTestHome(t)
+	_, err := executeCommand(t, "--login", "none", "runs", "list")
+	require.NoError(t, err)
+	assert.Equal(t, config.LoginNone, cfg.AWS.Login)
+
+	_, err = executeCommand(t, "--login", "popup", "runs", "list")
+	assert.ErrorContains(t, err, "aws.login")
+}
@@ -0,0 +1,472 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/api"
+	"github.com/criswit/chi-chi-moni/config"
+	"github.com/criswit/chi-chi-moni/db"
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+func newSyncCommand() *cobra.Command {
+	var full bool
+	var strategy string
+	var connections []string
+	syncCmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Fetch accounts from SimpleFIN and record balances and transactions",
+		Long: `Fetch accounts from SimpleFIN and record balances and transactions.
+
+Transactions are requested from the oldest per-account watermark (the newest
+stored posted transaction) less sync.overlap. A run that finds an account
+whose history has not been fetched yet, such as a newly linked one, is
+followed by one run over the bridge's default window. Use --full to request
+the default window anyway.
+
+With sync.strategy (or --strategy) set to per_account, accounts are first
+listed with balances only, then fetched one at a time by sync.concurrency
+workers, each from its own watermark less sync.overlap. The listing and each
+request are limited to sync.account_timeout. An account that fails or times
+out keeps its listed balance and makes the run partial.
+
+When the config defines connections, each is synced in turn with its own
+access token, AWS profile and database; --connection limits the run to the
+named ones. A failing connection does not stop the others, and a summary of
+every connection is printed at the end.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().Changed("strategy") {
+				cfg.Sync.Strategy = strategy
+				if err := cfg.Validate(); err != nil {
+					return err
+				}
+			}
+			return runSyncConnections(cmd.Context(), cmd.OutOrStdout(), connections, full)
+		},
+	}
+	syncCmd.Flags().BoolVar(&full, "full", false, "ignore watermarks and request the bridge's default window")
+	syncCmd.Flags().StringVar(&strategy, "strategy", "", "how to request accounts: single or per_account (default sync.strategy)")
+	syncCmd.Flags().StringSliceVar(&connections, "connection", nil, "sync only the named connection (repeatable)")
+	return syncCmd
+}
+
+// connectionResult is the outcome of syncing or backfilling one connection
+type connectionResult struct {
+	name  string
+	stats db.SyncRunStats
+	err   error
+}
+
+// runSyncConnections syncs the selected connections one after another. Each
+// runs with its resolved settings as the effective configuration, and its
+// failure is recorded in the summary rather than stopping the others.
+func runSyncConnections(ctx context.Context, out io.Writer, names []string, full bool) error {
+	connections, err := cfg.ResolveConnections(names)
+	if err != nil {
+		return err
+	}
+	if len(cfg.Connections) == 0 {
+		_, err := runSync(ctx, out, connections[0].Tenant, full)
+		return err
+	}
+
+	base := cfg
+	defer func() { cfg = base }()
+
+	var results []connectionResult
+	for _, conn := range connections {
+		result := connectionResult{name: conn.Name}
+		if result.err = ctx.Err(); result.err == nil {
+			fmt.Fprintf(out, "== %s ==\n", conn.Name)
+			cfg = conn.Config
+			result.stats, result.err = runSync(ctx, out, conn.Tenant, full)
+		}
+		results = append(results, result)
+	}
+
+	w := newTabWriter(out)
+	fmt.Fprintln(w, "CONNECTION\tSTATUS\tACCOUNTS\tNEW\tUPDATED\tERROR")
+	for _, result := range results {
+		var errText string
+		if result.err != nil {
+			errText = result.err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\n",
+			result.name,
+			syncRunStatus(result.err),
+			result.stats.AccountsSeen,
+			result.stats.TransactionsInserted,
+			result.stats.TransactionsUpdated,
+			errText,
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return connectionsError(results)
+}
+
+// connectionsError combines the errors of every connection that did not sync
+// cleanly. The exit code is ExitBridgeErrors when all of them were partial
+// runs, otherwise that of the first connection that failed outright.
+func connectionsError(results []connectionResult) error {
+	var errs []error
+	code := ExitBridgeErrors
+	for _, result := range results {
+		if result.err == nil {
+			continue
+		}
+		errs = append(errs, fmt.Errorf("connection %s: %w", result.name, result.err))
+		if resultCode := ExitCode(result.err); resultCode != ExitBridgeErrors && code == ExitBridgeErrors {
+			code = resultCode
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ExitError{
+		Code: code,
+		Err:  fmt.Errorf("%d of %d connection(s) had errors: %w", len(errs), len(results), errors.Join(errs...)),
+	}
+}
+
+// runSync records one run for tenant against the database and access token
+// of the effective configuration
+func runSync(ctx context.Context, out io.Writer, tenant string, full bool) (db.SyncRunStats, error) {
+	dbClient, err := openDatabase()
+	if err != nil {
+		return db.SyncRunStats{}, err
+	}
+	defer dbClient.Close()
+	dbClient = dbClient.WithTenant(tenant)
+
+	runId := uuid.New().String()
+	opts := &api.GetAccountsOptions{Pending: cfg.Sync.Pending}
+	var starts map[string]*int64
+	if !full {
+		if opts.StartDate, err = dbClient.SyncWindowStart(cfg.Sync.Overlap); err != nil {
+			return db.SyncRunStats{}, err
+		}
+		if cfg.Sync.Strategy == config.SyncPerAccount {
+			if starts, err = dbClient.AccountWindowStarts(cfg.Sync.Overlap, opts.StartDate); err != nil {
+				return db.SyncRunStats{}, err
+			}
+		}
+	}
+	if err := dbClient.BeginSyncRun(runId, opts.StartDate, opts.EndDate); err != nil {
+		return db.SyncRunStats{}, err
+	}
+
+	stats, err := fetchAndRecord(ctx, out, dbClient, runId, opts, starts)
+	if finishErr := dbClient.FinishSyncRun(runId, syncRunStatus(err), stats, err); finishErr != nil {
+		return stats, errors.Join(err, finishErr)
+	}
+	return stats, err
+}
+
+// fetchAndRecord fetches the accounts with the configured strategy and stores
+// them. starts holds the per-account windows of the per_account strategy, see
+// fetchPerAccount.
+func fetchAndRecord(ctx context.Context, out io.Writer, dbClient *db.DatabaseClient, runId string, opts *api.GetAccountsOptions, starts map[string]*int64) (db.SyncRunStats, error) {
+	fetcher, err := newAccountsFetcher(ctx)
+	if err != nil {
+		return db.SyncRunStats{}, err
+	}
+
+	var getAccountsResp *model.GetAccountsResponse
+	var fullHistory map[string]bool
+	if cfg.Sync.Strategy == config.SyncPerAccount {
+		getAccountsResp, fullHistory, err = fetchPerAccount(ctx, fetcher, opts, starts)
+	} else {
+		getAccountsResp, err = fetcher.GetAccountsContext(ctx, opts)
+		if err == nil {
+			fullHistory = historyFetched(getAccountsResp, opts.StartDate)
+		}
+	}
+	if err != nil {
+		return db.SyncRunStats{}, withAuthHint(err)
+	}
+
+	return recordSync(out, dbClient, runId, getAccountsResp, fullHistory)
+}
+
+// historyFetched returns the IDs of the accounts in resp whose complete
+// history it holds: all of them when it was requested from the bridge's
+// default window, unless the bridge reported errors, since an institution
+// that failed may have returned its accounts without transactions
+func historyFetched(resp *model.GetAccountsResponse, start *int64) map[string]bool {
+	if start != nil || len(resp.Errors) > 0 {
+		return nil
+	}
+	fullHistory := make(map[string]bool, len(resp.Accounts))
+	for _, account := range resp.Accounts {
+		fullHistory[account.ID] = true
+	}
+	return fullHistory
+}
+
+// accountFetch is the outcome of fetching one account on its own
+type accountFetch struct {
+	resp    *model.GetAccountsResponse
+	account model.Account
+	err     error
+}
+
+// fetchPerAccount lists every account with balances only, then fetches each
+// account's transactions and holdings with at most sync.concurrency requests
+// in flight. The listing and each fetch are limited to sync.account_timeout.
+// Each account is fetched from its own start in starts; an account missing
+// from starts gets the bridge's default window. An account whose fetch fails
+// keeps its listed balance and the failure is added to the bridge errors, so
+// the run is partial rather than failed. The merged response is stored by the
+// caller on a single goroutine. It also returns the accounts whose complete
+// history was fetched, see historyFetched.
+func fetchPerAccount(ctx context.Context, fetcher accountsFetcher, opts *api.GetAccountsOptions, starts map[string]*int64) (*model.GetAccountsResponse, map[string]bool, error) {
+	timeout := cfg.Sync.AccountTimeout
+	listing, err := listAccounts(ctx, fetcher, opts, timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fetches := make([]accountFetch, len(listing.Accounts))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(cfg.Sync.Concurrency, len(listing.Accounts)) {
+		wg.Go(func() {
+			for i := range jobs {
+				accountId := listing.Accounts[i].ID
+				fetches[i] = fetchAccount(ctx, fetcher, accountId, starts[accountId], opts, timeout)
+			}
+		})
+	}
+	for i := range listing.Accounts {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	merged := &model.GetAccountsResponse{
+		Errors:      listing.Errors,
+		XAPIMessage: listing.XAPIMessage,
+	}
+	fullHistory := make(map[string]bool)
+	for i, fetch := range fetches {
+		if fetch.err != nil {
+			listed := listing.Accounts[i]
+			merged.Accounts = append(merged.Accounts, listed)
+			merged.Errors = append(merged.Errors, fmt.Sprintf("Failed to fetch transactions for %s (%s): %v", listed.Name, listed.ID, fetch.err))
+			continue
+		}
+		merged.Accounts = append(merged.Accounts, fetch.account)
+		if historyFetched(fetch.resp, starts[fetch.account.ID])[fetch.account.ID] {
+			fullHistory[fetch.account.ID] = true
+		}
+		merged.Errors = appendNew(merged.Errors, fetch.resp.Errors...)
+		merged.XAPIMessage = appendNew(merged.XAPIMessage, fetch.resp.XAPIMessage...)
+	}
+	return merged, fullHistory, nil
+}
+
+// listAccounts requests every account with balances only, giving up after
+// timeout if it is positive
+func listAccounts(ctx context.Context, fetcher accountsFetcher, opts *api.GetAccountsOptions, timeout time.Duration) (*model.GetAccountsResponse, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	listOpts := *opts
+	listOpts.BalancesOnly = true
+	listing, err := fetcher.GetAccountsContext(ctx, &listOpts)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("listing accounts timed out after %s", timeout)
+	}
+	return listing, err
+}
+
+// fetchAccount requests a single account from start, giving up after timeout
+// if it is positive
+func fetchAccount(ctx context.Context, fetcher accountsFetcher, accountId string, start *int64, opts *api.GetAccountsOptions, timeout time.Duration) accountFetch {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	accountOpts := *opts
+	accountOpts.StartDate = start
+	accountOpts.AccountIDs = []string{accountId}
+	resp, err := fetcher.GetAccountsContext(ctx, &accountOpts)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+		return accountFetch{err: err}
+	}
+	for _, account := range resp.Accounts {
+		if account.ID == accountId {
+			return accountFetch{resp: resp, account: account}
+		}
+	}
+	return accountFetch{err: errors.New("account missing from the response")}
+}
+
+// appendNew appends the messages not already in messages. Every per-account
+// response repeats the bridge's connection-level messages.
+func appendNew(messages []string, more ...string) []string {
+	for _, message := range more {
+		if !slices.Contains(messages, message) {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// withAuthHint points the user at secrets rotate when the bridge has revoked the access token
+func withAuthHint(err error) error {
+	if errors.Is(err, api.ErrAuthRevoked) {
+		return fmt.Errorf("%w; claim a new setup token with 'monies secrets rotate <setup-token>'", err)
+	}
+	return err
+}
+
+// syncRunStatus maps the outcome of a run to the status stored in SYNC_RUN
+func syncRunStatus(err error) string {
+	switch {
+	case err == nil:
+		return db.RunStatusSuccess
+	case ExitCode(err) == ExitBridgeErrors:
+		return db.RunStatusPartial
+	default:
+		return db.RunStatusFailed
+	}
+}
+
+// recordSync stores the accounts, balances, transactions and bridge messages
+// from one response in a single database transaction, so a run that fails
+// part way leaves nothing under its run ID, and then prints the run summary.
+// Accounts in fullHistory are marked as having their complete history. It
+// returns an *ExitError with ExitBridgeErrors when the bridge reported
+// account-level errors.
+func recordSync(out io.Writer, dbClient *db.DatabaseClient, runId string, resp *model.GetAccountsResponse, fullHistory map[string]bool) (db.SyncRunStats, error) {
+	tx, err := dbClient.Begin()
+	if err != nil {
+		return db.SyncRunStats{}, err
+	}
+	defer tx.Rollback()
+
+	stats, accountChanges, expired, err := recordSyncTx(tx, runId, resp, fullHistory)
+	if err != nil {
+		return db.SyncRunStats{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return db.SyncRunStats{}, err
+	}
+
+	fmt.Fprintf(out, "Run %s: %d accounts, %d new transactions, %d updated\n",
+		runId, stats.AccountsSeen, stats.TransactionsInserted, stats.TransactionsUpdated)
+	for _, change := range accountChanges {
+		fmt.Fprintf(out, "Account changed: %s\n", change)
+	}
+	if expired > 0 {
+		fmt.Fprintf(out, "Expired %d pending transaction(s) that never posted\n", expired)
+	}
+	for _, message := range resp.XAPIMessage {
+		fmt.Fprintf(out, "SimpleFIN message: %s\n", message)
+	}
+	for _, message := range resp.Errors {
+		fmt.Fprintf(out, "SimpleFIN error: %s\n", message)
+	}
+
+	if len(resp.Errors) > 0 {
+		return stats, &ExitError{
+			Code: ExitBridgeErrors,
+			Err:  fmt.Errorf("simplefin reported %d account error(s) in run %s", len(resp.Errors), runId),
+		}
+	}
+	return stats, nil
+}
+
+// recordSyncTx makes the writes of recordSync within tx. It returns what was
+// written, the account changes and how many pending transactions expired.
+func recordSyncTx(tx *db.Tx, runId string, resp *model.GetAccountsResponse, fullHistory map[string]bool) (db.SyncRunStats, []db.AccountChange, int, error) {
+	var stats db.SyncRunStats
+	if err := tx.PutRunMessages(runId, db.RunMessageError, resp.Errors); err != nil {
+		return stats, nil, 0, err
+	}
+	if err := tx.PutRunMessages(runId, db.RunMessageAPIMessage, resp.XAPIMessage); err != nil {
+		return stats, nil, 0, err
+	}
+
+	var seen []string
+	var accountChanges []db.AccountChange
+	for _, account := range resp.Accounts {
+		stats.AccountsSeen++
+		seen = append(seen, account.ID)
+		_, changes, err := tx.UpsertBankAccount(account, runId)
+		if err != nil {
+			return stats, nil, 0, err
+		}
+		accountChanges = append(accountChanges, changes...)
+		if db.OrganizationID(account.Org) != "" {
+			orgId, err := tx.PutOrganization(account.Org, runId)
+			if err != nil {
+				return stats, nil, 0, err
+			}
+			if err := tx.SetBankAccountOrganization(account.ID, orgId); err != nil {
+				return stats, nil, 0, err
+			}
+		}
+
+		if err := tx.PutAccountBalance(account.ID, runId, account.Balance); err != nil {
+			return stats, nil, 0, err
+		}
+		stats.BalancesWritten++
+
+		if err := tx.PutHoldings(account.ID, runId, account.Currency, account.Holdings); err != nil {
+			return stats, nil, 0, err
+		}
+
+		accountInserted, accountUpdated, err := tx.PutTransactions(account.ID, account.Transactions)
+		stats.TransactionsInserted += accountInserted
+		stats.TransactionsUpdated += accountUpdated
+		if err != nil {
+			return stats, nil, 0, err
+		}
+		if err := tx.AdvanceWatermark(account.ID, account.Transactions); err != nil {
+			return stats, nil, 0, err
+		}
+		if fullHistory[account.ID] {
+			if err := tx.MarkHistorySynced(account.ID, runId); err != nil {
+				return stats, nil, 0, err
+			}
+		}
+	}
+
+	// An account can be absent because its institution failed on the bridge
+	// side, so a run with bridge errors does not count as missed
+	if len(resp.Errors) == 0 {
+		missing, err := tx.MarkMissingAccounts(runId, seen, cfg.Sync.CloseAfterMissedRuns)
+		if err != nil {
+			return stats, nil, 0, err
+		}
+		accountChanges = append(accountChanges, missing...)
+	}
+
+	expired, err := tx.ExpirePendingTransactions(time.Now().Add(-cfg.Sync.PendingExpiry), resp.Accounts)
+	if err != nil {
+		return stats, nil, 0, err
+	}
+	return stats, accountChanges, expired, nil
+}
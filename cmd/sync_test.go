@@ -0,0 +1,627 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/api"
+	"github.com/criswit/chi-chi-moni/aws"
+	"github.com/criswit/chi-chi-moni/config"
+	"github.com/criswit/chi-chi-moni/db"
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testAccountsResponse() *model.GetAccountsResponse {
+	return &model.GetAccountsResponse{
+		Accounts: []model.Account{
+			{
+				ID:      "acc_1",
+				Name:    "Checking",
+				Balance: "100.00",
+				Org:     model.Organization{Name: "Test Bank"},
+				Transactions: []model.Transaction{
+					{ID: "txn_1", Posted: 1704067200, Amount: "-4.50"},
+				},
+			},
+			{
+				ID:       "acc_2",
+				Name:     "Brokerage",
+				Currency: "USD",
+				Balance:  "2500.00",
+				Org:      model.Organization{Name: "Test Bank"},
+				Holdings: []model.Holding{
+					{ID: "h_1", Symbol: "VTI", Shares: "10", MarketValue: "2500.00"},
+				},
+			},
+		},
+	}
+}
+
+func TestRecordSync(t *testing.T) {
+	dbClient := setupTestHome(t)
+	resp := testAccountsResponse()
+	resp.XAPIMessage = []string{"Scheduled maintenance on Sunday"}
+
+	var out bytes.Buffer
+	stats, err := recordSync(&out, dbClient, "run_1", resp, nil)
+	require.NoError(t, err)
+	assert.Equal(t, db.SyncRunStats{AccountsSeen: 2, BalancesWritten: 2, TransactionsInserted: 1}, stats)
+
+	holdings, err := dbClient.GetHoldings("acc_2", "run_1")
+	require.NoError(t, err)
+	require.Len(t, holdings, 1)
+	assert.Equal(t, "VTI", holdings[0].Symbol)
+	assert.Equal(t, "USD", holdings[0].Currency)
+
+	assert.Contains(t, out.String(), "Run run_1: 2 accounts, 1 new transactions, 0 updated")
+	assert.Contains(t, out.String(), "SimpleFIN message: Scheduled maintenance on Sunday")
+	assert.NotContains(t, out.String(), "SimpleFIN error")
+
+	messages, err := dbClient.ListRunMessages("run_1")
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, db.RunMessageAPIMessage, messages[0].Kind)
+}
+
+func TestRecordSync_BridgeErrors(t *testing.T) {
+	dbClient := setupTestHome(t)
+	resp := testAccountsResponse()
+	resp.Errors = []string{"Connection to Test Bank may need attention"}
+
+	var out bytes.Buffer
+	stats, err := recordSync(&out, dbClient, "run_1", resp, nil)
+	require.Error(t, err)
+	assert.Equal(t, ExitBridgeErrors, ExitCode(err))
+	assert.Equal(t, db.RunStatusPartial, syncRunStatus(err))
+	assert.Equal(t, 2, stats.BalancesWritten)
+	assert.Contains(t, out.String(), "SimpleFIN error: Connection to Test Bank may need attention")
+
+	// Data is still stored when the bridge reports errors
+	balances, err := dbClient.GetBalanceHistory("acc_1", 0)
+	require.NoError(t, err)
+	assert.Len(t, balances, 1)
+
+	messages, err := dbClient.ListRunMessages("run_1")
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, db.RunMessageError, messages[0].Kind)
+	assert.Equal(t, "Connection to Test Bank may need attention", messages[0].Message)
+}
+
+func TestRecordSync_AtomicRun(t *testing.T) {
+	dbClient := setupTestHome(t)
+	dbPath, err := getDbFilePath()
+	require.NoError(t, err)
+	raw, err := sqlx.Connect("sqlite3", dbPath)
+	require.NoError(t, err)
+	defer raw.Close()
+	_, err = raw.Exec(`CREATE TRIGGER fail_acc_2 BEFORE INSERT ON BANK_ACCOUNT_BALANCE
+		WHEN NEW.BANK_ACCOUNT_ID = 'acc_2' BEGIN SELECT RAISE(ABORT, 'disk full'); END`)
+	require.NoError(t, err)
+
+	resp := testAccountsResponse()
+	resp.XAPIMessage = []string{"Scheduled maintenance on Sunday"}
+	var out bytes.Buffer
+	stats, err := recordSync(&out, dbClient, "run_1", resp, nil)
+	require.ErrorContains(t, err, "disk full")
+	assert.Equal(t, db.SyncRunStats{}, stats, "Nothing was stored")
+	assert.Empty(t, out.String())
+
+	// The first account's writes were rolled back with the second's
+	accounts, err := dbClient.ListBankAccounts()
+	require.NoError(t, err)
+	assert.Empty(t, accounts)
+	balances, err := dbClient.GetBalanceHistory("acc_1", 0)
+	require.NoError(t, err)
+	assert.Empty(t, balances)
+	transactions, err := dbClient.ListTransactions(db.TransactionFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, transactions)
+	messages, err := dbClient.ListRunMessages("run_1")
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+
+	_, err = raw.Exec("DROP TRIGGER fail_acc_2")
+	require.NoError(t, err)
+	stats, err = recordSync(&out, dbClient, "run_2", resp, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.BalancesWritten)
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, ExitOK, ExitCode(nil))
+	assert.Equal(t, ExitFailure, ExitCode(errors.New("boom")))
+
+	wrapped := &ExitError{Code: ExitBridgeErrors, Err: errors.New("bridge errors")}
+	assert.Equal(t, ExitBridgeErrors, ExitCode(wrapped))
+	assert.Equal(t, ExitBridgeErrors, ExitCode(errors.Join(errors.New("context"), wrapped)))
+
+	loginErr := fmt.Errorf("SSO authentication failed: %w", aws.ErrLoginTimeout)
+	assert.Equal(t, ExitLoginRequired, ExitCode(loginErr))
+}
+
+func TestSyncRunStatus(t *testing.T) {
+	assert.Equal(t, db.RunStatusSuccess, syncRunStatus(nil))
+	assert.Equal(t, db.RunStatusFailed, syncRunStatus(errors.New("bridge down")))
+	assert.Equal(t, db.RunStatusPartial, syncRunStatus(&ExitError{Code: ExitBridgeErrors, Err: errors.New("account errors")}))
+}
+
+func TestRunsList(t *testing.T) {
+	dbClient := setupTestHome(t)
+	start := int64(1704067200)
+	require.NoError(t, dbClient.BeginSyncRun("run_1", &start, nil))
+	require.NoError(t, dbClient.FinishSyncRun("run_1", db.RunStatusSuccess, db.SyncRunStats{AccountsSeen: 3, TransactionsInserted: 7}, nil))
+	require.NoError(t, dbClient.BeginSyncRun("run_2", nil, nil))
+	require.NoError(t, dbClient.FinishSyncRun("run_2", db.RunStatusFailed, db.SyncRunStats{}, errors.New("bridge down")))
+
+	out, err := executeCommand(t, "runs", "list")
+	require.NoError(t, err)
+	assert.Contains(t, out, "STATUS")
+	assert.Contains(t, out, "run_1")
+	assert.Contains(t, out, "success")
+	assert.Contains(t, out, "2024-01-01..*")
+	assert.Contains(t, out, "failed")
+	assert.Contains(t, out, "bridge down")
+
+	out, err = executeCommand(t, "runs", "list", "--limit", "1")
+	require.NoError(t, err)
+	assert.Contains(t, out, "run_2", "Newest run should be shown first")
+	assert.NotContains(t, out, "run_1")
+}
+
+func TestRecordSync_AccountLifecycle(t *testing.T) {
+	dbClient := setupTestHome(t)
+	cfg.Sync.CloseAfterMissedRuns = 2
+
+	_, err := recordSync(&bytes.Buffer{}, dbClient, "run_1", testAccountsResponse(), nil)
+	require.NoError(t, err)
+
+	renamed := testAccountsResponse()
+	renamed.Accounts = renamed.Accounts[:1]
+	renamed.Accounts[0].Name = "Everyday Checking"
+	var out bytes.Buffer
+	_, err = recordSync(&out, dbClient, "run_2", renamed, nil)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), `acc_1 NAME: "Checking" -> "Everyday Checking"`)
+	assert.Contains(t, out.String(), `acc_2 STATUS: "active" -> "missing"`)
+
+	out.Reset()
+	_, err = recordSync(&out, dbClient, "run_3", renamed, nil)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), `acc_2 STATUS: "missing" -> "closed"`)
+}
+
+func TestRecordSync_BridgeErrorsDoNotCountMissedRuns(t *testing.T) {
+	dbClient := setupTestHome(t)
+	cfg.Sync.CloseAfterMissedRuns = 1
+
+	_, err := recordSync(&bytes.Buffer{}, dbClient, "run_1", testAccountsResponse(), nil)
+	require.NoError(t, err)
+
+	outage := testAccountsResponse()
+	outage.Accounts = outage.Accounts[:1]
+	outage.Errors = []string{"Connection to Test Bank may need attention"}
+	var out bytes.Buffer
+	_, err = recordSync(&out, dbClient, "run_2", outage, nil)
+	require.Error(t, err)
+	assert.NotContains(t, out.String(), "acc_2 STATUS")
+
+	accounts, err := dbClient.ListBankAccounts()
+	require.NoError(t, err)
+	for _, account := range accounts {
+		assert.Equal(t, db.AccountStatusActive, account.Status, "%s should stay active through a bridge outage", account.ID)
+		assert.Zero(t, account.MissedRuns)
+	}
+}
+
+func TestRecordSync_AdvancesWatermark(t *testing.T) {
+	dbClient := setupTestHome(t)
+
+	_, err := recordSync(&bytes.Buffer{}, dbClient, "run_1", testAccountsResponse(), nil)
+	require.NoError(t, err)
+
+	posted, ok, err := dbClient.GetWatermark("acc_1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, int64(1704067200), posted)
+}
+
+func TestSync_IncrementalWindow(t *testing.T) {
+	dbClient := setupTestHome(t)
+	resp := testAccountsResponse()
+	fetcher := &fakeFetcher{respond: func(opts *api.GetAccountsOptions) (*model.GetAccountsResponse, error) {
+		return resp, nil
+	}}
+	useFakeFetcher(t, fetcher)
+	incremental := int64(1704067200) - int64(cfg.Sync.Overlap.Seconds())
+
+	_, err := runSync(context.Background(), &bytes.Buffer{}, "", false)
+	require.NoError(t, err)
+	start, err := dbClient.SyncWindowStart(cfg.Sync.Overlap)
+	require.NoError(t, err)
+	require.NotNil(t, start, "The brokerage account without transactions does not hold the window back")
+	assert.Equal(t, incremental, *start)
+
+	// A newly linked account is fetched from the default window once
+	resp.Accounts = append(resp.Accounts, model.Account{ID: "acc_3", Name: "New Card", Balance: "0.00"})
+	for range 3 {
+		_, err = runSync(context.Background(), &bytes.Buffer{}, "", false)
+		require.NoError(t, err)
+	}
+
+	require.Len(t, fetcher.requests, 4)
+	assert.Nil(t, fetcher.requests[0].StartDate, "The first run requests the default window")
+	require.NotNil(t, fetcher.requests[1].StartDate)
+	assert.Equal(t, incremental, *fetcher.requests[1].StartDate, "acc_3 was only seen within the incremental window")
+	assert.Nil(t, fetcher.requests[2].StartDate, "acc_3's history has not been fetched yet")
+	require.NotNil(t, fetcher.requests[3].StartDate)
+	assert.Equal(t, incremental, *fetcher.requests[3].StartDate)
+}
+
+func TestRecordSync_PendingTransactions(t *testing.T) {
+	dbClient := setupTestHome(t)
+	now := time.Now().Unix()
+
+	resp := testAccountsResponse()
+	resp.Accounts[0].Transactions = append(resp.Accounts[0].Transactions,
+		model.Transaction{ID: "pending_1", TransactedAt: now, Amount: "-12.00", Payee: "Grocer", Pending: true},
+		model.Transaction{ID: "pending_old", TransactedAt: now - 30*86400, Amount: "-1.00", Payee: "Parking", Pending: true},
+	)
+	var out bytes.Buffer
+	_, err := recordSync(&out, dbClient, "run_1", resp, nil)
+	require.NoError(t, err)
+	assert.NotContains(t, out.String(), "Expired", "A pending transaction the bridge still returns stays open")
+
+	posted, _, err := dbClient.GetWatermark("acc_1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1704067200), posted, "Pending transactions must not advance the watermark")
+
+	output, err := executeCommand(t, "balances", "current")
+	require.NoError(t, err)
+	assert.Contains(t, output, "-13.00 (2)")
+	assert.Contains(t, output, "87.00")
+
+	// The posted version arrives under a new ID and replaces the pending one,
+	// and the old pending transaction is no longer returned
+	resp.Accounts[0].Transactions = []model.Transaction{
+		{ID: "posted_1", Posted: now + 86400, Amount: "-12.00", Payee: "Grocer"},
+	}
+	out.Reset()
+	_, err = recordSync(&out, dbClient, "run_2", resp, nil)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "Expired 1 pending transaction(s) that never posted")
+
+	output, err = executeCommand(t, "balances", "current")
+	require.NoError(t, err)
+	assert.Contains(t, output, "0.00 (0)")
+	assert.NotContains(t, output, "88.00")
+}
+
+// useFetcherPerSecret serves each connection from the response or error
+// registered for the secret name it syncs with
+func useFetcherPerSecret(t *testing.T, respond map[string]func() (*model.GetAccountsResponse, error)) {
+	t.Helper()
+	original := newAccountsFetcher
+	newAccountsFetcher = func(ctx context.Context) (accountsFetcher, error) {
+		secretName := cfg.SecretName
+		return &fakeFetcher{respond: func(opts *api.GetAccountsOptions) (*model.GetAccountsResponse, error) {
+			return respond[secretName]()
+		}}, nil
+	}
+	t.Cleanup(func() { newAccountsFetcher = original })
+}
+
+func TestSync_Connections(t *testing.T) {
+	dbClient := setupTestHome(t)
+	t.Cleanup(func() { cfg = config.Default() })
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	configPath := filepath.Join(home, "config.yaml")
+	content := `connections:
+  - name: personal
+  - name: business
+    secret_name: business-monies
+  - name: parents
+    secret_name: parents-monies
+    database:
+      path: ` + filepath.Join(home, "parents.db") + `
+`
+	require.NoError(t, os.WriteFile(configPath, []byte(content), 0600))
+
+	businessErr := errors.New("bridge down")
+	useFetcherPerSecret(t, map[string]func() (*model.GetAccountsResponse, error){
+		"monk-monies": func() (*model.GetAccountsResponse, error) {
+			return testAccountsResponse(), nil
+		},
+		"business-monies": func() (*model.GetAccountsResponse, error) {
+			if businessErr != nil {
+				return nil, businessErr
+			}
+			return &model.GetAccountsResponse{Accounts: []model.Account{{ID: "acc_business", Name: "Operating", Balance: "10.00"}}}, nil
+		},
+		"parents-monies": func() (*model.GetAccountsResponse, error) {
+			resp := testAccountsResponse()
+			resp.Accounts = resp.Accounts[:1]
+			resp.Errors = []string{"Connection to Test Bank may need attention"}
+			return resp, nil
+		},
+	})
+
+	out, err := executeCommand(t, "--config", configPath, "sync")
+	require.Error(t, err)
+	assert.Equal(t, ExitFailure, ExitCode(err), "An outright failure outranks partial runs")
+	assert.Contains(t, err.Error(), "2 of 3 connection(s) had errors")
+	assert.Contains(t, err.Error(), "connection business: bridge down")
+	assert.Contains(t, out, "== personal ==")
+	assert.Contains(t, out, "CONNECTION")
+	assert.Regexp(t, `personal\s+success\s+2\s+1\s+0`, out)
+	assert.Regexp(t, `business\s+failed\s+0\s+0\s+0\s+bridge down`, out)
+	assert.Regexp(t, `parents\s+partial\s+1\s+1\s+0`, out)
+	assert.Equal(t, "monk-monies", cfg.SecretName, "The base configuration is restored")
+
+	parentsDb, err := db.NewDatabaseClient(filepath.Join(home, "parents.db"))
+	require.NoError(t, err)
+	defer parentsDb.Close()
+	runs, err := parentsDb.ListSyncRuns(0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, "parents", runs[0].Tenant)
+
+	// A retry of the failed connection alone leaves the others' accounts be
+	businessErr = nil
+	out, err = executeCommand(t, "--config", configPath, "sync", "--connection", "business")
+	require.NoError(t, err)
+	assert.NotContains(t, out, "== personal ==")
+	assert.Regexp(t, `business\s+success\s+1\s+0\s+0`, out)
+
+	accounts, err := dbClient.ListBankAccounts()
+	require.NoError(t, err)
+	require.Len(t, accounts, 3)
+	for _, account := range accounts {
+		assert.Equal(t, db.AccountStatusActive, account.Status, account.ID)
+	}
+}
+
+func TestSync_UnknownConnection(t *testing.T) {
+	setupTestHome(t)
+
+	_, err := executeCommand(t, "sync", "--connection", "business")
+	assert.ErrorContains(t, err, `unknown connection "business"`)
+}
+
+func TestReadCommands_Connection(t *testing.T) {
+	dbClient := setupTestHome(t)
+	t.Cleanup(func() { cfg = config.Default() })
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	configPath := filepath.Join(home, "config.yaml")
+	content := `connections:
+  - name: personal
+  - name: business
+    tenant: acme
+`
+	require.NoError(t, os.WriteFile(configPath, []byte(content), 0600))
+
+	personal := dbClient.WithTenant("personal")
+	business := dbClient.WithTenant("acme")
+	_, err = recordSync(&bytes.Buffer{}, personal, "run_personal", testAccountsResponse(), nil)
+	require.NoError(t, err)
+	require.NoError(t, personal.BeginSyncRun("run_personal", nil, nil))
+	_, err = recordSync(&bytes.Buffer{}, business, "run_business", &model.GetAccountsResponse{
+		Accounts: []model.Account{{ID: "acc_business", Name: "Operating", Balance: "10.00"}},
+	}, nil)
+	require.NoError(t, err)
+	require.NoError(t, business.BeginSyncRun("run_business", nil, nil))
+
+	out, err := executeCommand(t, "--config", configPath, "runs", "list")
+	require.NoError(t, err)
+	assert.Contains(t, out, "TENANT")
+	assert.Regexp(t, `run_personal\s+personal\s`, out)
+	assert.Regexp(t, `run_business\s+acme\s`, out)
+
+	out, err = executeCommand(t, "--config", configPath, "runs", "list", "--connection", "business")
+	require.NoError(t, err)
+	assert.Contains(t, out, "run_business")
+	assert.NotContains(t, out, "run_personal")
+
+	out, err = executeCommand(t, "--config", configPath, "accounts", "list", "--connection", "business")
+	require.NoError(t, err)
+	assert.Contains(t, out, "acc_business")
+	assert.NotContains(t, out, "acc_1")
+
+	out, err = executeCommand(t, "--config", configPath, "balances", "current", "--connection", "business")
+	require.NoError(t, err)
+	assert.Contains(t, out, "acc_business")
+	assert.NotContains(t, out, "acc_1")
+
+	out, err = executeCommand(t, "--config", configPath, "balances", "history", "--connection", "personal")
+	require.NoError(t, err)
+	assert.Contains(t, out, "acc_1")
+	assert.NotContains(t, out, "acc_business")
+
+	out, err = executeCommand(t, "--config", configPath, "transactions", "list", "--connection", "business")
+	require.NoError(t, err)
+	assert.NotContains(t, out, "acc_1")
+	out, err = executeCommand(t, "--config", configPath, "transactions", "list", "--connection", "personal")
+	require.NoError(t, err)
+	assert.Contains(t, out, "acc_1")
+
+	_, err = executeCommand(t, "--config", configPath, "accounts", "list", "--connection", "parents")
+	assert.ErrorContains(t, err, `unknown connection "parents"`)
+}
+
+// fetcherFunc adapts a function to accountsFetcher
+type fetcherFunc func(ctx context.Context, opts *api.GetAccountsOptions) (*model.GetAccountsResponse, error)
+
+func (f fetcherFunc) GetAccountsContext(ctx context.Context, opts *api.GetAccountsOptions) (*model.GetAccountsResponse, error) {
+	return f(ctx, opts)
+}
+
+func TestSync_PerAccount(t *testing.T) {
+	dbClient := setupTestHome(t)
+	cfg.Sync.Strategy = config.SyncPerAccount
+	cfg.Sync.Concurrency = 2
+	cfg.Sync.AccountTimeout = 50 * time.Millisecond
+
+	listed := testAccountsResponse()
+	listed.Accounts = append(listed.Accounts, model.Account{ID: "acc_3", Name: "Savings", Balance: "900.00"})
+	for i := range listed.Accounts {
+		listed.Accounts[i].Transactions = nil
+		listed.Accounts[i].Holdings = nil
+	}
+
+	var mu sync.Mutex
+	var requests []api.GetAccountsOptions
+	var inFlight, maxInFlight int
+	fetcher := fetcherFunc(func(ctx context.Context, opts *api.GetAccountsOptions) (*model.GetAccountsResponse, error) {
+		mu.Lock()
+		requests = append(requests, *opts)
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+
+		if opts.BalancesOnly {
+			return listed, nil
+		}
+		if len(opts.AccountIDs) != 1 {
+			return nil, fmt.Errorf("expected one account, got %v", opts.AccountIDs)
+		}
+		if opts.AccountIDs[0] == "acc_3" {
+			// A slow institution is given up on without holding up the others
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		time.Sleep(10 * time.Millisecond)
+		resp := testAccountsResponse()
+		resp.XAPIMessage = []string{"Scheduled maintenance on Sunday"}
+		for _, account := range resp.Accounts {
+			if account.ID == opts.AccountIDs[0] {
+				resp.Accounts = []model.Account{account}
+			}
+		}
+		return resp, nil
+	})
+	original := newAccountsFetcher
+	newAccountsFetcher = func(ctx context.Context) (accountsFetcher, error) { return fetcher, nil }
+	t.Cleanup(func() { newAccountsFetcher = original })
+
+	var out bytes.Buffer
+	stats, err := runSync(context.Background(), &out, "", true)
+	require.Error(t, err)
+	assert.Equal(t, ExitBridgeErrors, ExitCode(err))
+	assert.Equal(t, db.SyncRunStats{AccountsSeen: 3, BalancesWritten: 3, TransactionsInserted: 1}, stats)
+	assert.Contains(t, out.String(), "SimpleFIN error: Failed to fetch transactions for Savings (acc_3): timed out after 50ms")
+	assert.Equal(t, 1, strings.Count(out.String(), "SimpleFIN message: Scheduled maintenance on Sunday"),
+		"Messages repeated by every account are reported once")
+
+	require.Len(t, requests, 4)
+	assert.True(t, requests[0].BalancesOnly, "Accounts are listed first")
+	assert.LessOrEqual(t, maxInFlight, 2)
+
+	holdings, err := dbClient.GetHoldings("acc_2", "")
+	require.NoError(t, err)
+	assert.Len(t, holdings, 1)
+	balances, err := dbClient.GetBalanceHistory("acc_3", 0)
+	require.NoError(t, err)
+	require.Len(t, balances, 1)
+	assert.Equal(t, "900.00", balances[0].Balance, "The listed balance is kept when the fetch fails")
+	_, ok, err := dbClient.GetWatermark("acc_3")
+	require.NoError(t, err)
+	assert.False(t, ok, "The failed account is fetched again next run")
+	start, err := dbClient.SyncWindowStart(cfg.Sync.Overlap)
+	require.NoError(t, err)
+	assert.Nil(t, start, "The failed account's history has not been fetched")
+}
+
+func TestSync_PerAccountWindows(t *testing.T) {
+	dbClient := setupTestHome(t)
+	cfg.Sync.Strategy = config.SyncPerAccount
+	cfg.Sync.Overlap = time.Hour
+
+	listed := testAccountsResponse()
+	var mu sync.Mutex
+	starts := make(map[string]*int64)
+	fetcher := fetcherFunc(func(ctx context.Context, opts *api.GetAccountsOptions) (*model.GetAccountsResponse, error) {
+		if opts.BalancesOnly {
+			return listed, nil
+		}
+		mu.Lock()
+		starts[opts.AccountIDs[0]] = opts.StartDate
+		mu.Unlock()
+		for _, account := range listed.Accounts {
+			if account.ID == opts.AccountIDs[0] {
+				return &model.GetAccountsResponse{Accounts: []model.Account{account}}, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown account %s", opts.AccountIDs[0])
+	})
+	original := newAccountsFetcher
+	newAccountsFetcher = func(ctx context.Context) (accountsFetcher, error) { return fetcher, nil }
+	t.Cleanup(func() { newAccountsFetcher = original })
+
+	_, err := runSync(context.Background(), &bytes.Buffer{}, "", false)
+	require.NoError(t, err)
+	assert.Nil(t, starts["acc_1"])
+	assert.Nil(t, starts["acc_2"])
+
+	// acc_1 starts from its own watermark, the brokerage account has no
+	// transactions and uses the run's window, and a new account gets its history
+	listed.Accounts = append(listed.Accounts, model.Account{ID: "acc_3", Name: "Savings", Balance: "900.00"})
+	clear(starts)
+	_, err = runSync(context.Background(), &bytes.Buffer{}, "", false)
+	require.NoError(t, err)
+	require.NotNil(t, starts["acc_1"])
+	assert.Equal(t, int64(1704067200-3600), *starts["acc_1"])
+	require.NotNil(t, starts["acc_2"])
+	assert.Equal(t, int64(1704067200-3600), *starts["acc_2"])
+	assert.Contains(t, starts, "acc_3")
+	assert.Nil(t, starts["acc_3"])
+
+	runs, err := dbClient.ListSyncRuns(1)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.NotNil(t, runs[0].WindowStart, "The new account does not reset the other accounts' windows")
+}
+
+func TestSync_PerAccountListingTimeout(t *testing.T) {
+	setupTestHome(t)
+	cfg.Sync.Strategy = config.SyncPerAccount
+	cfg.Sync.AccountTimeout = 50 * time.Millisecond
+
+	fetcher := fetcherFunc(func(ctx context.Context, opts *api.GetAccountsOptions) (*model.GetAccountsResponse, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	original := newAccountsFetcher
+	newAccountsFetcher = func(ctx context.Context) (accountsFetcher, error) { return fetcher, nil }
+	t.Cleanup(func() { newAccountsFetcher = original })
+
+	_, err := runSync(context.Background(), &bytes.Buffer{}, "", true)
+	assert.ErrorContains(t, err, "listing accounts timed out after 50ms")
+}
+
+func TestSync_InvalidStrategy(t *testing.T) {
+	setupTestHome(t)
+	t.Cleanup(func() { cfg = config.Default() })
+
+	_, err := executeCommand(t, "sync", "--strategy", "parallel")
+	assert.ErrorContains(t, err, "sync.strategy")
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/db"
+	"github.com/spf13/cobra"
+)
+
+func newTransactionsCommand() *cobra.Command {
+	transactionsCmd := &cobra.Command{
+		Use:   "transactions",
+		Short: "Inspect stored transactions",
+	}
+
+	var accountId string
+	var since string
+	var limit int
+	var connection string
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List transactions, most recent first, including pending ones",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter := db.TransactionFilter{BankAccountID: accountId, Limit: limit}
+			if since != "" {
+				sinceTime, err := time.ParseInLocation(time.DateOnly, since, time.Local)
+				if err != nil {
+					return fmt.Errorf("invalid --since date %q: expected YYYY-MM-DD", since)
+				}
+				filter.Since = sinceTime
+			}
+
+			dbClient, err := openConnectionDatabase(connection)
+			if err != nil {
+				return err
+			}
+			defer dbClient.Close()
+
+			transactions, err := dbClient.ListTransactions(filter)
+			if err != nil {
+				return err
+			}
+
+			w := newTabWriter(cmd.OutOrStdout())
+			fmt.Fprintln(w, "POSTED\tACCOUNT\tAMOUNT\tPAYEE\tDESCRIPTION")
+			for _, transaction := range transactions {
+				posted := "pending"
+				if !transaction.Pending {
+					posted = time.Unix(transaction.Posted, 0).Local().Format(time.DateOnly)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+					posted, transaction.BankAccountID, transaction.Amount, transaction.Payee, transaction.Description)
+			}
+			return w.Flush()
+		},
+	}
+	listCmd.Flags().StringVar(&accountId, "account", "", "only show transactions for this account ID")
+	listCmd.Flags().StringVar(&since, "since", "", "only show transactions posted on or after this date (YYYY-MM-DD)")
+	listCmd.Flags().IntVar(&limit, "limit", 50, "maximum number of rows to show (0 for all)")
+	listCmd.Flags().StringVar(&connection, "connection", "", "only show the named connection's transactions")
+
+	transactionsCmd.AddCommand(listCmd)
+	return transactionsCmd
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionsList(t *testing.T) {
+	dbClient := setupTestHome(t)
+	older := time.Date(2024, 1, 5, 12, 0, 0, 0, time.Local).Unix()
+	newer := time.Date(2024, 3, 5, 12, 0, 0, 0, time.Local).Unix()
+	_, err := dbClient.PutTransaction("acc_1", model.Transaction{ID: "txn_old", Posted: older, Amount: "-10.00", Payee: "Old Payee"})
+	require.NoError(t, err)
+	_, err = dbClient.PutTransaction("acc_1", model.Transaction{ID: "txn_new", Posted: newer, Amount: "-20.00", Payee: "New Payee"})
+	require.NoError(t, err)
+
+	t.Run("all", func(t *testing.T) {
+		out, err := executeCommand(t, "transactions", "list")
+		require.NoError(t, err)
+		assert.Contains(t, out, "Old Payee")
+		assert.Contains(t, out, "New Payee")
+		assert.Less(t, strings.Index(out, "New Payee"), strings.Index(out, "Old Payee"), "Newest transaction should be first")
+	})
+
+	t.Run("since", func(t *testing.T) {
+		out, err := executeCommand(t, "transactions", "list", "--since", "2024-02-01")
+		require.NoError(t, err)
+		assert.Contains(t, out, "New Payee")
+		assert.NotContains(t, out, "Old Payee")
+	})
+
+	t.Run("pending", func(t *testing.T) {
+		_, err := dbClient.PutTransaction("acc_1", model.Transaction{ID: "txn_pending", TransactedAt: newer, Amount: "-5.00", Payee: "Pending Payee", Pending: true})
+		require.NoError(t, err)
+		out, err := executeCommand(t, "transactions", "list")
+		require.NoError(t, err)
+		assert.Regexp(t, `pending\s+acc_1\s+-5.00\s+Pending Payee`, out)
+	})
+
+	t.Run("invalid_since", func(t *testing.T) {
+		_, err := executeCommand(t, "transactions", "list", "--since", "02/01/2024")
+		assert.Error(t, err)
+	})
+}
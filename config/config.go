@@ -0,0 +1,503 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DefaultSSOProfile   = "monkstorage"
+	DefaultAWSRegion    = "us-east-1"
+	DefaultSecretName   = "monk-monies"
+	DefaultDatabasePath = "~/data/monk.db"
+	// DefaultCloseAfterMissedRuns is how many consecutive runs an account may
+	// be absent from before it is marked closed
+	DefaultCloseAfterMissedRuns = 3
+	// DefaultSyncOverlap is how far before the newest stored transaction an
+	// incremental sync starts, so pending transactions that later post are caught
+	DefaultSyncOverlap = 7 * 24 * time.Hour
+	// DefaultPendingExpiry is how old a pending transaction may get without
+	// posting before it is expired
+	DefaultPendingExpiry = 14 * 24 * time.Hour
+	// DefaultSyncConcurrency is how many accounts the per-account strategy
+	// fetches at once
+	DefaultSyncConcurrency = 4
+	// DefaultAccountTimeout bounds each per-account fetch, so one slow
+	// institution cannot hold up the run
+	DefaultAccountTimeout = 2 * time.Minute
+	// DefaultBackfillWindow is the span of history requested per backfill call
+	DefaultBackfillWindow = 60 * 24 * time.Hour
+	// DefaultBackfillPause is the wait between backfill requests, to stay
+	// within the bridge's rate limits
+	DefaultBackfillPause = 5 * time.Second
+)
+
+// Credential store backends selectable with credentials.backend
+const (
+	CredentialsSecretsManager = "secretsmanager"
+	CredentialsFile           = "file"
+	CredentialsEnv            = "env"
+)
+
+// How an expired AWS SSO session is renewed, selected with aws.login
+const (
+	LoginBrowser = "browser" // Print the code and open a browser
+	LoginLog     = "log"     // Write the URL and code as a log line on stderr
+	LoginWebhook = "webhook" // POST the URL and code to aws.login_webhook
+	LoginNone    = "none"    // Fail straight away, for unattended runs
+)
+
+// How sync requests accounts, selected with sync.strategy
+const (
+	SyncSingle     = "single"      // One request returns every account
+	SyncPerAccount = "per_account" // List balances, then fetch each account concurrently
+)
+
+// DefaultConnectionName names the single connection formed by the top-level
+// settings when no connections are configured
+const DefaultConnectionName = "default"
+
+// DefaultCredentialsFile is where the file backend keeps its encrypted tokens
+const DefaultCredentialsFile = "~/.config/chi-chi-moni/credentials.enc"
+
+// EnvPrefix is prepended to every environment variable the config reads
+const EnvPrefix = "CHICHIMONI_"
+
+// EnvConfigFile names an alternate config file location
+const EnvConfigFile = EnvPrefix + "CONFIG"
+
+// EnvCredentialsPassphrase holds the passphrase for the file credential
+// backend; it is never read from the config file
+const EnvCredentialsPassphrase = EnvPrefix + "CREDENTIALS_PASSPHRASE"
+
+var awsRegionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z0-9/_+=.@-]+$`)
+var connectionNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Config holds every setting that used to be a compile-time constant
+type Config struct {
+	AWS         AWSConfig          `yaml:"aws"`
+	Credentials CredentialsConfig  `yaml:"credentials"`
+	SecretName  string             `yaml:"secret_name"`
+	Database    DatabaseConfig     `yaml:"database"`
+	Sync        SyncConfig         `yaml:"sync"`
+	Backfill    BackfillConfig     `yaml:"backfill"`
+	Connections []ConnectionConfig `yaml:"connections"`
+	// Connection names the connection these settings were resolved for. It
+	// is empty for the top-level settings.
+	Connection string `yaml:"-"`
+}
+
+type AWSConfig struct {
+	Profile      string        `yaml:"profile"`
+	Region       string        `yaml:"region"`
+	Login        string        `yaml:"login"`
+	LoginWebhook string        `yaml:"login_webhook"`
+	LoginTimeout time.Duration `yaml:"login_timeout"`
+}
+
+// CredentialsConfig selects where the SimpleFIN access token named by
+// secret_name is kept
+type CredentialsConfig struct {
+	Backend string `yaml:"backend"`
+	File    string `yaml:"file"`
+}
+
+type DatabaseConfig struct {
+	Path string `yaml:"path"`
+}
+
+type SyncConfig struct {
+	CloseAfterMissedRuns int           `yaml:"close_after_missed_runs"`
+	Overlap              time.Duration `yaml:"overlap"`
+	Pending              bool          `yaml:"pending"`
+	PendingExpiry        time.Duration `yaml:"pending_expiry"`
+	Strategy             string        `yaml:"strategy"`
+	Concurrency          int           `yaml:"concurrency"`
+	AccountTimeout       time.Duration `yaml:"account_timeout"` // 0 disables the limit
+}
+
+type BackfillConfig struct {
+	Window time.Duration `yaml:"window"`
+	Pause  time.Duration `yaml:"pause"`
+}
+
+// ConnectionConfig is one named SimpleFIN connection with its own access
+// token. Empty fields inherit the top-level setting. Tenant defaults to the
+// name and tags the connection's accounts and runs, so connections can share
+// a database.
+type ConnectionConfig struct {
+	Name        string              `yaml:"name"`
+	Tenant      string              `yaml:"tenant"`
+	SecretName  string              `yaml:"secret_name"`
+	AWS         ConnectionAWSConfig `yaml:"aws"`
+	Credentials CredentialsConfig   `yaml:"credentials"`
+	Database    DatabaseConfig      `yaml:"database"`
+}
+
+// ConnectionAWSConfig holds the AWS settings a connection may override
+type ConnectionAWSConfig struct {
+	Profile string `yaml:"profile"`
+	Region  string `yaml:"region"`
+}
+
+// Connection is a connection resolved against the top-level settings
+type Connection struct {
+	Name   string
+	Tenant string
+	Config Config
+}
+
+// FieldError reports a problem with a single config field, named by its YAML path
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("config field %s: %s", e.Field, e.Message)
+}
+
+// Default returns the configuration used when no file or overrides are present
+func Default() Config {
+	return Config{
+		AWS: AWSConfig{
+			Profile: DefaultSSOProfile,
+			Region:  DefaultAWSRegion,
+			Login:   LoginBrowser,
+		},
+		Credentials: CredentialsConfig{
+			Backend: CredentialsSecretsManager,
+			File:    DefaultCredentialsFile,
+		},
+		SecretName: DefaultSecretName,
+		Database: DatabaseConfig{
+			Path: DefaultDatabasePath,
+		},
+		Sync: SyncConfig{
+			CloseAfterMissedRuns: DefaultCloseAfterMissedRuns,
+			Overlap:              DefaultSyncOverlap,
+			Pending:              true,
+			PendingExpiry:        DefaultPendingExpiry,
+			Strategy:             SyncSingle,
+			Concurrency:          DefaultSyncConcurrency,
+			AccountTimeout:       DefaultAccountTimeout,
+		},
+		Backfill: BackfillConfig{
+			Window: DefaultBackfillWindow,
+			Pause:  DefaultBackfillPause,
+		},
+	}
+}
+
+// DefaultPath returns the config file location: $CHICHIMONI_CONFIG if set,
+// otherwise $XDG_CONFIG_HOME/chi-chi-moni/config.yaml, falling back to
+// ~/.config/chi-chi-moni/config.yaml
+func DefaultPath() (string, error) {
+	if path := os.Getenv(EnvConfigFile); path != "" {
+		return ExpandPath(path)
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "chi-chi-moni", "config.yaml"), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".config", "chi-chi-moni", "config.yaml"), nil
+}
+
+// Load builds the configuration from defaults, the YAML file at path and
+// CHICHIMONI_* environment variables, in increasing order of precedence. An
+// empty path means DefaultPath, which may be missing; an explicit path must exist.
+// The result is not validated so callers can layer flags on top first.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	explicit := path != ""
+	if !explicit {
+		defaultPath, err := DefaultPath()
+		if err != nil {
+			return Config{}, err
+		}
+		path = defaultPath
+		explicit = os.Getenv(EnvConfigFile) != ""
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := decode(data, &cfg); err != nil {
+			return Config{}, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist) && !explicit:
+		// No config file is fine, defaults and overrides apply
+	default:
+		return Config{}, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	cfg.ApplyEnv(os.LookupEnv)
+	return cfg, nil
+}
+
+func decode(data []byte, cfg *Config) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// ApplyEnv overrides fields from CHICHIMONI_* environment variables
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) {
+	overrides := map[string]*string{
+		EnvPrefix + "AWS_PROFILE":         &c.AWS.Profile,
+		EnvPrefix + "AWS_REGION":          &c.AWS.Region,
+		EnvPrefix + "AWS_LOGIN":           &c.AWS.Login,
+		EnvPrefix + "AWS_LOGIN_WEBHOOK":   &c.AWS.LoginWebhook,
+		EnvPrefix + "CREDENTIALS_BACKEND": &c.Credentials.Backend,
+		EnvPrefix + "CREDENTIALS_FILE":    &c.Credentials.File,
+		EnvPrefix + "SECRET_NAME":         &c.SecretName,
+		EnvPrefix + "DATABASE_PATH":       &c.Database.Path,
+		EnvPrefix + "SYNC_STRATEGY":       &c.Sync.Strategy,
+	}
+	for name, field := range overrides {
+		if value, ok := lookup(name); ok {
+			*field = value
+		}
+	}
+}
+
+// Validate checks every field and returns all problems found, each as a *FieldError
+func (c Config) Validate() error {
+	var errs []error
+	if strings.TrimSpace(c.AWS.Profile) == "" {
+		errs = append(errs, &FieldError{Field: "aws.profile", Message: "must not be empty"})
+	}
+	if err := validateRegion("aws.region", c.AWS.Region); err != nil {
+		errs = append(errs, err)
+	}
+	switch c.AWS.Login {
+	case LoginBrowser, LoginLog, LoginNone:
+	case LoginWebhook:
+		if u, err := url.Parse(c.AWS.LoginWebhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, &FieldError{Field: "aws.login_webhook", Message: "must be an http or https URL for the webhook login"})
+		}
+	default:
+		errs = append(errs, &FieldError{Field: "aws.login", Message: fmt.Sprintf("%q must be one of %s, %s, %s or %s",
+			c.AWS.Login, LoginBrowser, LoginLog, LoginWebhook, LoginNone)})
+	}
+	if c.AWS.LoginTimeout < 0 {
+		errs = append(errs, &FieldError{Field: "aws.login_timeout", Message: "must not be negative"})
+	}
+	if err := validateCredentials("credentials.", c.Credentials); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateSecretName("secret_name", c.SecretName); err != nil {
+		errs = append(errs, err)
+	}
+	if strings.TrimSpace(c.Database.Path) == "" {
+		errs = append(errs, &FieldError{Field: "database.path", Message: "must not be empty"})
+	}
+	if c.Sync.CloseAfterMissedRuns < 1 {
+		errs = append(errs, &FieldError{Field: "sync.close_after_missed_runs", Message: "must be at least 1"})
+	}
+	if c.Sync.Overlap < 0 {
+		errs = append(errs, &FieldError{Field: "sync.overlap", Message: "must not be negative"})
+	}
+	if c.Sync.PendingExpiry <= 0 {
+		errs = append(errs, &FieldError{Field: "sync.pending_expiry", Message: "must be positive"})
+	}
+	switch c.Sync.Strategy {
+	case SyncSingle, SyncPerAccount:
+	default:
+		errs = append(errs, &FieldError{Field: "sync.strategy", Message: fmt.Sprintf("%q must be %s or %s", c.Sync.Strategy, SyncSingle, SyncPerAccount)})
+	}
+	if c.Sync.Concurrency < 1 {
+		errs = append(errs, &FieldError{Field: "sync.concurrency", Message: "must be at least 1"})
+	}
+	if c.Sync.AccountTimeout < 0 {
+		errs = append(errs, &FieldError{Field: "sync.account_timeout", Message: "must not be negative"})
+	}
+	if c.Backfill.Window < 24*time.Hour {
+		errs = append(errs, &FieldError{Field: "backfill.window", Message: "must be at least 24h"})
+	}
+	if c.Backfill.Pause < 0 {
+		errs = append(errs, &FieldError{Field: "backfill.pause", Message: "must not be negative"})
+	}
+	errs = append(errs, c.validateConnections()...)
+	return errors.Join(errs...)
+}
+
+// validateConnections checks the fields each connection sets, that names are
+// unique and that connections sharing a database have distinct tenants
+func (c Config) validateConnections() []error {
+	var errs []error
+	names := make(map[string]bool)
+	tenants := make(map[[2]string]string)
+	for i, conn := range c.Connections {
+		prefix := fmt.Sprintf("connections[%d].", i)
+		switch {
+		case !connectionNamePattern.MatchString(conn.Name):
+			errs = append(errs, &FieldError{Field: prefix + "name", Message: fmt.Sprintf("%q must be letters, digits, - or _", conn.Name)})
+		case names[conn.Name]:
+			errs = append(errs, &FieldError{Field: prefix + "name", Message: fmt.Sprintf("%q is used by another connection", conn.Name)})
+		}
+		names[conn.Name] = true
+		if conn.Tenant != "" && !connectionNamePattern.MatchString(conn.Tenant) {
+			errs = append(errs, &FieldError{Field: prefix + "tenant", Message: fmt.Sprintf("%q must be letters, digits, - or _", conn.Tenant)})
+		}
+		if conn.AWS.Region != "" {
+			if err := validateRegion(prefix+"aws.region", conn.AWS.Region); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if conn.SecretName != "" {
+			if err := validateSecretName(prefix+"secret_name", conn.SecretName); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		resolved := c.resolveConnection(conn)
+		if conn.Credentials != (CredentialsConfig{}) {
+			if err := validateCredentials(prefix+"credentials.", resolved.Config.Credentials); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		key := [2]string{resolved.Config.Database.Path, resolved.Tenant}
+		if other, ok := tenants[key]; ok {
+			errs = append(errs, &FieldError{Field: prefix + "tenant", Message: fmt.Sprintf("%q is also the tenant of connection %s in the same database", resolved.Tenant, other)})
+		} else {
+			tenants[key] = conn.Name
+		}
+	}
+	return errs
+}
+
+func validateRegion(field string, region string) error {
+	if !awsRegionPattern.MatchString(region) {
+		return &FieldError{Field: field, Message: fmt.Sprintf("%q is not a valid AWS region", region)}
+	}
+	return nil
+}
+
+func validateSecretName(field string, name string) error {
+	switch {
+	case name == "":
+		return &FieldError{Field: field, Message: "must not be empty"}
+	case len(name) > 512 || !secretNamePattern.MatchString(name):
+		return &FieldError{Field: field, Message: fmt.Sprintf("%q is not a valid Secrets Manager secret name", name)}
+	}
+	return nil
+}
+
+// validateCredentials checks a credentials block whose fields are named with prefix
+func validateCredentials(prefix string, credentials CredentialsConfig) error {
+	switch credentials.Backend {
+	case CredentialsSecretsManager, CredentialsEnv:
+	case CredentialsFile:
+		if strings.TrimSpace(credentials.File) == "" {
+			return &FieldError{Field: prefix + "file", Message: "must not be empty for the file backend"}
+		}
+	default:
+		return &FieldError{Field: prefix + "backend", Message: fmt.Sprintf("%q must be one of %s, %s or %s",
+			credentials.Backend, CredentialsSecretsManager, CredentialsFile, CredentialsEnv)}
+	}
+	return nil
+}
+
+// ResolveConnections returns the connections named in names, in config order,
+// or every connection when names is empty. Without configured connections the
+// top-level settings form a single connection named DefaultConnectionName
+// with an empty tenant.
+func (c Config) ResolveConnections(names []string) ([]Connection, error) {
+	var all []Connection
+	if len(c.Connections) == 0 {
+		all = []Connection{{Name: DefaultConnectionName, Config: c}}
+	}
+	for _, conn := range c.Connections {
+		all = append(all, c.resolveConnection(conn))
+	}
+	if len(names) == 0 {
+		return all, nil
+	}
+
+	selected := make(map[string]bool, len(names))
+	for _, name := range names {
+		selected[name] = true
+	}
+	var connections []Connection
+	for _, conn := range all {
+		if selected[conn.Name] {
+			connections = append(connections, conn)
+			delete(selected, conn.Name)
+		}
+	}
+	for _, name := range names {
+		if selected[name] {
+			return nil, fmt.Errorf("unknown connection %q", name)
+		}
+	}
+	return connections, nil
+}
+
+// resolveConnection overlays the fields conn sets on the top-level settings
+func (c Config) resolveConnection(conn ConnectionConfig) Connection {
+	resolved := c
+	resolved.Connections = nil
+	resolved.Connection = conn.Name
+	overrides := []struct {
+		value string
+		field *string
+	}{
+		{conn.SecretName, &resolved.SecretName},
+		{conn.AWS.Profile, &resolved.AWS.Profile},
+		{conn.AWS.Region, &resolved.AWS.Region},
+		{conn.Credentials.Backend, &resolved.Credentials.Backend},
+		{conn.Credentials.File, &resolved.Credentials.File},
+		{conn.Database.Path, &resolved.Database.Path},
+	}
+	for _, override := range overrides {
+		if override.value != "" {
+			*override.field = override.value
+		}
+	}
+	tenant := conn.Tenant
+	if tenant == "" {
+		tenant = conn.Name
+	}
+	return Connection{Name: conn.Name, Tenant: tenant, Config: resolved}
+}
+
+// DatabasePath returns the database path with a leading ~ expanded
+func (c Config) DatabasePath() (string, error) {
+	return ExpandPath(c.Database.Path)
+}
+
+// CredentialsFilePath returns the file backend's path with a leading ~ expanded
+func (c Config) CredentialsFilePath() (string, error) {
+	return ExpandPath(c.Credentials.File)
+}
+
+// ExpandPath replaces a leading ~ with the user's home directory
+func ExpandPath(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, path[1:]), nil
+}
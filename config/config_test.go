@@ -0,0 +1,462 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"AWS_PROFILE", "AWS_REGION", "SECRET_NAME", "DATABASE_PATH", "CONFIG", "CREDENTIALS_BACKEND", "CREDENTIALS_FILE", "AWS_LOGIN", "AWS_LOGIN_WEBHOOK", "SYNC_STRATEGY"} {
+		t.Setenv(EnvPrefix+name, "")
+		os.Unsetenv(EnvPrefix + name)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	cfg := Default()
+	assert.Equal(t, "monkstorage", cfg.AWS.Profile)
+	assert.Equal(t, "us-east-1", cfg.AWS.Region)
+	assert.Equal(t, "monk-monies", cfg.SecretName)
+	assert.Equal(t, CredentialsSecretsManager, cfg.Credentials.Backend)
+	assert.Equal(t, LoginBrowser, cfg.AWS.Login)
+	assert.Equal(t, "~/data/monk.db", cfg.Database.Path)
+	assert.Equal(t, 3, cfg.Sync.CloseAfterMissedRuns)
+	assert.Equal(t, 7*24*time.Hour, cfg.Sync.Overlap)
+	assert.True(t, cfg.Sync.Pending)
+	assert.Equal(t, 14*24*time.Hour, cfg.Sync.PendingExpiry)
+	assert.Equal(t, SyncSingle, cfg.Sync.Strategy)
+	assert.Equal(t, 4, cfg.Sync.Concurrency)
+	assert.Equal(t, 60*24*time.Hour, cfg.Backfill.Window)
+	assert.Equal(t, 5*time.Second, cfg.Backfill.Pause)
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestDefaultPath(t *testing.T) {
+	clearEnv(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	t.Run("home", func(t *testing.T) {
+		t.Setenv("XDG_CONFIG_HOME", "")
+		path, err := DefaultPath()
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(home, ".config", "chi-chi-moni", "config.yaml"), path)
+	})
+
+	t.Run("xdg", func(t *testing.T) {
+		t.Setenv("XDG_CONFIG_HOME", "/xdg")
+		path, err := DefaultPath()
+		require.NoError(t, err)
+		assert.Equal(t, "/xdg/chi-chi-moni/config.yaml", path)
+	})
+
+	t.Run("env_override", func(t *testing.T) {
+		t.Setenv(EnvConfigFile, "~/custom.yaml")
+		path, err := DefaultPath()
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(home, "custom.yaml"), path)
+	})
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		env     map[string]string
+		want    Config
+		wantErr string
+	}{
+		{
+			name:    "empty_file_uses_defaults",
+			content: "",
+			want:    Default(),
+		},
+		{
+			name: "partial_file_keeps_other_defaults",
+			content: `aws:
+  profile: teammate
+`,
+			want: func() Config {
+				cfg := Default()
+				cfg.AWS.Profile = "teammate"
+				return cfg
+			}(),
+		},
+		{
+			name: "connections",
+			content: `connections:
+  - name: personal
+  - name: business
+    secret_name: business-monies
+    aws:
+      profile: business-sso
+    database:
+      path: ~/data/business.db
+`,
+			want: func() Config {
+				cfg := Default()
+				cfg.Connections = []ConnectionConfig{
+					{Name: "personal"},
+					{
+						Name:       "business",
+						SecretName: "business-monies",
+						AWS:        ConnectionAWSConfig{Profile: "business-sso"},
+						Database:   DatabaseConfig{Path: "~/data/business.db"},
+					},
+				}
+				return cfg
+			}(),
+		},
+		{
+			name: "headless_login",
+			content: `aws:
+  login: webhook
+  login_webhook: https://hooks.example.com/sso
+  login_timeout: 5m
+`,
+			env: map[string]string{"CHICHIMONI_AWS_LOGIN": "log"},
+			want: func() Config {
+				cfg := Default()
+				cfg.AWS.Login = LoginLog
+				cfg.AWS.LoginWebhook = "https://hooks.example.com/sso"
+				cfg.AWS.LoginTimeout = 5 * time.Minute
+				return cfg
+			}(),
+		},
+		{
+			name: "credentials_backend",
+			content: `credentials:
+  backend: file
+  file: /tmp/credentials.enc
+`,
+			env: map[string]string{"CHICHIMONI_CREDENTIALS_BACKEND": "env"},
+			want: func() Config {
+				cfg := Default()
+				cfg.Credentials.Backend = CredentialsEnv
+				cfg.Credentials.File = "/tmp/credentials.enc"
+				return cfg
+			}(),
+		},
+		{
+			name: "env_overrides_file",
+			content: `secret_name: from-file
+database:
+  path: /tmp/file.db
+`,
+			env: map[string]string{
+				"CHICHIMONI_SECRET_NAME":   "from-env",
+				"CHICHIMONI_DATABASE_PATH": "/tmp/env.db",
+			},
+			want: func() Config {
+				cfg := Default()
+				cfg.SecretName = "from-env"
+				cfg.Database.Path = "/tmp/env.db"
+				return cfg
+			}(),
+		},
+		{
+			name: "sync_settings",
+			content: `sync:
+  close_after_missed_runs: 5
+  overlap: 48h
+  pending: false
+  pending_expiry: 72h
+  strategy: single
+  concurrency: 8
+  account_timeout: 30s
+`,
+			env: map[string]string{"CHICHIMONI_SYNC_STRATEGY": "per_account"},
+			want: func() Config {
+				cfg := Default()
+				cfg.Sync.CloseAfterMissedRuns = 5
+				cfg.Sync.Overlap = 48 * time.Hour
+				cfg.Sync.Pending = false
+				cfg.Sync.PendingExpiry = 72 * time.Hour
+				cfg.Sync.Strategy = SyncPerAccount
+				cfg.Sync.Concurrency = 8
+				cfg.Sync.AccountTimeout = 30 * time.Second
+				return cfg
+			}(),
+		},
+		{
+			name: "backfill_settings",
+			content: `backfill:
+  window: 720h
+  pause: 0s
+`,
+			want: func() Config {
+				cfg := Default()
+				cfg.Backfill.Window = 720 * time.Hour
+				cfg.Backfill.Pause = 0
+				return cfg
+			}(),
+		},
+		{
+			name:    "unknown_field",
+			content: "secret_nam: typo\n",
+			wantErr: "secret_nam",
+		},
+		{
+			name:    "malformed_yaml",
+			content: "aws: [unclosed\n",
+			wantErr: "failed to parse config file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			cfg, err := Load(writeConfig(t, tt.content))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, cfg)
+		})
+	}
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	cfg, err := Load("")
+	require.NoError(t, err, "A missing default config file should not be an error")
+	assert.Equal(t, Default(), cfg)
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err, "A missing explicit config file should be an error")
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		modify     func(cfg *Config)
+		wantFields []string
+	}{
+		{
+			name:   "valid",
+			modify: func(cfg *Config) {},
+		},
+		{
+			name:       "empty_profile",
+			modify:     func(cfg *Config) { cfg.AWS.Profile = " " },
+			wantFields: []string{"aws.profile"},
+		},
+		{
+			name:       "bad_region",
+			modify:     func(cfg *Config) { cfg.AWS.Region = "useast1" },
+			wantFields: []string{"aws.region"},
+		},
+		{
+			name:       "bad_secret_name",
+			modify:     func(cfg *Config) { cfg.SecretName = "has spaces" },
+			wantFields: []string{"secret_name"},
+		},
+		{
+			name: "bad_login",
+			modify: func(cfg *Config) {
+				cfg.AWS.Login = "popup"
+				cfg.AWS.LoginTimeout = -time.Minute
+			},
+			wantFields: []string{"aws.login", "aws.login_timeout"},
+		},
+		{
+			name: "webhook_login_without_url",
+			modify: func(cfg *Config) {
+				cfg.AWS.Login = LoginWebhook
+				cfg.AWS.LoginWebhook = "hooks.example.com"
+			},
+			wantFields: []string{"aws.login_webhook"},
+		},
+		{
+			name:       "unknown_credentials_backend",
+			modify:     func(cfg *Config) { cfg.Credentials.Backend = "vault" },
+			wantFields: []string{"credentials.backend"},
+		},
+		{
+			name: "file_backend_without_path",
+			modify: func(cfg *Config) {
+				cfg.Credentials.Backend = CredentialsFile
+				cfg.Credentials.File = ""
+			},
+			wantFields: []string{"credentials.file"},
+		},
+		{
+			name:       "close_after_zero_runs",
+			modify:     func(cfg *Config) { cfg.Sync.CloseAfterMissedRuns = 0 },
+			wantFields: []string{"sync.close_after_missed_runs"},
+		},
+		{
+			name:       "negative_overlap",
+			modify:     func(cfg *Config) { cfg.Sync.Overlap = -time.Hour },
+			wantFields: []string{"sync.overlap"},
+		},
+		{
+			name:       "zero_pending_expiry",
+			modify:     func(cfg *Config) { cfg.Sync.PendingExpiry = 0 },
+			wantFields: []string{"sync.pending_expiry"},
+		},
+		{
+			name: "bad_sync_strategy",
+			modify: func(cfg *Config) {
+				cfg.Sync.Strategy = "parallel"
+				cfg.Sync.Concurrency = 0
+				cfg.Sync.AccountTimeout = -time.Second
+			},
+			wantFields: []string{"sync.strategy", "sync.concurrency", "sync.account_timeout"},
+		},
+		{
+			name: "bad_backfill",
+			modify: func(cfg *Config) {
+				cfg.Backfill.Window = time.Hour
+				cfg.Backfill.Pause = -time.Second
+			},
+			wantFields: []string{"backfill.window", "backfill.pause"},
+		},
+		{
+			name: "bad_connections",
+			modify: func(cfg *Config) {
+				cfg.Connections = []ConnectionConfig{
+					{Name: "personal", AWS: ConnectionAWSConfig{Region: "mars-1"}},
+					{Name: "personal", SecretName: "has spaces"},
+					{Name: "", Credentials: CredentialsConfig{Backend: "vault"}},
+				}
+			},
+			wantFields: []string{"connections[0].aws.region", "connections[1].name", "connections[1].secret_name",
+				"connections[2].name", "connections[2].credentials.backend"},
+		},
+		{
+			name: "shared_tenant",
+			modify: func(cfg *Config) {
+				cfg.Connections = []ConnectionConfig{
+					{Name: "personal"},
+					{Name: "parents", Tenant: "personal"},
+					{Name: "business", Tenant: "personal", Database: DatabaseConfig{Path: "~/data/business.db"}},
+				}
+			},
+			wantFields: []string{"connections[1].tenant"},
+		},
+		{
+			name: "multiple_errors",
+			modify: func(cfg *Config) {
+				cfg.SecretName = ""
+				cfg.Database.Path = ""
+			},
+			wantFields: []string{"secret_name", "database.path"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if len(tt.wantFields) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, field := range tt.wantFields {
+				assert.Contains(t, err.Error(), field)
+			}
+			var fieldErr *FieldError
+			assert.True(t, errors.As(err, &fieldErr))
+		})
+	}
+}
+
+func TestResolveConnections(t *testing.T) {
+	cfg := Default()
+	connections, err := cfg.ResolveConnections(nil)
+	require.NoError(t, err)
+	require.Len(t, connections, 1)
+	assert.Equal(t, Connection{Name: DefaultConnectionName, Config: cfg}, connections[0],
+		"Without connections the top-level settings are the only connection")
+
+	cfg.Connections = []ConnectionConfig{
+		{Name: "personal"},
+		{
+			Name:        "business",
+			Tenant:      "acme",
+			SecretName:  "business-monies",
+			AWS:         ConnectionAWSConfig{Profile: "business-sso"},
+			Credentials: CredentialsConfig{Backend: CredentialsEnv},
+		},
+		{Name: "parents", Database: DatabaseConfig{Path: "~/data/parents.db"}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	connections, err = cfg.ResolveConnections(nil)
+	require.NoError(t, err)
+	require.Len(t, connections, 3)
+
+	personal := connections[0]
+	assert.Equal(t, "personal", personal.Tenant, "The tenant defaults to the name")
+	assert.Equal(t, DefaultSecretName, personal.Config.SecretName)
+	assert.Equal(t, DefaultSSOProfile, personal.Config.AWS.Profile)
+	assert.Nil(t, personal.Config.Connections)
+	assert.Equal(t, "personal", personal.Config.Connection)
+
+	business := connections[1]
+	assert.Equal(t, "acme", business.Tenant)
+	assert.Equal(t, "business-monies", business.Config.SecretName)
+	assert.Equal(t, "business-sso", business.Config.AWS.Profile)
+	assert.Equal(t, DefaultAWSRegion, business.Config.AWS.Region)
+	assert.Equal(t, CredentialsEnv, business.Config.Credentials.Backend)
+	assert.Equal(t, DefaultDatabasePath, business.Config.Database.Path)
+	assert.Equal(t, "~/data/parents.db", connections[2].Config.Database.Path)
+
+	connections, err = cfg.ResolveConnections([]string{"parents", "personal"})
+	require.NoError(t, err)
+	require.Len(t, connections, 2)
+	assert.Equal(t, "personal", connections[0].Name, "Selected connections keep their config order")
+	assert.Equal(t, "parents", connections[1].Name)
+
+	_, err = cfg.ResolveConnections([]string{"personal", "work"})
+	assert.ErrorContains(t, err, `unknown connection "work"`)
+}
+
+func TestExpandPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"~/data/monk.db", filepath.Join(home, "data", "monk.db")},
+		{"~", home},
+		{"/abs/path.db", "/abs/path.db"},
+		{"relative.db", "relative.db"},
+		{"~other/path", "~other/path"},
+	}
+
+	for _, tt := range tests {
+		got, err := ExpandPath(tt.input)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+}
@@ -0,0 +1,106 @@
+package credstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/criswit/chi-chi-moni/api"
+)
+
+// DefaultEnvPrefix names the variables EnvStore reads
+const DefaultEnvPrefix = "CHICHIMONI_ACCESS_URL"
+
+// EnvStore reads SimpleFIN access URLs from environment variables. The token
+// named "monk-monies" is read from CHICHIMONI_ACCESS_URL_MONK_MONIES, falling
+// back to CHICHIMONI_ACCESS_URL unless NoFallback is set. It is read-only.
+type EnvStore struct {
+	Prefix    string                      // DefaultEnvPrefix if empty
+	LookupEnv func(string) (string, bool) // os.LookupEnv if nil
+	Environ   func() []string             // os.Environ if nil
+	// NoFallback requires the token's own variable, so that one of several
+	// connections is never synced with another's token
+	NoFallback bool
+}
+
+// NewEnvStore returns a store reading the process environment
+func NewEnvStore() *EnvStore {
+	return &EnvStore{}
+}
+
+// VariableName returns the variable holding the token stored under name
+func (s *EnvStore) VariableName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(name) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return s.prefix() + "_" + b.String()
+}
+
+func (s *EnvStore) Get(ctx context.Context, name string) (api.AccessToken, error) {
+	variables := []string{s.VariableName(name)}
+	if !s.NoFallback {
+		variables = append(variables, s.prefix())
+	}
+	for _, variable := range variables {
+		if value, ok := s.lookup(variable); ok && value != "" {
+			token, err := api.ParseAccessUrl(value)
+			if err != nil {
+				return api.AccessToken{}, fmt.Errorf("%s: %w", variable, err)
+			}
+			return token, nil
+		}
+	}
+	return api.AccessToken{}, fmt.Errorf("%w: set %s", ErrNotFound, strings.Join(variables, " or "))
+}
+
+func (s *EnvStore) Put(ctx context.Context, name string, token api.AccessToken) error {
+	return fmt.Errorf("%w: set %s instead", ErrReadOnly, s.VariableName(name))
+}
+
+func (s *EnvStore) Delete(ctx context.Context, name string) error {
+	return fmt.Errorf("%w: unset %s instead", ErrReadOnly, s.VariableName(name))
+}
+
+// List returns the variable suffixes that hold access URLs, lower-cased with
+// underscores turned into dashes, such as "monk-monies"
+func (s *EnvStore) List(ctx context.Context, prefix string) ([]string, error) {
+	environ := s.Environ
+	if environ == nil {
+		environ = os.Environ
+	}
+	var names []string
+	for _, entry := range environ() {
+		key, value, _ := strings.Cut(entry, "=")
+		suffix, ok := strings.CutPrefix(key, s.prefix()+"_")
+		if !ok || suffix == "" || value == "" {
+			continue
+		}
+		name := strings.ReplaceAll(strings.ToLower(suffix), "_", "-")
+		if strings.Contains(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *EnvStore) prefix() string {
+	if s.Prefix != "" {
+		return s.Prefix
+	}
+	return DefaultEnvPrefix
+}
+
+func (s *EnvStore) lookup(name string) (string, bool) {
+	if s.LookupEnv != nil {
+		return s.LookupEnv(name)
+	}
+	return os.LookupEnv(name)
+}
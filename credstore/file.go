@@ -0,0 +1,214 @@
+package credstore
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/criswit/chi-chi-moni/api"
+)
+
+// DefaultFileIterations is the PBKDF2-SHA256 work factor for new files
+const DefaultFileIterations = 600_000
+
+const fileFormatVersion = 1
+
+// FileStore keeps tokens in a local file encrypted with a passphrase. The key
+// is derived with PBKDF2-SHA256 and the contents sealed with AES-256-GCM; a
+// fresh salt and nonce are used on every write.
+type FileStore struct {
+	path       string
+	passphrase string
+	// Iterations is the PBKDF2 work factor for writes, DefaultFileIterations
+	// if zero. Reads use the count recorded in the file.
+	Iterations int
+
+	mu sync.Mutex
+}
+
+// encryptedFile is the on-disk envelope
+type encryptedFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// NewFileStore returns a store backed by the file at path, which is created
+// on the first Put
+func NewFileStore(path string, passphrase string) (*FileStore, error) {
+	if passphrase == "" {
+		return nil, errors.New("the credentials file needs a non-empty passphrase")
+	}
+	return &FileStore{path: path, passphrase: passphrase}, nil
+}
+
+func (s *FileStore) Get(ctx context.Context, name string) (api.AccessToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return api.AccessToken{}, err
+	}
+	token, ok := tokens[name]
+	if !ok {
+		return api.AccessToken{}, fmt.Errorf("%w: %s in %s", ErrNotFound, name, s.path)
+	}
+	return token, nil
+}
+
+func (s *FileStore) Put(ctx context.Context, name string, token api.AccessToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	tokens[name] = token
+	return s.save(tokens)
+}
+
+func (s *FileStore) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := tokens[name]; !ok {
+		return fmt.Errorf("%w: %s in %s", ErrNotFound, name, s.path)
+	}
+	delete(tokens, name)
+	return s.save(tokens)
+}
+
+func (s *FileStore) List(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range tokens {
+		if strings.Contains(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// load decrypts the file; a missing file holds no tokens
+func (s *FileStore) load() (map[string]api.AccessToken, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]api.AccessToken{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+
+	var envelope encryptedFile
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("credentials file %s is not valid: %w", s.path, err)
+	}
+	if envelope.Version != fileFormatVersion || envelope.KDF != "pbkdf2-sha256" || envelope.Iterations <= 0 {
+		return nil, fmt.Errorf("credentials file %s has an unsupported format", s.path)
+	}
+
+	aead, err := newFileCipher(s.passphrase, envelope.Salt, envelope.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, envelope.Nonce, envelope.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credentials file %s: wrong passphrase or corrupted file", s.path)
+	}
+
+	tokens := map[string]api.AccessToken{}
+	if err := json.Unmarshal(plaintext, &tokens); err != nil {
+		return nil, fmt.Errorf("credentials file %s has invalid contents: %w", s.path, err)
+	}
+	return tokens, nil
+}
+
+// save encrypts tokens and replaces the file atomically, readable only by the owner
+func (s *FileStore) save(tokens map[string]api.AccessToken) error {
+	plaintext, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+
+	iterations := s.Iterations
+	if iterations <= 0 {
+		iterations = DefaultFileIterations
+	}
+	envelope := encryptedFile{
+		Version:    fileFormatVersion,
+		KDF:        "pbkdf2-sha256",
+		Iterations: iterations,
+		Salt:       make([]byte, 16),
+		Nonce:      make([]byte, 12),
+	}
+	if _, err := rand.Read(envelope.Salt); err != nil {
+		return err
+	}
+	if _, err := rand.Read(envelope.Nonce); err != nil {
+		return err
+	}
+	aead, err := newFileCipher(s.passphrase, envelope.Salt, iterations)
+	if err != nil {
+		return err
+	}
+	envelope.Ciphertext = aead.Seal(nil, envelope.Nonce, plaintext, nil)
+
+	data, err := json.MarshalIndent(envelope, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create credentials directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".credentials-*")
+	if err != nil {
+		return fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	return nil
+}
+
+func newFileCipher(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,89 @@
+package credstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/criswit/chi-chi-moni/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testToken = api.AccessToken{Username: "user", Password: "pass", Url: "bridge.example.com/simplefin"}
+
+func newTestFileStore(t *testing.T, path string, passphrase string) *FileStore {
+	t.Helper()
+	store, err := NewFileStore(path, passphrase)
+	require.NoError(t, err)
+	store.Iterations = 1000
+	return store
+}
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "nested", "credentials.enc")
+	store := newTestFileStore(t, path, "correct horse")
+
+	_, err := store.Get(ctx, "monk-monies")
+	assert.ErrorIs(t, err, ErrNotFound, "A missing file holds no tokens")
+
+	require.NoError(t, store.Put(ctx, "monk-monies", testToken))
+	require.NoError(t, store.Put(ctx, "other", testToken))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.False(t, strings.Contains(string(data), testToken.Password), "The token must not be stored in plain text")
+
+	// A second store reading the same file sees the tokens
+	reopened := newTestFileStore(t, path, "correct horse")
+	token, err := reopened.Get(ctx, "monk-monies")
+	require.NoError(t, err)
+	assert.Equal(t, testToken, token)
+
+	names, err := reopened.List(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"monk-monies", "other"}, names)
+	names, err = reopened.List(ctx, "monk")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"monk-monies"}, names)
+
+	require.NoError(t, reopened.Delete(ctx, "other"))
+	assert.ErrorIs(t, reopened.Delete(ctx, "other"), ErrNotFound)
+	exists, err := Exists(ctx, reopened, "other")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = Exists(ctx, reopened, "monk-monies")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestFileStore_WrongPassphrase(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "credentials.enc")
+	require.NoError(t, newTestFileStore(t, path, "correct horse").Put(ctx, "monk-monies", testToken))
+
+	_, err := newTestFileStore(t, path, "battery staple").Get(ctx, "monk-monies")
+	assert.ErrorContains(t, err, "wrong passphrase")
+
+	_, err = Exists(ctx, newTestFileStore(t, path, "battery staple"), "monk-monies")
+	assert.Error(t, err, "A decryption failure must not look like a missing token")
+}
+
+func TestFileStore_CorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.enc")
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0600))
+
+	_, err := newTestFileStore(t, path, "correct horse").Get(context.Background(), "monk-monies")
+	assert.ErrorContains(t, err, "not valid")
+}
+
+func TestNewFileStore_EmptyPassphrase(t *testing.T) {
+	_, err := NewFileStore(filepath.Join(t.TempDir(), "credentials.enc"), "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,115 @@
+// Package credstore abstracts where the SimpleFIN access token is kept, so
+// the tool can run against AWS Secrets Manager, an encrypted local file or
+// the environment.
+package credstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/api"
+)
+
+// ErrNotFound is returned by Get and Delete when no credential has the given name
+var ErrNotFound = errors.New("credential not found")
+
+// ErrReadOnly is returned by Put and Delete on stores that cannot be written
+var ErrReadOnly = errors.New("credential store is read-only")
+
+// CredentialStore keeps SimpleFIN access tokens under a name
+type CredentialStore interface {
+	// Get returns the token stored under name, or an error matching ErrNotFound
+	Get(ctx context.Context, name string) (api.AccessToken, error)
+	// Put stores token under name, replacing any existing token
+	Put(ctx context.Context, name string, token api.AccessToken) error
+	// Delete removes the token stored under name
+	Delete(ctx context.Context, name string) error
+	// List returns the names of stored tokens containing prefix, all if empty
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Version stages, named as in AWS Secrets Manager
+const (
+	StageCurrent  = "AWSCURRENT"
+	StagePending  = "AWSPENDING"
+	StagePrevious = "AWSPREVIOUS"
+)
+
+// Version is one stored revision of a credential
+type Version struct {
+	ID     string
+	Stages []string
+	// CreatedAt is when the version was stored
+	CreatedAt time.Time
+	// LastAccessed is the day the version was last read, nil if never
+	LastAccessed *time.Time
+}
+
+// VersionedStore is a CredentialStore that keeps earlier versions of a
+// credential, so a replacement can be staged, checked and promoted without
+// losing the token it replaces
+type VersionedStore interface {
+	CredentialStore
+	// Stage stores token under name as a StagePending version without
+	// making it current, and returns the version's ID
+	Stage(ctx context.Context, name string, token api.AccessToken) (string, error)
+	// GetVersion returns the token stored in a version of name
+	GetVersion(ctx context.Context, name string, versionID string) (api.AccessToken, error)
+	// Promote makes a version of name StageCurrent; the version it
+	// replaces becomes StagePrevious
+	Promote(ctx context.Context, name string, versionID string) error
+	// Versions lists the stored versions of name, newest first
+	Versions(ctx context.Context, name string) ([]Version, error)
+}
+
+// Recovery windows for RecoverableStore deletions, in days, as allowed by
+// AWS Secrets Manager
+const (
+	DefaultRecoveryWindowDays = 30
+	MinRecoveryWindowDays     = 7
+	MaxRecoveryWindowDays     = 30
+)
+
+// Info describes a stored credential, so a user can check it is the right
+// one before deleting it
+type Info struct {
+	Name string
+	ARN  string
+	// LastAccessed is the day the credential was last read, nil if never
+	LastAccessed *time.Time
+	// DeletionDate is when a scheduled deletion takes effect, nil if none
+	DeletionDate *time.Time
+}
+
+// DeleteOptions controls how a RecoverableStore deletes a credential
+type DeleteOptions struct {
+	// RecoveryWindowDays is how long the credential can be restored, from
+	// MinRecoveryWindowDays to MaxRecoveryWindowDays; zero means
+	// DefaultRecoveryWindowDays
+	RecoveryWindowDays int
+	// Force deletes the credential at once, with no way to restore it
+	Force bool
+}
+
+// RecoverableStore is a CredentialStore whose deletions can be undone during
+// a recovery window. Its Delete uses DefaultRecoveryWindowDays.
+type RecoverableStore interface {
+	CredentialStore
+	// Describe returns what the store knows about the credential under name
+	Describe(ctx context.Context, name string) (Info, error)
+	// DeleteWithOptions deletes the credential under name and returns when
+	// the deletion takes effect
+	DeleteWithOptions(ctx context.Context, name string, opts DeleteOptions) (time.Time, error)
+	// Restore cancels a scheduled deletion of the credential under name
+	Restore(ctx context.Context, name string) error
+}
+
+// Exists reports whether store holds a token under name
+func Exists(ctx context.Context, store CredentialStore, name string) (bool, error) {
+	_, err := store.Get(ctx, name)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
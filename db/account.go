@@ -0,0 +1,218 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/jmoiron/sqlx"
+)
+
+const bankAccountHistoryTable = "BANK_ACCOUNT_HISTORY"
+
+// Account statuses
+const (
+	AccountStatusActive  = "active"
+	AccountStatusMissing = "missing" // Absent from at least one recent run
+	AccountStatusClosed  = "closed"  // Absent for the configured number of consecutive runs
+)
+
+// AccountChange is a single field change on a bank account
+type AccountChange struct {
+	BankAccountID string `db:"BANK_ACCOUNT_ID"`
+	Field         string `db:"FIELD"` // BANK_ACCOUNT column name
+	OldValue      string `db:"OLD_VALUE"`
+	NewValue      string `db:"NEW_VALUE"`
+}
+
+// AccountChangeRecord is a row of the BANK_ACCOUNT_HISTORY table
+type AccountChangeRecord struct {
+	AccountChange
+	RunID     string    `db:"RUN_ID"` // Empty for manual changes
+	ChangedAt time.Time `db:"CHANGED_AT"`
+}
+
+func (c AccountChange) String() string {
+	return fmt.Sprintf("%s %s: %q -> %q", c.BankAccountID, c.Field, c.OldValue, c.NewValue)
+}
+
+// UpsertBankAccount inserts a new account or updates the name, institution
+// and currency of an existing one, recording every change in
+// BANK_ACCOUNT_HISTORY. Seeing an account also makes it active again and
+// resets its missed-run count, and tags it with the client's tenant. It
+// reports whether the account was created.
+func (c *DatabaseClient) UpsertBankAccount(account model.Account, runId string) (bool, []AccountChange, error) {
+	tx, err := c.Begin()
+	if err != nil {
+		return false, nil, err
+	}
+	defer tx.Rollback()
+
+	created, changes, err := tx.UpsertBankAccount(account, runId)
+	if err != nil {
+		return false, nil, err
+	}
+	return created, changes, tx.Commit()
+}
+
+func upsertBankAccount(q sqlx.Ext, tenant string, account model.Account, runId string) (bool, []AccountChange, error) {
+	var current struct {
+		Name            string `db:"NAME"`
+		InstitutionName string `db:"INSTITUTION_NAME"`
+		Currency        string `db:"CURRENCY"`
+		Status          string `db:"STATUS"`
+	}
+	query := fmt.Sprintf("SELECT NAME, INSTITUTION_NAME, COALESCE(CURRENCY, '') AS CURRENCY, STATUS FROM %s WHERE ID = ?", bankAccountTable)
+	err := sqlx.Get(q, &current, query, account.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		query := fmt.Sprintf(`INSERT INTO %s (ID, NAME, INSTITUTION_NAME, CURRENCY, LAST_SEEN_RUN_ID, UPDATED_AT, TENANT)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`, bankAccountTable)
+		if _, err := q.Exec(query, account.ID, account.Name, account.Org.Name, account.Currency, runId, time.Now().UTC(), tenant); err != nil {
+			return false, nil, fmt.Errorf("failed to insert bank account %s: %w", account.ID, err)
+		}
+		return true, nil, nil
+	}
+	if err != nil {
+		return false, nil, err
+	}
+
+	var changes []AccountChange
+	for _, field := range []struct {
+		name     string
+		old, new string
+	}{
+		{"NAME", current.Name, account.Name},
+		{"INSTITUTION_NAME", current.InstitutionName, account.Org.Name},
+		{"CURRENCY", current.Currency, account.Currency},
+		{"STATUS", current.Status, AccountStatusActive},
+	} {
+		if field.old != field.new {
+			changes = append(changes, AccountChange{BankAccountID: account.ID, Field: field.name, OldValue: field.old, NewValue: field.new})
+		}
+	}
+	if err := putAccountChanges(q, runId, changes); err != nil {
+		return false, nil, err
+	}
+
+	query = fmt.Sprintf(`UPDATE %s SET NAME = ?, INSTITUTION_NAME = ?, CURRENCY = ?, STATUS = ?,
+		MISSED_RUNS = 0, LAST_SEEN_RUN_ID = ?, UPDATED_AT = CASE WHEN ? THEN ? ELSE UPDATED_AT END, TENANT = ?
+		WHERE ID = ?`, bankAccountTable)
+	_, err = q.Exec(query, account.Name, account.Org.Name, account.Currency, AccountStatusActive,
+		runId, len(changes) > 0, time.Now().UTC(), tenant, account.ID)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to update bank account %s: %w", account.ID, err)
+	}
+	return false, changes, nil
+}
+
+// MarkMissingAccounts counts a missed run against every account of the
+// client's tenant not in seen. An active account becomes missing after one
+// missed run and closed after closeAfter consecutive missed runs. It returns
+// the resulting status changes.
+func (c *DatabaseClient) MarkMissingAccounts(runId string, seen []string, closeAfter int) ([]AccountChange, error) {
+	tx, err := c.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	changes, err := tx.MarkMissingAccounts(runId, seen, closeAfter)
+	if err != nil {
+		return nil, err
+	}
+	return changes, tx.Commit()
+}
+
+func markMissingAccounts(q sqlx.Ext, tenant string, runId string, seen []string, closeAfter int) ([]AccountChange, error) {
+	var accounts []BankAccountRecord
+	query := fmt.Sprintf("SELECT ID, NAME, INSTITUTION_NAME, STATUS, MISSED_RUNS FROM %s WHERE STATUS != ? AND TENANT = ?", bankAccountTable)
+	if err := sqlx.Select(q, &accounts, query, AccountStatusClosed, tenant); err != nil {
+		return nil, err
+	}
+
+	seenSet := make(map[string]bool, len(seen))
+	for _, id := range seen {
+		seenSet[id] = true
+	}
+
+	var changes []AccountChange
+	update := fmt.Sprintf("UPDATE %s SET MISSED_RUNS = ?, STATUS = ? WHERE ID = ?", bankAccountTable)
+	for _, account := range accounts {
+		if seenSet[account.ID] {
+			continue
+		}
+		missed := account.MissedRuns + 1
+		status := AccountStatusMissing
+		if missed >= closeAfter {
+			status = AccountStatusClosed
+		}
+		if _, err := q.Exec(update, missed, status, account.ID); err != nil {
+			return nil, fmt.Errorf("failed to mark bank account %s missing: %w", account.ID, err)
+		}
+		if status != account.Status {
+			changes = append(changes, AccountChange{BankAccountID: account.ID, Field: "STATUS", OldValue: account.Status, NewValue: status})
+		}
+	}
+	if err := putAccountChanges(q, runId, changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// ReactivateBankAccount marks a missing or closed account active again and
+// resets its missed-run count
+func (c *DatabaseClient) ReactivateBankAccount(bankAccountId string) error {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+	query := fmt.Sprintf("SELECT STATUS FROM %s WHERE ID = ?", bankAccountTable)
+	if err := tx.Get(&status, query, bankAccountId); errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("bank account %s not found", bankAccountId)
+	} else if err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf("UPDATE %s SET STATUS = ?, MISSED_RUNS = 0, UPDATED_AT = ? WHERE ID = ?", bankAccountTable)
+	if _, err := tx.Exec(query, AccountStatusActive, time.Now().UTC(), bankAccountId); err != nil {
+		return err
+	}
+	if status != AccountStatusActive {
+		change := AccountChange{BankAccountID: bankAccountId, Field: "STATUS", OldValue: status, NewValue: AccountStatusActive}
+		if err := putAccountChanges(tx, "", []AccountChange{change}); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetBankAccountHistory returns the recorded changes to an account, oldest first
+func (c *DatabaseClient) GetBankAccountHistory(bankAccountId string) ([]AccountChangeRecord, error) {
+	query := fmt.Sprintf(`SELECT BANK_ACCOUNT_ID, COALESCE(RUN_ID, '') AS RUN_ID, FIELD,
+		COALESCE(OLD_VALUE, '') AS OLD_VALUE, COALESCE(NEW_VALUE, '') AS NEW_VALUE, CHANGED_AT
+		FROM %s WHERE BANK_ACCOUNT_ID = ? ORDER BY ID`, bankAccountHistoryTable)
+	var history []AccountChangeRecord
+	if err := c.db.Select(&history, query, bankAccountId); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func putAccountChanges(q sqlx.Execer, runId string, changes []AccountChange) error {
+	var run interface{}
+	if runId != "" {
+		run = runId
+	}
+	query := fmt.Sprintf("INSERT INTO %s (BANK_ACCOUNT_ID, RUN_ID, FIELD, OLD_VALUE, NEW_VALUE) VALUES (?, ?, ?, ?, ?)", bankAccountHistoryTable)
+	for _, change := range changes {
+		if _, err := q.Exec(query, change.BankAccountID, run, change.Field, change.OldValue, change.NewValue); err != nil {
+			return fmt.Errorf("failed to record change to bank account %s: %w", change.BankAccountID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testAccount(id string, name string) model.Account {
+	return model.Account{
+		ID:       id,
+		Name:     name,
+		Currency: "USD",
+		Org:      model.Organization{ID: "test_bank_1", Name: "Test Bank"},
+	}
+}
+
+func getBankAccount(t *testing.T, client *DatabaseClient, id string) BankAccountRecord {
+	t.Helper()
+	accounts, err := client.ListBankAccounts()
+	require.NoError(t, err)
+	for _, account := range accounts {
+		if account.ID == id {
+			return account
+		}
+	}
+	t.Fatalf("bank account %s not found", id)
+	return BankAccountRecord{}
+}
+
+// TestUpsertBankAccount tests creating and renaming accounts
+func TestUpsertBankAccount(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	created, changes, err := client.UpsertBankAccount(testAccount("acc_1", "Checking"), "run_1")
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Empty(t, changes)
+
+	account := getBankAccount(t, client, "acc_1")
+	assert.Equal(t, "Checking", account.Name)
+	assert.Equal(t, "USD", account.Currency)
+	assert.Equal(t, AccountStatusActive, account.Status)
+
+	created, changes, err = client.UpsertBankAccount(testAccount("acc_1", "Checking"), "run_2")
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Empty(t, changes, "Unchanged accounts should not record history")
+
+	renamed := testAccount("acc_1", "Everyday Checking")
+	renamed.Org.Name = "Test Bank & Trust"
+	created, changes, err = client.UpsertBankAccount(renamed, "run_3")
+	require.NoError(t, err)
+	assert.False(t, created)
+	require.Len(t, changes, 2)
+	assert.Equal(t, AccountChange{BankAccountID: "acc_1", Field: "NAME", OldValue: "Checking", NewValue: "Everyday Checking"}, changes[0])
+	assert.Equal(t, "INSTITUTION_NAME", changes[1].Field)
+
+	account = getBankAccount(t, client, "acc_1")
+	assert.Equal(t, "Everyday Checking", account.Name)
+	assert.Equal(t, "Test Bank & Trust", account.InstitutionName)
+
+	history, err := client.GetBankAccountHistory("acc_1")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "run_3", history[0].RunID)
+	assert.Equal(t, "NAME", history[0].Field)
+	assert.False(t, history[0].ChangedAt.IsZero())
+}
+
+// TestUpsertBankAccount_LegacyRow tests upserting an account created by PutBankAccount
+func TestUpsertBankAccount_LegacyRow(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+	seedTestData(t, client)
+
+	account := testAccount("test_account_1", "Checking Account")
+	created, changes, err := client.UpsertBankAccount(account, "run_1")
+	require.NoError(t, err)
+	assert.False(t, created)
+	require.Len(t, changes, 1)
+	assert.Equal(t, AccountChange{BankAccountID: "test_account_1", Field: "CURRENCY", OldValue: "", NewValue: "USD"}, changes[0])
+}
+
+// TestMarkMissingAccounts tests the active -> missing -> closed lifecycle
+func TestMarkMissingAccounts(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	for _, id := range []string{"acc_1", "acc_2"} {
+		_, _, err := client.UpsertBankAccount(testAccount(id, "Account "+id), "run_1")
+		require.NoError(t, err)
+	}
+
+	changes, err := client.MarkMissingAccounts("run_2", []string{"acc_1"}, 3)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, AccountChange{BankAccountID: "acc_2", Field: "STATUS", OldValue: AccountStatusActive, NewValue: AccountStatusMissing}, changes[0])
+
+	changes, err = client.MarkMissingAccounts("run_3", []string{"acc_1"}, 3)
+	require.NoError(t, err)
+	assert.Empty(t, changes, "Still missing is not a status change")
+	assert.Equal(t, 2, getBankAccount(t, client, "acc_2").MissedRuns)
+
+	changes, err = client.MarkMissingAccounts("run_4", []string{"acc_1"}, 3)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, AccountStatusClosed, changes[0].NewValue)
+
+	account := getBankAccount(t, client, "acc_2")
+	assert.Equal(t, AccountStatusClosed, account.Status)
+	assert.Equal(t, 3, account.MissedRuns)
+
+	_, err = client.MarkMissingAccounts("run_5", []string{"acc_1"}, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 3, getBankAccount(t, client, "acc_2").MissedRuns, "Closed accounts stop counting")
+	assert.Equal(t, AccountStatusActive, getBankAccount(t, client, "acc_1").Status)
+
+	// Reappearing in a sync makes the account active again
+	_, changes, err = client.UpsertBankAccount(testAccount("acc_2", "Account acc_2"), "run_6")
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, AccountChange{BankAccountID: "acc_2", Field: "STATUS", OldValue: AccountStatusClosed, NewValue: AccountStatusActive}, changes[0])
+	account = getBankAccount(t, client, "acc_2")
+	assert.Equal(t, AccountStatusActive, account.Status)
+	assert.Equal(t, 0, account.MissedRuns)
+}
+
+// TestMarkMissingAccounts_Tenant tests that connections sharing a database
+// only count missed runs against their own accounts
+func TestMarkMissingAccounts_Tenant(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+	personal := client.WithTenant("personal")
+	business := client.WithTenant("business")
+
+	_, _, err := personal.UpsertBankAccount(testAccount("acc_personal", "Checking"), "run_1")
+	require.NoError(t, err)
+	_, _, err = business.UpsertBankAccount(testAccount("acc_business", "Operating"), "run_2")
+	require.NoError(t, err)
+
+	changes, err := business.MarkMissingAccounts("run_2", []string{"acc_business"}, 1)
+	require.NoError(t, err)
+	assert.Empty(t, changes, "Another tenant's accounts are not missing")
+	assert.Equal(t, AccountStatusActive, getBankAccount(t, client, "acc_personal").Status)
+
+	changes, err = personal.MarkMissingAccounts("run_3", nil, 1)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "acc_personal", changes[0].BankAccountID)
+	assert.Equal(t, AccountStatusActive, getBankAccount(t, client, "acc_business").Status)
+}
+
+// TestListings_Tenant tests that a scoped client only reads and expires its
+// own tenant's rows, while an unscoped one reads every tenant's
+func TestListings_Tenant(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+	personal := client.WithTenant("personal")
+	business := client.WithTenant("business")
+
+	cutoff := time.Now().Add(-14 * 24 * time.Hour)
+	old := model.Transaction{TransactedAt: cutoff.Add(-24 * time.Hour).Unix(), Amount: "-1.00", Pending: true}
+	for _, scoped := range []struct {
+		client    *DatabaseClient
+		accountId string
+	}{{personal, "acc_personal"}, {business, "acc_business"}} {
+		_, _, err := scoped.client.UpsertBankAccount(testAccount(scoped.accountId, "Checking"), "run_1")
+		require.NoError(t, err)
+		require.NoError(t, scoped.client.PutAccountBalance(scoped.accountId, "run_1", "10.00"))
+		old.ID = "pending_" + scoped.accountId
+		_, err = scoped.client.PutTransaction(scoped.accountId, old)
+		require.NoError(t, err)
+	}
+
+	accounts, err := personal.ListBankAccounts()
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, "acc_personal", accounts[0].ID)
+	accounts, err = client.ListBankAccounts()
+	require.NoError(t, err)
+	assert.Len(t, accounts, 2)
+
+	balances, err := personal.GetBalanceHistory("", 0)
+	require.NoError(t, err)
+	require.Len(t, balances, 1)
+	assert.Equal(t, "acc_personal", balances[0].BankAccountID)
+	balances, err = personal.GetBalanceHistory("acc_business", 0)
+	require.NoError(t, err)
+	assert.Empty(t, balances)
+
+	transactions, err := personal.ListTransactions(TransactionFilter{})
+	require.NoError(t, err)
+	require.Len(t, transactions, 1)
+	assert.Equal(t, "pending_acc_personal", transactions[0].ID)
+
+	pending, err := personal.GetPendingBalances()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 1, pending[0].PendingCount)
+
+	expired, err := personal.ExpirePendingTransactions(cutoff, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired, "Only the tenant's own pending transactions expire")
+	transactions, err = business.ListTransactions(TransactionFilter{})
+	require.NoError(t, err)
+	assert.Len(t, transactions, 1)
+}
+
+// TestReactivateBankAccount tests manually reactivating a closed account
+func TestReactivateBankAccount(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	_, _, err := client.UpsertBankAccount(testAccount("acc_1", "Checking"), "run_1")
+	require.NoError(t, err)
+	_, err = client.MarkMissingAccounts("run_2", nil, 1)
+	require.NoError(t, err)
+	require.Equal(t, AccountStatusClosed, getBankAccount(t, client, "acc_1").Status)
+
+	require.NoError(t, client.ReactivateBankAccount("acc_1"))
+	account := getBankAccount(t, client, "acc_1")
+	assert.Equal(t, AccountStatusActive, account.Status)
+	assert.Equal(t, 0, account.MissedRuns)
+
+	history, err := client.GetBankAccountHistory("acc_1")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "run_2", history[0].RunID)
+	assert.Equal(t, "", history[1].RunID, "Manual changes have no run")
+	assert.Equal(t, AccountStatusActive, history[1].NewValue)
+
+	assert.Error(t, client.ReactivateBankAccount("acc_missing"))
+}
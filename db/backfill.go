@@ -0,0 +1,180 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	backfillProgressTable     = "BACKFILL_PROGRESS"
+	backfillFailedWindowTable = "BACKFILL_FAILED_WINDOW"
+)
+
+// BackfillProgress is a row of the BACKFILL_PROGRESS table
+type BackfillProgress struct {
+	Since                int64      `db:"SINCE"`    // Unix epoch the backfill walks back to
+	NextEnd              int64      `db:"NEXT_END"` // Unix epoch end-date of the next window to fetch
+	Chunks               int        `db:"CHUNKS"`
+	TransactionsInserted int        `db:"TRANSACTIONS_INSERTED"`
+	TransactionsUpdated  int        `db:"TRANSACTIONS_UPDATED"`
+	StartedAt            time.Time  `db:"STARTED_AT"`
+	CompletedAt          *time.Time `db:"COMPLETED_AT"`
+}
+
+// BackfillWindow is a window [Start, End) of a backfill in which the bridge
+// reported errors, to be fetched again
+type BackfillWindow struct {
+	Start int64 `db:"WINDOW_START"`
+	End   int64 `db:"WINDOW_END"`
+}
+
+// Done reports whether every window back to Since has been stored
+func (p BackfillProgress) Done() bool {
+	return p.NextEnd <= p.Since
+}
+
+// BeginBackfill returns the client tenant's checkpoint for a backfill back to
+// since, creating one that starts at until if none exists. An unfinished
+// backfill resumes where it stopped; restart discards any existing checkpoint
+// first.
+func (c *DatabaseClient) BeginBackfill(since int64, until int64, restart bool) (BackfillProgress, error) {
+	if restart {
+		for _, table := range []string{backfillProgressTable, backfillFailedWindowTable} {
+			query := fmt.Sprintf("DELETE FROM %s WHERE TENANT = ? AND SINCE = ?", table)
+			if _, err := c.db.Exec(query, c.tenant, since); err != nil {
+				return BackfillProgress{}, err
+			}
+		}
+	}
+	query := fmt.Sprintf("INSERT INTO %s (TENANT, SINCE, NEXT_END) VALUES (?, ?, ?) ON CONFLICT(TENANT, SINCE) DO NOTHING", backfillProgressTable)
+	if _, err := c.db.Exec(query, c.tenant, since, until); err != nil {
+		return BackfillProgress{}, fmt.Errorf("failed to begin backfill: %w", err)
+	}
+	return c.GetBackfillProgress(since)
+}
+
+// GetBackfillProgress returns the client tenant's checkpoint for a backfill
+// back to since
+func (c *DatabaseClient) GetBackfillProgress(since int64) (BackfillProgress, error) {
+	query := fmt.Sprintf(`SELECT SINCE, NEXT_END, CHUNKS, TRANSACTIONS_INSERTED, TRANSACTIONS_UPDATED, STARTED_AT, COMPLETED_AT
+		FROM %s WHERE TENANT = ? AND SINCE = ?`, backfillProgressTable)
+	var progress BackfillProgress
+	err := c.db.Get(&progress, query, c.tenant, since)
+	if errors.Is(err, sql.ErrNoRows) {
+		return BackfillProgress{}, fmt.Errorf("no backfill to %s has been started", time.Unix(since, 0).UTC().Format(time.DateOnly))
+	}
+	return progress, err
+}
+
+// ListFailedBackfillWindows returns the windows of a backfill back to since
+// in which the bridge reported errors, newest first
+func (c *DatabaseClient) ListFailedBackfillWindows(since int64) ([]BackfillWindow, error) {
+	query := fmt.Sprintf("SELECT WINDOW_START, WINDOW_END FROM %s WHERE TENANT = ? AND SINCE = ? ORDER BY WINDOW_START DESC", backfillFailedWindowTable)
+	var windows []BackfillWindow
+	if err := c.db.Select(&windows, query, c.tenant, since); err != nil {
+		return nil, err
+	}
+	return windows, nil
+}
+
+// PutBackfillChunk stores the transactions fetched for the window
+// [windowStart, progress.NextEnd) and moves the checkpoint back to
+// windowStart, all in one database transaction, so an interrupted backfill
+// never skips or half-stores a window. Accounts not seen before are created.
+// A window in which the bridge reported errors is also recorded as failed,
+// see ListFailedBackfillWindows.
+func (c *DatabaseClient) PutBackfillChunk(since int64, windowStart int64, accounts []model.Account, failed bool) (BackfillProgress, error) {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return BackfillProgress{}, err
+	}
+	defer tx.Rollback()
+
+	inserted, updated, err := putBackfillAccounts(tx, c.tenant, accounts)
+	if err != nil {
+		return BackfillProgress{}, err
+	}
+	if failed {
+		query := fmt.Sprintf(`INSERT OR REPLACE INTO %s (TENANT, SINCE, WINDOW_START, WINDOW_END)
+			SELECT TENANT, SINCE, ?, NEXT_END FROM %s WHERE TENANT = ? AND SINCE = ?`, backfillFailedWindowTable, backfillProgressTable)
+		if _, err := tx.Exec(query, windowStart, c.tenant, since); err != nil {
+			return BackfillProgress{}, fmt.Errorf("failed to record failed backfill window: %w", err)
+		}
+	}
+
+	query := fmt.Sprintf(`UPDATE %s SET NEXT_END = ?, CHUNKS = CHUNKS + 1,
+		TRANSACTIONS_INSERTED = TRANSACTIONS_INSERTED + ?, TRANSACTIONS_UPDATED = TRANSACTIONS_UPDATED + ?,
+		UPDATED_AT = CURRENT_TIMESTAMP,
+		COMPLETED_AT = CASE WHEN ? <= SINCE THEN CURRENT_TIMESTAMP ELSE NULL END
+		WHERE TENANT = ? AND SINCE = ?`, backfillProgressTable)
+	result, err := tx.Exec(query, windowStart, inserted, updated, windowStart, c.tenant, since)
+	if err != nil {
+		return BackfillProgress{}, fmt.Errorf("failed to checkpoint backfill: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return BackfillProgress{}, fmt.Errorf("no backfill to %s has been started", time.Unix(since, 0).UTC().Format(time.DateOnly))
+	}
+	if err := tx.Commit(); err != nil {
+		return BackfillProgress{}, err
+	}
+	return c.GetBackfillProgress(since)
+}
+
+// RetryBackfillWindow stores the transactions fetched again for a failed
+// window without moving the checkpoint. The window stays recorded as failed
+// while the bridge still reports errors for it.
+func (c *DatabaseClient) RetryBackfillWindow(since int64, window BackfillWindow, accounts []model.Account, failed bool) (BackfillProgress, error) {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return BackfillProgress{}, err
+	}
+	defer tx.Rollback()
+
+	inserted, updated, err := putBackfillAccounts(tx, c.tenant, accounts)
+	if err != nil {
+		return BackfillProgress{}, err
+	}
+	if !failed {
+		query := fmt.Sprintf("DELETE FROM %s WHERE TENANT = ? AND SINCE = ? AND WINDOW_START = ?", backfillFailedWindowTable)
+		if _, err := tx.Exec(query, c.tenant, since, window.Start); err != nil {
+			return BackfillProgress{}, fmt.Errorf("failed to clear failed backfill window: %w", err)
+		}
+	}
+	query := fmt.Sprintf(`UPDATE %s SET TRANSACTIONS_INSERTED = TRANSACTIONS_INSERTED + ?,
+		TRANSACTIONS_UPDATED = TRANSACTIONS_UPDATED + ?, UPDATED_AT = CURRENT_TIMESTAMP
+		WHERE TENANT = ? AND SINCE = ?`, backfillProgressTable)
+	if _, err := tx.Exec(query, inserted, updated, c.tenant, since); err != nil {
+		return BackfillProgress{}, fmt.Errorf("failed to checkpoint backfill: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return BackfillProgress{}, err
+	}
+	return c.GetBackfillProgress(since)
+}
+
+// putBackfillAccounts creates the accounts not seen before under tenant and
+// stores their transactions
+func putBackfillAccounts(tx *sqlx.Tx, tenant string, accounts []model.Account) (inserted int, updated int, err error) {
+	accountQuery := fmt.Sprintf(`INSERT INTO %s (ID, NAME, INSTITUTION_NAME, CURRENCY, TENANT) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(ID) DO NOTHING`, bankAccountTable)
+	for _, account := range accounts {
+		if _, err := tx.Exec(accountQuery, account.ID, account.Name, account.Org.Name, account.Currency, tenant); err != nil {
+			return inserted, updated, fmt.Errorf("failed to put bank account %s: %w", account.ID, err)
+		}
+		accountInserted, accountUpdated, err := putTransactions(tx, account.ID, account.Transactions)
+		if err != nil {
+			return inserted, updated, err
+		}
+		inserted += accountInserted
+		updated += accountUpdated
+		if err := advanceWatermark(tx, account.ID, account.Transactions); err != nil {
+			return inserted, updated, err
+		}
+	}
+	return inserted, updated, nil
+}
@@ -0,0 +1,195 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	backfillSince = int64(1672531200) // 2023-01-01
+	backfillUntil = int64(1680307200) // 2023-04-01
+	backfillMid   = int64(1677628800) // 2023-03-01
+)
+
+// TestBackfill tests walking a backfill back to its start date
+func TestBackfill(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	progress, err := client.BeginBackfill(backfillSince, backfillUntil, false)
+	require.NoError(t, err)
+	assert.Equal(t, backfillUntil, progress.NextEnd)
+	assert.False(t, progress.Done())
+
+	accounts := []model.Account{{
+		ID:   "acc_1",
+		Name: "Checking",
+		Org:  model.Organization{Name: "Test Bank"},
+		Transactions: []model.Transaction{
+			{ID: "txn_1", Posted: backfillMid + 100, Amount: "-1.00"},
+			{ID: "txn_2", Posted: backfillMid + 200, Amount: "-2.00"},
+		},
+	}}
+	progress, err = client.PutBackfillChunk(backfillSince, backfillMid, accounts, false)
+	require.NoError(t, err)
+	assert.Equal(t, backfillMid, progress.NextEnd)
+	assert.Equal(t, 1, progress.Chunks)
+	assert.Equal(t, 2, progress.TransactionsInserted)
+	assert.Nil(t, progress.CompletedAt)
+
+	exists, err := client.DoesBankAccountExist("acc_1")
+	require.NoError(t, err)
+	assert.True(t, exists, "Backfill should create accounts it has not seen")
+
+	// Resuming picks up the checkpoint instead of starting over
+	progress, err = client.BeginBackfill(backfillSince, backfillUntil+86400, false)
+	require.NoError(t, err)
+	assert.Equal(t, backfillMid, progress.NextEnd)
+
+	accounts[0].Transactions = []model.Transaction{{ID: "txn_3", Posted: backfillSince + 100, Amount: "-3.00"}}
+	progress, err = client.PutBackfillChunk(backfillSince, backfillSince, accounts, false)
+	require.NoError(t, err)
+	assert.True(t, progress.Done())
+	assert.NotNil(t, progress.CompletedAt)
+	assert.Equal(t, 3, progress.TransactionsInserted)
+
+	transactions, err := client.ListTransactions(TransactionFilter{BankAccountID: "acc_1"})
+	require.NoError(t, err)
+	assert.Len(t, transactions, 3)
+
+	posted, _, err := client.GetWatermark("acc_1")
+	require.NoError(t, err)
+	assert.Equal(t, backfillMid+200, posted, "Older chunks must not move the watermark back")
+
+	// Restart discards the checkpoint
+	progress, err = client.BeginBackfill(backfillSince, backfillUntil, true)
+	require.NoError(t, err)
+	assert.Equal(t, backfillUntil, progress.NextEnd)
+	assert.Equal(t, 0, progress.Chunks)
+}
+
+// TestPutBackfillChunk_RollsBack tests that a failed chunk stores nothing
+func TestPutBackfillChunk_RollsBack(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	_, err := client.BeginBackfill(backfillSince, backfillUntil, false)
+	require.NoError(t, err)
+
+	// Make the checkpoint update fail after the transactions were written
+	_, err = client.db.Exec(`CREATE TRIGGER fail_checkpoint BEFORE UPDATE ON BACKFILL_PROGRESS
+		BEGIN SELECT RAISE(ABORT, 'checkpoint failed'); END`)
+	require.NoError(t, err)
+
+	_, err = client.PutBackfillChunk(backfillSince, backfillMid, []model.Account{{
+		ID:           "acc_1",
+		Transactions: []model.Transaction{{ID: "txn_1", Posted: backfillMid + 100, Amount: "-1.00"}},
+	}}, true)
+	require.Error(t, err)
+
+	transactions, err := client.ListTransactions(TransactionFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, transactions, "A failed chunk must not leave partial data")
+
+	exists, err := client.DoesBankAccountExist("acc_1")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+// TestBackfill_FailedWindows tests recording and retrying windows in which
+// the bridge reported errors
+func TestBackfill_FailedWindows(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	_, err := client.BeginBackfill(backfillSince, backfillUntil, false)
+	require.NoError(t, err)
+	progress, err := client.PutBackfillChunk(backfillSince, backfillMid, nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, backfillMid, progress.NextEnd, "A failed window still moves the checkpoint")
+
+	windows, err := client.ListFailedBackfillWindows(backfillSince)
+	require.NoError(t, err)
+	assert.Equal(t, []BackfillWindow{{Start: backfillMid, End: backfillUntil}}, windows)
+
+	accounts := []model.Account{{
+		ID:           "acc_1",
+		Name:         "Checking",
+		Transactions: []model.Transaction{{ID: "txn_1", Posted: backfillMid + 100, Amount: "-1.00"}},
+	}}
+	progress, err = client.RetryBackfillWindow(backfillSince, windows[0], accounts, true)
+	require.NoError(t, err)
+	assert.Equal(t, backfillMid, progress.NextEnd, "Retries do not move the checkpoint")
+	assert.Equal(t, 1, progress.TransactionsInserted)
+	windows, err = client.ListFailedBackfillWindows(backfillSince)
+	require.NoError(t, err)
+	assert.Len(t, windows, 1, "A window that fails again stays recorded")
+
+	_, err = client.RetryBackfillWindow(backfillSince, windows[0], accounts, false)
+	require.NoError(t, err)
+	windows, err = client.ListFailedBackfillWindows(backfillSince)
+	require.NoError(t, err)
+	assert.Empty(t, windows)
+
+	// Restart discards the failed windows with the checkpoint
+	_, err = client.PutBackfillChunk(backfillSince, backfillSince, nil, true)
+	require.NoError(t, err)
+	_, err = client.BeginBackfill(backfillSince, backfillUntil, true)
+	require.NoError(t, err)
+	windows, err = client.ListFailedBackfillWindows(backfillSince)
+	require.NoError(t, err)
+	assert.Empty(t, windows)
+}
+
+// TestBackfill_Tenants tests that connections sharing a database keep their
+// own checkpoints and own the accounts they backfill
+func TestBackfill_Tenants(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+	personal := client.WithTenant("personal")
+	business := client.WithTenant("business")
+
+	_, err := personal.BeginBackfill(backfillSince, backfillUntil, false)
+	require.NoError(t, err)
+	_, err = business.BeginBackfill(backfillSince, backfillUntil, false)
+	require.NoError(t, err)
+
+	_, err = personal.PutBackfillChunk(backfillSince, backfillMid, []model.Account{{ID: "acc_1", Name: "Checking"}}, true)
+	require.NoError(t, err)
+
+	progress, err := business.GetBackfillProgress(backfillSince)
+	require.NoError(t, err)
+	assert.Equal(t, backfillUntil, progress.NextEnd, "One connection's chunk must not move another's checkpoint")
+	windows, err := business.ListFailedBackfillWindows(backfillSince)
+	require.NoError(t, err)
+	assert.Empty(t, windows)
+
+	windows, err = personal.ListFailedBackfillWindows(backfillSince)
+	require.NoError(t, err)
+	assert.Len(t, windows, 1)
+
+	var tenant string
+	require.NoError(t, client.db.Get(&tenant, "SELECT TENANT FROM BANK_ACCOUNT WHERE ID = ?", "acc_1"))
+	assert.Equal(t, "personal", tenant)
+
+	// Restarting one connection leaves the other's checkpoint alone
+	_, err = business.BeginBackfill(backfillSince, backfillUntil, true)
+	require.NoError(t, err)
+	progress, err = personal.GetBackfillProgress(backfillSince)
+	require.NoError(t, err)
+	assert.Equal(t, backfillMid, progress.NextEnd)
+}
+
+// TestPutBackfillChunk_NotStarted tests checkpointing a backfill that was never begun
+func TestPutBackfillChunk_NotStarted(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	_, err := client.PutBackfillChunk(backfillSince, backfillMid, nil, false)
+	assert.Error(t, err)
+	_, err = client.GetBackfillProgress(backfillSince)
+	assert.Error(t, err)
+}
@@ -2,6 +2,8 @@ package db
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/criswit/chi-chi-moni/model"
 	"github.com/jmoiron/sqlx"
@@ -12,14 +14,75 @@ const bankAccountBalanceTable = "BANK_ACCOUNT_BALANCE"
 
 type DatabaseClient struct {
 	db *sqlx.DB
+	// tenant scopes account upserts, missing-account checks, sync windows
+	// and runs to one SimpleFIN connection; "" is the single unnamed one
+	tenant string
+	// scoped limits listings to the tenant's rows; a client that was never
+	// given a tenant lists every tenant's
+	scoped bool
 }
 
+// BankAccountRecord is a row of the BANK_ACCOUNT table
+type BankAccountRecord struct {
+	ID              string `db:"ID"`
+	Name            string `db:"NAME"`
+	InstitutionName string `db:"INSTITUTION_NAME"`
+	Currency        string `db:"CURRENCY"`
+	Status          string `db:"STATUS"`
+	MissedRuns      int    `db:"MISSED_RUNS"`
+}
+
+// BalanceRecord is a row of the BANK_ACCOUNT_BALANCE table
+type BalanceRecord struct {
+	BankAccountID string    `db:"BANK_ACCOUNT_ID"`
+	RunID         string    `db:"RUN_ID"`
+	Balance       string    `db:"BALANCE"`
+	CreatedAt     time.Time `db:"CREATED_AT"`
+}
+
+// NewDatabaseClient opens the SQLite database at path and brings its schema
+// up to date by applying any pending migrations. The client is safe for
+// concurrent use.
 func NewDatabaseClient(path string) (*DatabaseClient, error) {
-	db, err := sqlx.Connect("sqlite3", path)
+	db, err := sqlx.Connect("sqlite3", dataSourceName(path))
 	if err != nil {
 		return nil, err
 	}
-	return &DatabaseClient{db: db}, nil
+	if path == ":memory:" {
+		// Every connection to :memory: opens a separate, empty database
+		db.SetMaxOpenConns(1)
+	}
+	client := &DatabaseClient{db: db}
+	if _, err := client.Migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// WithTenant returns a client sharing this one's database that is scoped to
+// tenant, so several connections can sync into one database without marking
+// each other's accounts missing. Its listings only return the tenant's rows.
+func (c *DatabaseClient) WithTenant(tenant string) *DatabaseClient {
+	scoped := *c
+	scoped.tenant = tenant
+	scoped.scoped = true
+	return &scoped
+}
+
+// tenantAccounts limits rows with a BANK_ACCOUNT_ID column to the accounts
+// of one tenant
+var tenantAccounts = fmt.Sprintf("BANK_ACCOUNT_ID IN (SELECT ID FROM %s WHERE TENANT = ?)", bankAccountTable)
+
+// dataSourceName adds connection options to path. Transactions take the write
+// lock when they begin, so two that read before writing cannot deadlock
+// upgrading their locks, and a locked database is waited on for a while.
+func dataSourceName(path string) string {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return path + separator + "_txlock=immediate&_busy_timeout=10000"
 }
 
 func (c *DatabaseClient) Close() {
@@ -27,8 +90,12 @@ func (c *DatabaseClient) Close() {
 }
 
 func (c *DatabaseClient) PutBankAccount(account model.Account) error {
+	return putBankAccount(c.db, account)
+}
+
+func putBankAccount(q sqlx.Execer, account model.Account) error {
 	query := fmt.Sprintf("INSERT INTO %s (ID, NAME, INSTITUTION_NAME) VALUES (?, ?, ?)", bankAccountTable)
-	_, err := c.db.Exec(query, account.ID, account.Name, account.Org.Name)
+	_, err := q.Exec(query, account.ID, account.Name, account.Org.Name)
 	if err != nil {
 		return err
 	}
@@ -45,8 +112,12 @@ func (c *DatabaseClient) PutBankAccountBalance(bankAccountId string, runId strin
 }
 
 func (c *DatabaseClient) PutAccountBalance(bankAccountId string, runId string, balance string) error {
+	return putAccountBalance(c.db, bankAccountId, runId, balance)
+}
+
+func putAccountBalance(q sqlx.Execer, bankAccountId string, runId string, balance string) error {
 	query := "INSERT INTO BANK_ACCOUNT_BALANCE (BANK_ACCOUNT_ID, RUN_ID, BALANCE) VALUES (?, ?, ?)"
-	_, err := c.db.Exec(query, bankAccountId, runId, balance)
+	_, err := q.Exec(query, bankAccountId, runId, balance)
 	if err != nil {
 		return err
 	}
@@ -62,3 +133,51 @@ func (c *DatabaseClient) DoesBankAccountExist(accountId string) (bool, error) {
 	}
 	return count > 0, nil
 }
+
+// ListBankAccounts returns every stored bank account of the client's tenant,
+// or of every tenant for an unscoped client, ordered by institution and name
+func (c *DatabaseClient) ListBankAccounts() ([]BankAccountRecord, error) {
+	query := fmt.Sprintf(`SELECT ID, NAME, INSTITUTION_NAME, COALESCE(CURRENCY, '') AS CURRENCY, STATUS, MISSED_RUNS
+		FROM %s`, bankAccountTable)
+	var args []interface{}
+	if c.scoped {
+		query += " WHERE TENANT = ?"
+		args = append(args, c.tenant)
+	}
+	query += " ORDER BY INSTITUTION_NAME, NAME"
+	var accounts []BankAccountRecord
+	if err := c.db.Select(&accounts, query, args...); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// GetBalanceHistory returns recorded balances, newest first. An empty
+// bankAccountId returns balances for every account of the client's tenant; a
+// limit of 0 or less returns every row.
+func (c *DatabaseClient) GetBalanceHistory(bankAccountId string, limit int) ([]BalanceRecord, error) {
+	query := fmt.Sprintf("SELECT BANK_ACCOUNT_ID, RUN_ID, BALANCE, CREATED_AT FROM %s", bankAccountBalanceTable)
+	var conditions []string
+	var args []interface{}
+	if bankAccountId != "" {
+		conditions = append(conditions, "BANK_ACCOUNT_ID = ?")
+		args = append(args, bankAccountId)
+	}
+	if c.scoped {
+		conditions = append(conditions, tenantAccounts)
+		args = append(args, c.tenant)
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY CREATED_AT DESC, ROWID DESC"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	var balances []BalanceRecord
+	if err := c.db.Select(&balances, query, args...); err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
@@ -24,31 +24,13 @@ func setupTestDB(t *testing.T) *DatabaseClient {
 	db, err := sqlx.Connect("sqlite3", ":memory:")
 	require.NoError(t, err, "Failed to create in-memory database")
 	
-	// Create schema
-	schema := `
-	CREATE TABLE IF NOT EXISTS BANK_ACCOUNT (
-		ID TEXT PRIMARY KEY,
-		NAME TEXT NOT NULL,
-		INSTITUTION_NAME TEXT NOT NULL
-	);
-	
-	CREATE TABLE IF NOT EXISTS BANK_ACCOUNT_BALANCE (
-		ID TEXT,
-		BANK_ACCOUNT_ID TEXT,
-		RUN_ID TEXT NOT NULL,
-		BALANCE TEXT NOT NULL,
-		CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY(BANK_ACCOUNT_ID) REFERENCES BANK_ACCOUNT(ID)
-	);
-	
-	CREATE INDEX IF NOT EXISTS idx_bank_account_balance_account_id ON BANK_ACCOUNT_BALANCE(BANK_ACCOUNT_ID);
-	CREATE INDEX IF NOT EXISTS idx_bank_account_balance_run_id ON BANK_ACCOUNT_BALANCE(RUN_ID);
-	`
-	
-	_, err = db.Exec(schema)
-	require.NoError(t, err, "Failed to create schema")
-	
-	return &DatabaseClient{db: db}
+	db.SetMaxOpenConns(1)
+	
+	client := &DatabaseClient{db: db}
+	_, err = client.Migrate()
+	require.NoError(t, err, "Failed to migrate schema")
+	
+	return client
 }
 
 func seedTestData(t *testing.T, client *DatabaseClient) {
@@ -567,10 +549,10 @@ func TestPutAccountBalance(t *testing.T) {
 
 // TestConcurrentDatabaseAccess tests concurrent database operations
 func TestConcurrentDatabaseAccess(t *testing.T) {
-	// Skip this test for now as in-memory SQLite has issues with WAL mode and concurrency
-	t.Skip("Skipping concurrent test - SQLite in-memory doesn't properly support WAL mode")
-	
-	client := setupTestDB(t)
+	// In-memory databases are limited to one connection, so use a file to
+	// exercise concurrent connections
+	client, err := NewDatabaseClient(filepath.Join(t.TempDir(), "concurrent.db"))
+	require.NoError(t, err)
 	defer client.Close()
 	
 	const numGoroutines = 10
@@ -612,8 +594,15 @@ func TestConcurrentDatabaseAccess(t *testing.T) {
 					continue
 				}
 				
-				// Add balance
+				// Update it as sync does, reading and writing in one transaction
 				runID := fmt.Sprintf("run_%d_%d", goroutineID, j)
+				account.Currency = "USD"
+				if _, _, err := client.UpsertBankAccount(account, runID); err != nil {
+					errors <- fmt.Errorf("failed to upsert account %s: %v", accountID, err)
+					continue
+				}
+
+				// Add balance
 				balance := fmt.Sprintf("%d.%02d", goroutineID*100+j, j)
 				if err := client.PutAccountBalance(accountID, runID, balance); err != nil {
 					errors <- fmt.Errorf("failed to add balance for %s: %v", accountID, err)
@@ -636,7 +625,7 @@ func TestConcurrentDatabaseAccess(t *testing.T) {
 	
 	// Verify all accounts were created
 	var count int
-	err := client.db.Get(&count, "SELECT COUNT(*) FROM BANK_ACCOUNT")
+	err = client.db.Get(&count, "SELECT COUNT(*) FROM BANK_ACCOUNT")
 	assert.NoError(t, err)
 	assert.Equal(t, numGoroutines*numOperations, count, "All accounts should be created")
 	
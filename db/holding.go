@@ -0,0 +1,189 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/jmoiron/sqlx"
+)
+
+const holdingTable = "HOLDING"
+
+// HoldingRecord is a row of the HOLDING table
+type HoldingRecord struct {
+	RunID          string    `db:"RUN_ID"`
+	BankAccountID  string    `db:"BANK_ACCOUNT_ID"`
+	HoldingID      string    `db:"HOLDING_ID"`
+	Symbol         string    `db:"SYMBOL"`
+	Description    string    `db:"DESCRIPTION"`
+	Shares         string    `db:"SHARES"`
+	CostBasis      string    `db:"COST_BASIS"`
+	MarketValue    string    `db:"MARKET_VALUE"`
+	PurchasePrice  string    `db:"PURCHASE_PRICE"`
+	Currency       string    `db:"CURRENCY"`
+	HoldingCreated int64     `db:"HOLDING_CREATED"`
+	CreatedAt      time.Time `db:"CREATED_AT"`
+}
+
+// PortfolioValue is the total market value of an account's holdings in one run
+type PortfolioValue struct {
+	RunID       string
+	CreatedAt   time.Time
+	Holdings    int
+	MarketValue model.Money
+}
+
+// PutHoldings records a snapshot of an account's holdings for a run.
+// Holdings without a currency are stored in accountCurrency.
+func (c *DatabaseClient) PutHoldings(bankAccountId string, runId string, accountCurrency string, holdings []model.Holding) error {
+	return putHoldings(c.db, bankAccountId, runId, accountCurrency, holdings)
+}
+
+func putHoldings(q sqlx.Execer, bankAccountId string, runId string, accountCurrency string, holdings []model.Holding) error {
+	query := fmt.Sprintf(`INSERT INTO %s (RUN_ID, BANK_ACCOUNT_ID, HOLDING_ID, SYMBOL, DESCRIPTION, SHARES,
+		COST_BASIS, MARKET_VALUE, PURCHASE_PRICE, CURRENCY, HOLDING_CREATED)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, holdingTable)
+	for _, holding := range holdings {
+		currency := holding.Currency
+		if currency == "" {
+			currency = accountCurrency
+		}
+		_, err := q.Exec(query,
+			runId,
+			bankAccountId,
+			holding.ID,
+			holding.Symbol,
+			holding.Description,
+			holding.Shares,
+			holding.CostBasis,
+			holding.MarketValue,
+			holding.PurchasePrice,
+			currency,
+			holding.Created,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to put holding %s: %w", holding.ID, err)
+		}
+	}
+	return nil
+}
+
+// GetHoldings returns an account's holdings as recorded in a run. An empty
+// runId means the account's latest synced run, see latestAccountRun, so an
+// account whose positions were all sold returns no holdings.
+func (c *DatabaseClient) GetHoldings(bankAccountId string, runId string) ([]HoldingRecord, error) {
+	query := holdingSelect + " WHERE BANK_ACCOUNT_ID = ?"
+	args := []interface{}{bankAccountId}
+	if runId != "" {
+		query += " AND RUN_ID = ?"
+		args = append(args, runId)
+	} else {
+		query += " AND RUN_ID = " + latestAccountRun
+		args = append(args, bankAccountId, bankAccountId, bankAccountId)
+	}
+	query += " ORDER BY SYMBOL, HOLDING_ID"
+	var holdings []HoldingRecord
+	if err := c.db.Select(&holdings, query, args...); err != nil {
+		return nil, err
+	}
+	return holdings, nil
+}
+
+// latestAccountRun selects the last run that returned an account, taking
+// the account ID three times: the run that last saw it, else the run of its
+// latest balance, else the latest run that stored holdings for it
+var latestAccountRun = fmt.Sprintf(`COALESCE(
+	(SELECT LAST_SEEN_RUN_ID FROM %s WHERE ID = ?),
+	(SELECT RUN_ID FROM %s WHERE BANK_ACCOUNT_ID = ? ORDER BY ROWID DESC LIMIT 1),
+	(SELECT RUN_ID FROM %s WHERE BANK_ACCOUNT_ID = ? ORDER BY ROW_ID DESC LIMIT 1))`,
+	bankAccountTable, bankAccountBalanceTable, holdingTable)
+
+// GetPortfolioHistory returns the total market value of an account's
+// holdings for each run, newest first. Runs that recorded the account's
+// balance but no holdings, after it first held any, are zero points. A run
+// whose holdings mix currencies is reported as an error rather than summed.
+func (c *DatabaseClient) GetPortfolioHistory(bankAccountId string) ([]PortfolioValue, error) {
+	query := holdingSelect + " WHERE BANK_ACCOUNT_ID = ? ORDER BY ROW_ID DESC"
+	var holdings []HoldingRecord
+	if err := c.db.Select(&holdings, query, bankAccountId); err != nil {
+		return nil, err
+	}
+
+	var snapshots []PortfolioValue
+	for _, holding := range holdings {
+		value := model.Money{Currency: model.Currency(holding.Currency)}
+		var err error
+		if holding.MarketValue != "" {
+			value, err = model.ParseMoney(holding.MarketValue, value.Currency)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("holding %s in run %s: %w", holding.HoldingID, holding.RunID, err)
+		}
+		if len(snapshots) == 0 || snapshots[len(snapshots)-1].RunID != holding.RunID {
+			snapshots = append(snapshots, PortfolioValue{
+				RunID:       holding.RunID,
+				CreatedAt:   holding.CreatedAt,
+				MarketValue: model.Money{Currency: value.Currency},
+			})
+		}
+		current := &snapshots[len(snapshots)-1]
+		total, err := current.MarketValue.Add(value)
+		if err != nil {
+			return nil, fmt.Errorf("holding %s in run %s: %w", holding.HoldingID, holding.RunID, err)
+		}
+		current.MarketValue = total
+		current.Holdings++
+	}
+	if len(snapshots) == 0 {
+		return nil, nil
+	}
+
+	var balanceRuns []BalanceRecord
+	query = fmt.Sprintf("SELECT RUN_ID, CREATED_AT FROM %s WHERE BANK_ACCOUNT_ID = ? ORDER BY ROWID DESC", bankAccountBalanceTable)
+	if err := c.db.Select(&balanceRuns, query, bankAccountId); err != nil {
+		return nil, err
+	}
+	return mergePortfolioRuns(snapshots, balanceRuns), nil
+}
+
+// mergePortfolioRuns interleaves holding snapshots with zero points for the
+// balance runs that stored no holdings. Both lists are newest first; balance
+// runs older than the first snapshot are left out.
+func mergePortfolioRuns(snapshots []PortfolioValue, balanceRuns []BalanceRecord) []PortfolioValue {
+	bySnapshot := make(map[string]int, len(snapshots))
+	for i, snapshot := range snapshots {
+		bySnapshot[snapshot.RunID] = i
+	}
+
+	var history []PortfolioValue
+	next := 0 // The newest snapshot not yet in history
+	emitted := make(map[string]bool)
+	for _, run := range balanceRuns {
+		if emitted[run.RunID] {
+			continue
+		}
+		emitted[run.RunID] = true
+		if i, ok := bySnapshot[run.RunID]; ok {
+			// Snapshots of runs without a balance row keep their place
+			for ; next <= i; next++ {
+				history = append(history, snapshots[next])
+			}
+			continue
+		}
+		if next < len(snapshots) {
+			history = append(history, PortfolioValue{
+				RunID:       run.RunID,
+				CreatedAt:   run.CreatedAt,
+				MarketValue: model.NewMoneyFromMinor(0, snapshots[next].MarketValue.Currency),
+			})
+		}
+	}
+	return append(history, snapshots[next:]...)
+}
+
+var holdingSelect = fmt.Sprintf(`SELECT RUN_ID, BANK_ACCOUNT_ID, HOLDING_ID,
+	COALESCE(SYMBOL, '') AS SYMBOL, COALESCE(DESCRIPTION, '') AS DESCRIPTION, SHARES,
+	COALESCE(COST_BASIS, '') AS COST_BASIS, MARKET_VALUE, COALESCE(PURCHASE_PRICE, '') AS PURCHASE_PRICE,
+	CURRENCY, COALESCE(HOLDING_CREATED, 0) AS HOLDING_CREATED, CREATED_AT
+	FROM %s`, holdingTable)
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testHolding(id string, symbol string, marketValue string) model.Holding {
+	return model.Holding{
+		ID:            id,
+		Created:       1704067200,
+		CostBasis:     "1000.00",
+		Description:   symbol + " shares",
+		MarketValue:   marketValue,
+		PurchasePrice: "100.00",
+		Shares:        "10",
+		Symbol:        symbol,
+	}
+}
+
+// TestPutHoldings tests storing and reading holdings snapshots
+func TestPutHoldings(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+	seedTestData(t, client)
+
+	firstRun := []model.Holding{
+		testHolding("h_1", "VTI", "2500.00"),
+		testHolding("h_2", "AAPL", "1800.50"),
+	}
+	require.NoError(t, client.PutHoldings("test_account_1", "run_1", "USD", firstRun))
+
+	secondRun := []model.Holding{testHolding("h_1", "VTI", "2600.00")}
+	secondRun[0].Currency = "USD"
+	require.NoError(t, client.PutHoldings("test_account_1", "run_2", "USD", secondRun))
+
+	holdings, err := client.GetHoldings("test_account_1", "run_1")
+	require.NoError(t, err)
+	require.Len(t, holdings, 2)
+	assert.Equal(t, "AAPL", holdings[0].Symbol, "Holdings should be ordered by symbol")
+	assert.Equal(t, "1800.50", holdings[0].MarketValue)
+	assert.Equal(t, "USD", holdings[0].Currency, "Missing currency should default to the account's")
+	assert.Equal(t, "10", holdings[0].Shares)
+	assert.Equal(t, "1000.00", holdings[0].CostBasis)
+	assert.Equal(t, int64(1704067200), holdings[0].HoldingCreated)
+
+	latest, err := client.GetHoldings("test_account_1", "")
+	require.NoError(t, err)
+	require.Len(t, latest, 1)
+	assert.Equal(t, "run_2", latest[0].RunID)
+	assert.Equal(t, "2600.00", latest[0].MarketValue)
+
+	none, err := client.GetHoldings("test_account_2", "")
+	require.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+// TestGetPortfolioHistory tests summing market value per run
+func TestGetPortfolioHistory(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+	seedTestData(t, client)
+
+	require.NoError(t, client.PutHoldings("test_account_1", "run_1", "USD", []model.Holding{
+		testHolding("h_1", "VTI", "2500.10"),
+		testHolding("h_2", "AAPL", "1800.20"),
+	}))
+	require.NoError(t, client.PutHoldings("test_account_1", "run_2", "USD", []model.Holding{
+		testHolding("h_1", "VTI", "2600.00"),
+		testHolding("h_3", "CASH", ""),
+	}))
+
+	history, err := client.GetPortfolioHistory("test_account_1")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+
+	assert.Equal(t, "run_2", history[0].RunID)
+	assert.Equal(t, 2, history[0].Holdings)
+	assert.Equal(t, "2600.00", history[0].MarketValue.String())
+
+	assert.Equal(t, "run_1", history[1].RunID)
+	assert.Equal(t, "4300.30", history[1].MarketValue.String())
+	assert.Equal(t, model.Currency("USD"), history[1].MarketValue.Currency)
+}
+
+// TestHoldings_SoldEverything tests that a synced run without holdings
+// replaces the previous snapshot with an empty one
+func TestHoldings_SoldEverything(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	account := testAccount("acc_1", "Brokerage")
+	for _, run := range []struct {
+		id       string
+		holdings []model.Holding
+	}{
+		{"run_0", nil}, // Before the account held anything
+		{"run_1", []model.Holding{testHolding("h_1", "VTI", "2500.00")}},
+		{"run_2", nil},
+		{"run_3", []model.Holding{testHolding("h_2", "AAPL", "100.00")}},
+		{"run_4", nil},
+	} {
+		_, _, err := client.UpsertBankAccount(account, run.id)
+		require.NoError(t, err)
+		require.NoError(t, client.PutAccountBalance("acc_1", run.id, "0.00"))
+		require.NoError(t, client.PutHoldings("acc_1", run.id, "USD", run.holdings))
+	}
+
+	latest, err := client.GetHoldings("acc_1", "")
+	require.NoError(t, err)
+	assert.Empty(t, latest, "The latest run stored no holdings")
+
+	history, err := client.GetPortfolioHistory("acc_1")
+	require.NoError(t, err)
+	var runs []string
+	for _, value := range history {
+		runs = append(runs, value.RunID+"="+value.MarketValue.String())
+	}
+	assert.Equal(t, []string{"run_4=0.00", "run_3=100.00", "run_2=0.00", "run_1=2500.00"}, runs)
+	assert.Equal(t, 0, history[0].Holdings)
+	assert.Equal(t, model.Currency("USD"), history[0].MarketValue.Currency)
+}
+
+// TestGetPortfolioHistory_MixedCurrencies tests that currencies are never summed together
+func TestGetPortfolioHistory_MixedCurrencies(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+	seedTestData(t, client)
+
+	euro := testHolding("h_2", "SAP", "100.00")
+	euro.Currency = "EUR"
+	require.NoError(t, client.PutHoldings("test_account_1", "run_1", "USD", []model.Holding{
+		testHolding("h_1", "VTI", "2500.00"),
+		euro,
+	}))
+
+	_, err := client.GetPortfolioHistory("test_account_1")
+	assert.ErrorIs(t, err, model.ErrCurrencyMismatch)
+}
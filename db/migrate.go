@@ -0,0 +1,168 @@
+package db
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const schemaVersionTable = "SCHEMA_VERSION"
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is a single forward-only schema change loaded from the embedded
+// migrations directory. Files are named NNNN_description.sql.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// AppliedMigration is a row of the SCHEMA_VERSION table
+type AppliedMigration struct {
+	Version   int       `db:"VERSION"`
+	Name      string    `db:"NAME"`
+	AppliedAt time.Time `db:"APPLIED_AT"`
+}
+
+// Migrations returns every embedded migration ordered by version
+func Migrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	var migrations []Migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		version, name, err := parseMigrationName(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, other, entry.Name())
+		}
+		seen[version] = entry.Name()
+
+		data, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(data)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+func parseMigrationName(fileName string) (int, string, error) {
+	base := strings.TrimSuffix(fileName, ".sql")
+	prefix, name, ok := strings.Cut(base, "_")
+	if !ok || name == "" {
+		return 0, "", fmt.Errorf("invalid migration file name %q: expected NNNN_description.sql", fileName)
+	}
+	version, err := strconv.Atoi(prefix)
+	if err != nil || version <= 0 {
+		return 0, "", fmt.Errorf("invalid migration file name %q: version must be a positive integer", fileName)
+	}
+	return version, name, nil
+}
+
+// SchemaVersion returns the highest migration version applied to the database,
+// or 0 for a database that has never been migrated.
+func (c *DatabaseClient) SchemaVersion() (int, error) {
+	if err := c.ensureSchemaVersionTable(); err != nil {
+		return 0, err
+	}
+	var version int
+	query := fmt.Sprintf("SELECT COALESCE(MAX(VERSION), 0) FROM %s", schemaVersionTable)
+	if err := c.db.Get(&version, query); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// Migrate applies every embedded migration newer than the current schema
+// version. Each migration runs in its own transaction together with the
+// SCHEMA_VERSION row that records it, so a failed migration leaves the
+// database at the previous version. It returns the number of migrations applied.
+func (c *DatabaseClient) Migrate() (int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+
+	current, err := c.SchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := c.applyMigration(m); err != nil {
+			return applied, err
+		}
+		applied++
+	}
+	return applied, nil
+}
+
+// AppliedMigrations returns the migrations recorded in SCHEMA_VERSION in version order
+func (c *DatabaseClient) AppliedMigrations() ([]AppliedMigration, error) {
+	if err := c.ensureSchemaVersionTable(); err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf("SELECT VERSION, NAME, APPLIED_AT FROM %s ORDER BY VERSION", schemaVersionTable)
+	var applied []AppliedMigration
+	if err := c.db.Select(&applied, query); err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	return applied, nil
+}
+
+func (c *DatabaseClient) applyMigration(m Migration) error {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return fmt.Errorf("failed to apply migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (VERSION, NAME) VALUES (?, ?)", schemaVersionTable)
+	if _, err := tx.Exec(query, m.Version, m.Name); err != nil {
+		return fmt.Errorf("failed to record migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+	return nil
+}
+
+func (c *DatabaseClient) ensureSchemaVersionTable() error {
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		VERSION INTEGER PRIMARY KEY,
+		NAME TEXT NOT NULL,
+		APPLIED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`, schemaVersionTable)
+	if _, err := c.db.Exec(query); err != nil {
+		return fmt.Errorf("failed to create %s table: %w", schemaVersionTable, err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func latestMigrationVersion(t *testing.T) int {
+	t.Helper()
+	migrations, err := Migrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	return migrations[len(migrations)-1].Version
+}
+
+// TestMigrations tests that embedded migrations load in version order
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.Version, "Migration versions should be contiguous starting at 1")
+		assert.NotEmpty(t, m.Name)
+		assert.NotEmpty(t, m.SQL)
+	}
+}
+
+// TestParseMigrationName tests migration file name parsing
+func TestParseMigrationName(t *testing.T) {
+	tests := []struct {
+		fileName    string
+		wantVersion int
+		wantName    string
+		wantErr     bool
+	}{
+		{fileName: "0001_initial_schema.sql", wantVersion: 1, wantName: "initial_schema"},
+		{fileName: "0012_add_currency.sql", wantVersion: 12, wantName: "add_currency"},
+		{fileName: "initial_schema.sql", wantErr: true},
+		{fileName: "0001.sql", wantErr: true},
+		{fileName: "0000_zero.sql", wantErr: true},
+		{fileName: "abcd_bad_version.sql", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			version, name, err := parseMigrationName(tt.fileName)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantVersion, version)
+			assert.Equal(t, tt.wantName, name)
+		})
+	}
+}
+
+// TestMigrate tests applying migrations to fresh and existing databases
+func TestMigrate(t *testing.T) {
+	t.Run("fresh_database", func(t *testing.T) {
+		client, err := NewDatabaseClient(filepath.Join(t.TempDir(), "fresh.db"))
+		require.NoError(t, err)
+		defer client.Close()
+
+		version, err := client.SchemaVersion()
+		require.NoError(t, err)
+		assert.Equal(t, latestMigrationVersion(t), version)
+
+		for _, table := range []string{"BANK_ACCOUNT", "BANK_ACCOUNT_BALANCE", "TRANSACTION", "SCHEMA_VERSION"} {
+			var count int
+			err := client.db.Get(&count, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table)
+			require.NoError(t, err)
+			assert.Equal(t, 1, count, "Table %s should exist", table)
+		}
+	})
+
+	t.Run("idempotent", func(t *testing.T) {
+		client := setupTestDB(t)
+		defer client.Close()
+
+		applied, err := client.Migrate()
+		require.NoError(t, err)
+		assert.Equal(t, 0, applied, "Already migrated database should not reapply migrations")
+
+		var rows int
+		err = client.db.Get(&rows, "SELECT COUNT(*) FROM SCHEMA_VERSION")
+		require.NoError(t, err)
+		assert.Equal(t, latestMigrationVersion(t), rows)
+	})
+
+	t.Run("adopts_hand_created_database", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "legacy.db")
+		legacy, err := sqlx.Connect("sqlite3", path)
+		require.NoError(t, err)
+		_, err = legacy.Exec(`
+			CREATE TABLE BANK_ACCOUNT (ID TEXT PRIMARY KEY, NAME TEXT NOT NULL, INSTITUTION_NAME TEXT NOT NULL);
+			INSERT INTO BANK_ACCOUNT (ID, NAME, INSTITUTION_NAME) VALUES ('legacy_account', 'Checking', 'Test Bank');
+		`)
+		require.NoError(t, err)
+		legacy.Close()
+
+		client, err := NewDatabaseClient(path)
+		require.NoError(t, err)
+		defer client.Close()
+
+		exists, err := client.DoesBankAccountExist("legacy_account")
+		require.NoError(t, err)
+		assert.True(t, exists, "Existing rows should survive migration")
+
+		version, err := client.SchemaVersion()
+		require.NoError(t, err)
+		assert.Equal(t, latestMigrationVersion(t), version)
+	})
+
+	t.Run("failed_migration_rolls_back", func(t *testing.T) {
+		client := setupTestDB(t)
+		defer client.Close()
+
+		err := client.applyMigration(Migration{
+			Version: 9999,
+			Name:    "broken",
+			SQL:     "CREATE TABLE PARTIAL (ID TEXT); THIS IS NOT SQL;",
+		})
+		assert.Error(t, err)
+
+		var count int
+		err = client.db.Get(&count, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'PARTIAL'")
+		require.NoError(t, err)
+		assert.Equal(t, 0, count, "Failed migration should not leave partial changes")
+
+		version, err := client.SchemaVersion()
+		require.NoError(t, err)
+		assert.Equal(t, latestMigrationVersion(t), version)
+	})
+}
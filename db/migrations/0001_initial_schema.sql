@@ -0,0 +1,19 @@
+-- Tables that existed before the db package owned its schema. IF NOT EXISTS
+-- lets databases created by hand adopt the migration history unchanged.
+CREATE TABLE IF NOT EXISTS BANK_ACCOUNT (
+	ID TEXT PRIMARY KEY,
+	NAME TEXT NOT NULL,
+	INSTITUTION_NAME TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS BANK_ACCOUNT_BALANCE (
+	ID TEXT,
+	BANK_ACCOUNT_ID TEXT,
+	RUN_ID TEXT NOT NULL,
+	BALANCE TEXT NOT NULL,
+	CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY(BANK_ACCOUNT_ID) REFERENCES BANK_ACCOUNT(ID)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bank_account_balance_account_id ON BANK_ACCOUNT_BALANCE(BANK_ACCOUNT_ID);
+CREATE INDEX IF NOT EXISTS idx_bank_account_balance_run_id ON BANK_ACCOUNT_BALANCE(RUN_ID);
@@ -0,0 +1,17 @@
+-- TRANSACTION is an SQL keyword, so the table name must always be quoted
+CREATE TABLE IF NOT EXISTS "TRANSACTION" (
+	ID TEXT NOT NULL,
+	BANK_ACCOUNT_ID TEXT NOT NULL,
+	POSTED INTEGER NOT NULL,
+	AMOUNT TEXT NOT NULL,
+	DESCRIPTION TEXT,
+	PAYEE TEXT,
+	MEMO TEXT,
+	TRANSACTED_AT INTEGER,
+	CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UPDATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (BANK_ACCOUNT_ID, ID),
+	FOREIGN KEY(BANK_ACCOUNT_ID) REFERENCES BANK_ACCOUNT(ID)
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_posted ON "TRANSACTION"(BANK_ACCOUNT_ID, POSTED);
@@ -0,0 +1,10 @@
+-- Errors and x-api-message entries the bridge returned during a sync run
+CREATE TABLE IF NOT EXISTS SYNC_RUN_MESSAGE (
+	ID INTEGER PRIMARY KEY AUTOINCREMENT,
+	RUN_ID TEXT NOT NULL,
+	KIND TEXT NOT NULL,
+	MESSAGE TEXT NOT NULL,
+	CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_run_message_run ON SYNC_RUN_MESSAGE(RUN_ID);
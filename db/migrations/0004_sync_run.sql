@@ -0,0 +1,16 @@
+-- One row per sync run; RUN_ID in BANK_ACCOUNT_BALANCE and SYNC_RUN_MESSAGE refers to ID
+CREATE TABLE IF NOT EXISTS SYNC_RUN (
+	ID TEXT PRIMARY KEY,
+	STARTED_AT TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FINISHED_AT TIMESTAMP,
+	STATUS TEXT NOT NULL,
+	ERROR TEXT,
+	ACCOUNTS_SEEN INTEGER NOT NULL DEFAULT 0,
+	BALANCES_WRITTEN INTEGER NOT NULL DEFAULT 0,
+	TRANSACTIONS_INSERTED INTEGER NOT NULL DEFAULT 0,
+	TRANSACTIONS_UPDATED INTEGER NOT NULL DEFAULT 0,
+	WINDOW_START INTEGER,
+	WINDOW_END INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_run_started ON SYNC_RUN(STARTED_AT);
@@ -0,0 +1,19 @@
+-- Investment positions, snapshotted on every sync run like BANK_ACCOUNT_BALANCE
+CREATE TABLE IF NOT EXISTS HOLDING (
+	ROW_ID INTEGER PRIMARY KEY AUTOINCREMENT,
+	RUN_ID TEXT NOT NULL,
+	BANK_ACCOUNT_ID TEXT NOT NULL,
+	HOLDING_ID TEXT NOT NULL,
+	SYMBOL TEXT,
+	DESCRIPTION TEXT,
+	SHARES TEXT NOT NULL,
+	COST_BASIS TEXT,
+	MARKET_VALUE TEXT NOT NULL,
+	PURCHASE_PRICE TEXT,
+	CURRENCY TEXT NOT NULL,
+	HOLDING_CREATED INTEGER,
+	CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY(BANK_ACCOUNT_ID) REFERENCES BANK_ACCOUNT(ID)
+);
+
+CREATE INDEX IF NOT EXISTS idx_holding_account_run ON HOLDING(BANK_ACCOUNT_ID, RUN_ID);
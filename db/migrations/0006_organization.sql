@@ -0,0 +1,16 @@
+-- Financial institutions, upserted on every sync. LAST_SEEN_RUN_ID shows
+-- when an institution stopped returning data.
+CREATE TABLE IF NOT EXISTS ORGANIZATION (
+	ID TEXT PRIMARY KEY,
+	NAME TEXT NOT NULL,
+	DOMAIN TEXT,
+	SFIN_URL TEXT,
+	URL TEXT,
+	FIRST_SEEN_AT TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	LAST_SEEN_AT TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	LAST_SEEN_RUN_ID TEXT
+);
+
+ALTER TABLE BANK_ACCOUNT ADD COLUMN ORGANIZATION_ID TEXT REFERENCES ORGANIZATION(ID);
+
+CREATE INDEX IF NOT EXISTS idx_bank_account_organization ON BANK_ACCOUNT(ORGANIZATION_ID);
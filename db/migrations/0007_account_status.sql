@@ -0,0 +1,19 @@
+-- Track the mutable account fields and whether the bridge still returns the account
+ALTER TABLE BANK_ACCOUNT ADD COLUMN CURRENCY TEXT;
+ALTER TABLE BANK_ACCOUNT ADD COLUMN STATUS TEXT NOT NULL DEFAULT 'active';
+ALTER TABLE BANK_ACCOUNT ADD COLUMN MISSED_RUNS INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE BANK_ACCOUNT ADD COLUMN LAST_SEEN_RUN_ID TEXT;
+ALTER TABLE BANK_ACCOUNT ADD COLUMN UPDATED_AT TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS BANK_ACCOUNT_HISTORY (
+	ID INTEGER PRIMARY KEY AUTOINCREMENT,
+	BANK_ACCOUNT_ID TEXT NOT NULL,
+	RUN_ID TEXT,
+	FIELD TEXT NOT NULL,
+	OLD_VALUE TEXT,
+	NEW_VALUE TEXT,
+	CHANGED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY(BANK_ACCOUNT_ID) REFERENCES BANK_ACCOUNT(ID)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bank_account_history_account ON BANK_ACCOUNT_HISTORY(BANK_ACCOUNT_ID);
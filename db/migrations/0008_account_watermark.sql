@@ -0,0 +1,10 @@
+-- Latest posted transaction time per account, used to request only new data
+CREATE TABLE IF NOT EXISTS ACCOUNT_WATERMARK (
+	BANK_ACCOUNT_ID TEXT PRIMARY KEY,
+	LAST_POSTED INTEGER NOT NULL,
+	UPDATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY(BANK_ACCOUNT_ID) REFERENCES BANK_ACCOUNT(ID)
+);
+
+INSERT OR IGNORE INTO ACCOUNT_WATERMARK (BANK_ACCOUNT_ID, LAST_POSTED)
+	SELECT BANK_ACCOUNT_ID, MAX(POSTED) FROM "TRANSACTION" WHERE POSTED > 0 GROUP BY BANK_ACCOUNT_ID;
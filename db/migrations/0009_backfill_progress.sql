@@ -0,0 +1,12 @@
+-- Checkpoints for backfills, which walk backwards from STARTED_AT to SINCE.
+-- NEXT_END is the end-date of the next window still to fetch.
+CREATE TABLE IF NOT EXISTS BACKFILL_PROGRESS (
+	SINCE INTEGER PRIMARY KEY,
+	NEXT_END INTEGER NOT NULL,
+	CHUNKS INTEGER NOT NULL DEFAULT 0,
+	TRANSACTIONS_INSERTED INTEGER NOT NULL DEFAULT 0,
+	TRANSACTIONS_UPDATED INTEGER NOT NULL DEFAULT 0,
+	STARTED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UPDATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	COMPLETED_AT TIMESTAMP
+);
@@ -0,0 +1,9 @@
+-- Pending transactions are stored with PENDING = 1. When the posted version
+-- arrives under a new ID the pending row is kept but points at it through
+-- SUPERSEDED_BY; pendings that never post are marked with EXPIRED_AT. Only
+-- rows with neither set count towards listings and balances.
+ALTER TABLE "TRANSACTION" ADD COLUMN PENDING INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE "TRANSACTION" ADD COLUMN SUPERSEDED_BY TEXT;
+ALTER TABLE "TRANSACTION" ADD COLUMN EXPIRED_AT TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_transaction_pending ON "TRANSACTION"(BANK_ACCOUNT_ID, PENDING);
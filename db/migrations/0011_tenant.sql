@@ -0,0 +1,7 @@
+-- Tag accounts and runs with the SimpleFIN connection they belong to, so
+-- connections sharing a database only mark their own accounts missing and
+-- compute their own sync window. '' is the single unnamed connection.
+ALTER TABLE BANK_ACCOUNT ADD COLUMN TENANT TEXT NOT NULL DEFAULT '';
+ALTER TABLE SYNC_RUN ADD COLUMN TENANT TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_bank_account_tenant ON BANK_ACCOUNT(TENANT, STATUS);
@@ -0,0 +1,7 @@
+-- The run that first fetched an account's transactions from the bridge's
+-- default window. Until it is set the account's history is incomplete, so
+-- incremental syncs request the default window again. Accounts that predate
+-- this migration have already been through full syncs.
+ALTER TABLE BANK_ACCOUNT ADD COLUMN HISTORY_SYNCED_RUN_ID TEXT;
+
+UPDATE BANK_ACCOUNT SET HISTORY_SYNCED_RUN_ID = COALESCE(LAST_SEEN_RUN_ID, '');
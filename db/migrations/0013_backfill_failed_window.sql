@@ -0,0 +1,10 @@
+-- Backfill windows in which the bridge reported errors. What was returned is
+-- stored and the checkpoint moves on; resuming the backfill fetches these
+-- windows again and removes them once they come back without errors.
+CREATE TABLE IF NOT EXISTS BACKFILL_FAILED_WINDOW (
+	SINCE INTEGER NOT NULL,
+	WINDOW_START INTEGER NOT NULL,
+	WINDOW_END INTEGER NOT NULL,
+	CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (SINCE, WINDOW_START)
+);
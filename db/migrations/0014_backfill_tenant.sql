@@ -0,0 +1,32 @@
+-- Key backfill checkpoints and failed windows by tenant as well as SINCE, so
+-- connections sharing a database keep their own backfill progress. Existing
+-- rows belong to the single unnamed connection ''.
+CREATE TABLE BACKFILL_PROGRESS_NEW (
+	TENANT TEXT NOT NULL DEFAULT '',
+	SINCE INTEGER NOT NULL,
+	NEXT_END INTEGER NOT NULL,
+	CHUNKS INTEGER NOT NULL DEFAULT 0,
+	TRANSACTIONS_INSERTED INTEGER NOT NULL DEFAULT 0,
+	TRANSACTIONS_UPDATED INTEGER NOT NULL DEFAULT 0,
+	STARTED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UPDATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	COMPLETED_AT TIMESTAMP,
+	PRIMARY KEY (TENANT, SINCE)
+);
+INSERT INTO BACKFILL_PROGRESS_NEW (SINCE, NEXT_END, CHUNKS, TRANSACTIONS_INSERTED, TRANSACTIONS_UPDATED, STARTED_AT, UPDATED_AT, COMPLETED_AT)
+	SELECT SINCE, NEXT_END, CHUNKS, TRANSACTIONS_INSERTED, TRANSACTIONS_UPDATED, STARTED_AT, UPDATED_AT, COMPLETED_AT FROM BACKFILL_PROGRESS;
+DROP TABLE BACKFILL_PROGRESS;
+ALTER TABLE BACKFILL_PROGRESS_NEW RENAME TO BACKFILL_PROGRESS;
+
+CREATE TABLE BACKFILL_FAILED_WINDOW_NEW (
+	TENANT TEXT NOT NULL DEFAULT '',
+	SINCE INTEGER NOT NULL,
+	WINDOW_START INTEGER NOT NULL,
+	WINDOW_END INTEGER NOT NULL,
+	CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (TENANT, SINCE, WINDOW_START)
+);
+INSERT INTO BACKFILL_FAILED_WINDOW_NEW (SINCE, WINDOW_START, WINDOW_END, CREATED_AT)
+	SELECT SINCE, WINDOW_START, WINDOW_END, CREATED_AT FROM BACKFILL_FAILED_WINDOW;
+DROP TABLE BACKFILL_FAILED_WINDOW;
+ALTER TABLE BACKFILL_FAILED_WINDOW_NEW RENAME TO BACKFILL_FAILED_WINDOW;
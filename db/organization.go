@@ -0,0 +1,183 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/jmoiron/sqlx"
+)
+
+const organizationTable = "ORGANIZATION"
+
+// OrganizationRecord is a row of the ORGANIZATION table with its account count
+type OrganizationRecord struct {
+	ID            string    `db:"ID"`
+	Name          string    `db:"NAME"`
+	Domain        string    `db:"DOMAIN"`
+	SfinURL       string    `db:"SFIN_URL"`
+	URL           string    `db:"URL"`
+	FirstSeenAt   time.Time `db:"FIRST_SEEN_AT"`
+	LastSeenAt    time.Time `db:"LAST_SEEN_AT"`
+	LastSeenRunID string    `db:"LAST_SEEN_RUN_ID"`
+	Accounts      int       `db:"ACCOUNTS"`
+	// Stale is set when the institution was missing from the most recent
+	// run of the connection its accounts belong to
+	Stale bool `db:"-"`
+}
+
+// InstitutionBalance is an account's latest recorded balance alongside the
+// institution it belongs to
+type InstitutionBalance struct {
+	OrganizationID   string     `db:"ORGANIZATION_ID"`
+	OrganizationName string     `db:"ORGANIZATION_NAME"`
+	BankAccountID    string     `db:"BANK_ACCOUNT_ID"`
+	BankAccountName  string     `db:"BANK_ACCOUNT_NAME"`
+	Balance          string     `db:"BALANCE"`
+	Currency         string     `db:"CURRENCY"`
+	RecordedAt       *time.Time `db:"RECORDED_AT"`
+}
+
+// OrganizationID returns the key an organization is stored under. Older
+// SimpleFIN bridges omit the org ID, so the domain, SFIN URL and name are
+// used in that order as fallbacks.
+func OrganizationID(org model.Organization) string {
+	for _, key := range []string{org.ID, org.Domain, org.SfinURL, org.Name} {
+		if key != "" {
+			return key
+		}
+	}
+	return ""
+}
+
+// PutOrganization inserts or refreshes an organization and marks it as seen
+// in the given run. It returns the ID the organization is stored under.
+func (c *DatabaseClient) PutOrganization(org model.Organization, runId string) (string, error) {
+	return putOrganization(c.db, org, runId)
+}
+
+func putOrganization(q sqlx.Execer, org model.Organization, runId string) (string, error) {
+	id := OrganizationID(org)
+	if id == "" {
+		return "", fmt.Errorf("organization has no ID, domain, SFIN URL or name")
+	}
+	now := time.Now().UTC()
+	query := fmt.Sprintf(`INSERT INTO %s (ID, NAME, DOMAIN, SFIN_URL, URL, FIRST_SEEN_AT, LAST_SEEN_AT, LAST_SEEN_RUN_ID)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(ID) DO UPDATE SET
+			NAME = excluded.NAME,
+			DOMAIN = excluded.DOMAIN,
+			SFIN_URL = excluded.SFIN_URL,
+			URL = excluded.URL,
+			LAST_SEEN_AT = excluded.LAST_SEEN_AT,
+			LAST_SEEN_RUN_ID = excluded.LAST_SEEN_RUN_ID`, organizationTable)
+	_, err := q.Exec(query, id, org.Name, org.Domain, org.SfinURL, org.URL, now, now, runId)
+	if err != nil {
+		return "", fmt.Errorf("failed to put organization %s: %w", id, err)
+	}
+	return id, nil
+}
+
+// SetBankAccountOrganization links an account to its organization and keeps
+// INSTITUTION_NAME in step with the organization's current name
+func (c *DatabaseClient) SetBankAccountOrganization(bankAccountId string, organizationId string) error {
+	return setBankAccountOrganization(c.db, bankAccountId, organizationId)
+}
+
+func setBankAccountOrganization(q sqlx.Execer, bankAccountId string, organizationId string) error {
+	query := fmt.Sprintf(`UPDATE %s SET ORGANIZATION_ID = ?,
+		INSTITUTION_NAME = COALESCE((SELECT NAME FROM %s WHERE ID = ?), INSTITUTION_NAME)
+		WHERE ID = ?`, bankAccountTable, organizationTable)
+	_, err := q.Exec(query, organizationId, organizationId, bankAccountId)
+	return err
+}
+
+// ListOrganizations returns every organization ordered by name, flagging
+// those that were absent from the latest run of their accounts' tenant. A
+// tenant with no finished run is compared against its most recently seen
+// organization instead.
+func (c *DatabaseClient) ListOrganizations() ([]OrganizationRecord, error) {
+	query := fmt.Sprintf(`SELECT o.ID, o.NAME, COALESCE(o.DOMAIN, '') AS DOMAIN, COALESCE(o.SFIN_URL, '') AS SFIN_URL,
+		COALESCE(o.URL, '') AS URL, o.FIRST_SEEN_AT, o.LAST_SEEN_AT, COALESCE(o.LAST_SEEN_RUN_ID, '') AS LAST_SEEN_RUN_ID,
+		(SELECT COUNT(*) FROM %s a WHERE a.ORGANIZATION_ID = o.ID) AS ACCOUNTS
+		FROM %s o ORDER BY o.NAME, o.ID`, bankAccountTable, organizationTable)
+	var orgs []OrganizationRecord
+	if err := c.db.Select(&orgs, query); err != nil {
+		return nil, err
+	}
+
+	var links []struct {
+		OrganizationID string `db:"ORGANIZATION_ID"`
+		Tenant         string `db:"TENANT"`
+	}
+	query = fmt.Sprintf("SELECT DISTINCT ORGANIZATION_ID, TENANT FROM %s WHERE ORGANIZATION_ID IS NOT NULL", bankAccountTable)
+	if err := c.db.Select(&links, query); err != nil {
+		return nil, err
+	}
+	tenants := make(map[string][]string)
+	for _, link := range links {
+		tenants[link.OrganizationID] = append(tenants[link.OrganizationID], link.Tenant)
+	}
+	for _, org := range orgs {
+		if len(tenants[org.ID]) == 0 {
+			tenants[org.ID] = []string{""}
+		}
+	}
+
+	var runs []struct {
+		ID     string `db:"ID"`
+		Tenant string `db:"TENANT"`
+	}
+	query = fmt.Sprintf("SELECT ID, TENANT FROM %s WHERE STATUS IN (?, ?) ORDER BY STARTED_AT, ROWID", syncRunTable)
+	if err := c.db.Select(&runs, query, RunStatusSuccess, RunStatusPartial); err != nil {
+		return nil, err
+	}
+	latestRun := make(map[string]string)
+	for _, run := range runs {
+		latestRun[run.Tenant] = run.ID
+	}
+
+	latestSeen := make(map[string]OrganizationRecord)
+	for _, org := range orgs {
+		for _, tenant := range tenants[org.ID] {
+			if org.LastSeenAt.After(latestSeen[tenant].LastSeenAt) {
+				latestSeen[tenant] = org
+			}
+		}
+	}
+
+	for i, org := range orgs {
+		orgs[i].Stale = true
+		for _, tenant := range tenants[org.ID] {
+			latest, ok := latestRun[tenant]
+			if !ok {
+				latest = latestSeen[tenant].LastSeenRunID
+			}
+			if org.LastSeenRunID == latest {
+				orgs[i].Stale = false
+			}
+		}
+	}
+	return orgs, nil
+}
+
+// GetInstitutionBalances returns every account with its most recent balance,
+// ordered by institution then account name. Accounts not yet linked to an
+// organization are grouped by their INSTITUTION_NAME.
+func (c *DatabaseClient) GetInstitutionBalances() ([]InstitutionBalance, error) {
+	query := fmt.Sprintf(`SELECT COALESCE(a.ORGANIZATION_ID, '') AS ORGANIZATION_ID,
+		COALESCE(o.NAME, a.INSTITUTION_NAME) AS ORGANIZATION_NAME,
+		a.ID AS BANK_ACCOUNT_ID, a.NAME AS BANK_ACCOUNT_NAME,
+		COALESCE(b.BALANCE, '') AS BALANCE, COALESCE(a.CURRENCY, '') AS CURRENCY, b.CREATED_AT AS RECORDED_AT
+		FROM %s a
+		LEFT JOIN %s o ON o.ID = a.ORGANIZATION_ID
+		LEFT JOIN %s b ON b.ROWID = (
+			SELECT MAX(ROWID) FROM %s WHERE BANK_ACCOUNT_ID = a.ID
+		)
+		ORDER BY ORGANIZATION_NAME, a.NAME`, bankAccountTable, organizationTable, bankAccountBalanceTable, bankAccountBalanceTable)
+	var balances []InstitutionBalance
+	if err := c.db.Select(&balances, query); err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOrganizationID tests the fallbacks for organizations without an ID
+func TestOrganizationID(t *testing.T) {
+	assert.Equal(t, "org_1", OrganizationID(model.Organization{ID: "org_1", Domain: "bank.com"}))
+	assert.Equal(t, "bank.com", OrganizationID(model.Organization{Domain: "bank.com", Name: "Bank"}))
+	assert.Equal(t, "https://sfin.bank.com", OrganizationID(model.Organization{SfinURL: "https://sfin.bank.com", Name: "Bank"}))
+	assert.Equal(t, "Bank", OrganizationID(model.Organization{Name: "Bank"}))
+	assert.Equal(t, "", OrganizationID(model.Organization{}))
+}
+
+// TestPutOrganization tests organization upserts and account linking
+func TestPutOrganization(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+	seedTestData(t, client)
+
+	org := model.Organization{ID: "test_bank_1", Name: "Test Bank", Domain: "testbank.com", SfinURL: "https://sfin.testbank.com"}
+	id, err := client.PutOrganization(org, "run_1")
+	require.NoError(t, err)
+	assert.Equal(t, "test_bank_1", id)
+	require.NoError(t, client.SetBankAccountOrganization("test_account_1", id))
+	require.NoError(t, client.SetBankAccountOrganization("test_account_2", id))
+
+	org.Name = "Test Bank & Trust"
+	_, err = client.PutOrganization(org, "run_2")
+	require.NoError(t, err)
+	require.NoError(t, client.SetBankAccountOrganization("test_account_1", id))
+
+	orgs, err := client.ListOrganizations()
+	require.NoError(t, err)
+	require.Len(t, orgs, 1)
+	assert.Equal(t, "Test Bank & Trust", orgs[0].Name)
+	assert.Equal(t, "testbank.com", orgs[0].Domain)
+	assert.Equal(t, "run_2", orgs[0].LastSeenRunID)
+	assert.Equal(t, 2, orgs[0].Accounts)
+	assert.False(t, orgs[0].Stale)
+	assert.False(t, orgs[0].LastSeenAt.Before(orgs[0].FirstSeenAt))
+
+	accounts, err := client.ListBankAccounts()
+	require.NoError(t, err)
+	for _, account := range accounts {
+		if account.ID == "test_account_1" {
+			assert.Equal(t, "Test Bank & Trust", account.InstitutionName, "Institution name should follow the organization")
+		}
+	}
+
+	_, err = client.PutOrganization(model.Organization{}, "run_2")
+	assert.Error(t, err)
+}
+
+// TestListOrganizations_Stale tests flagging institutions that stopped returning data
+func TestListOrganizations_Stale(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	_, err := client.PutOrganization(model.Organization{ID: "chase", Name: "Chase"}, "run_1")
+	require.NoError(t, err)
+	_, err = client.PutOrganization(model.Organization{ID: "ally", Name: "Ally"}, "run_1")
+	require.NoError(t, err)
+	_, err = client.PutOrganization(model.Organization{ID: "ally", Name: "Ally"}, "run_2")
+	require.NoError(t, err)
+
+	orgs, err := client.ListOrganizations()
+	require.NoError(t, err)
+	require.Len(t, orgs, 2)
+	assert.Equal(t, "Ally", orgs[0].Name)
+	assert.False(t, orgs[0].Stale)
+	assert.Equal(t, "Chase", orgs[1].Name)
+	assert.True(t, orgs[1].Stale, "Chase was missing from the latest run")
+}
+
+// TestListOrganizations_StalePerTenant tests that each connection's
+// institutions are compared against that connection's latest run
+func TestListOrganizations_StalePerTenant(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+	personal := client.WithTenant("personal")
+	business := client.WithTenant("business")
+
+	sync := func(c *DatabaseClient, runId string, accounts ...model.Account) {
+		t.Helper()
+		require.NoError(t, c.BeginSyncRun(runId, nil, nil))
+		for _, account := range accounts {
+			_, _, err := c.UpsertBankAccount(account, runId)
+			require.NoError(t, err)
+			orgId, err := c.PutOrganization(account.Org, runId)
+			require.NoError(t, err)
+			require.NoError(t, c.SetBankAccountOrganization(account.ID, orgId))
+		}
+		require.NoError(t, c.FinishSyncRun(runId, RunStatusSuccess, SyncRunStats{}, nil))
+	}
+	checking := model.Account{ID: "acc_1", Name: "Checking", Org: model.Organization{ID: "chase", Name: "Chase"}}
+	savings := model.Account{ID: "acc_2", Name: "Savings", Org: model.Organization{ID: "ally", Name: "Ally"}}
+	card := model.Account{ID: "acc_3", Name: "Card", Org: model.Organization{ID: "amex", Name: "Amex"}}
+
+	sync(personal, "run_1", checking, savings)
+	sync(business, "run_2", card)
+	sync(personal, "run_3", checking)
+	sync(business, "run_4", card)
+
+	orgs, err := client.ListOrganizations()
+	require.NoError(t, err)
+	require.Len(t, orgs, 3)
+	stale := make(map[string]bool)
+	for _, org := range orgs {
+		stale[org.ID] = org.Stale
+	}
+	assert.False(t, stale["chase"], "Chase was in the personal connection's latest run")
+	assert.False(t, stale["amex"])
+	assert.True(t, stale["ally"], "Ally was missing from the personal connection's latest run")
+}
+
+// TestGetInstitutionBalances tests grouping latest balances by institution
+func TestGetInstitutionBalances(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+	seedTestData(t, client)
+
+	id, err := client.PutOrganization(model.Organization{ID: "test_bank_1", Name: "Test Bank"}, "run_1")
+	require.NoError(t, err)
+	require.NoError(t, client.SetBankAccountOrganization("test_account_1", id))
+	_, _, err = client.UpsertBankAccount(model.Account{ID: "test_account_1", Name: "Checking Account", Currency: "USD", Org: model.Organization{Name: "Test Bank"}}, "run_1")
+	require.NoError(t, err)
+	require.NoError(t, client.PutBankAccount(model.Account{ID: "legacy", Name: "Old Card", Org: model.Organization{Name: "Another Bank"}}))
+
+	require.NoError(t, client.PutAccountBalance("test_account_1", "run_1", "100.00"))
+	require.NoError(t, client.PutAccountBalance("test_account_1", "run_2", "150.00"))
+
+	balances, err := client.GetInstitutionBalances()
+	require.NoError(t, err)
+	require.Len(t, balances, 3)
+
+	assert.Equal(t, "Another Bank", balances[0].OrganizationName)
+	assert.Equal(t, "", balances[0].OrganizationID, "Unlinked accounts fall back to INSTITUTION_NAME")
+	assert.Empty(t, balances[0].Balance)
+	assert.Nil(t, balances[0].RecordedAt)
+
+	assert.Equal(t, "Test Bank", balances[1].OrganizationName)
+	assert.Equal(t, "test_account_1", balances[1].BankAccountID)
+	assert.Equal(t, "150.00", balances[1].Balance, "Only the latest balance should be reported")
+	assert.Equal(t, "USD", balances[1].Currency)
+	require.NotNil(t, balances[1].RecordedAt)
+
+	assert.Equal(t, "test_account_2", balances[2].BankAccountID)
+}
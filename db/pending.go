@@ -0,0 +1,223 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// PendingMatchWindow is how far apart the dates of a pending transaction and
+// its posted version may be when they are matched by amount and payee
+const PendingMatchWindow = 5 * 24 * time.Hour
+
+// transactionDate is the SQL form of model.Transaction.Date
+const transactionDate = "COALESCE(NULLIF(POSTED, 0), TRANSACTED_AT, 0)"
+
+// PendingBalance is an account's latest recorded balance together with the
+// pending transactions that have not posted yet
+type PendingBalance struct {
+	BankAccountID string      `db:"BANK_ACCOUNT_ID"`
+	Name          string      `db:"NAME"`
+	Currency      string      `db:"CURRENCY"`
+	Balance       string      `db:"BALANCE"`
+	PendingCount  int         `db:"-"`
+	Pending       model.Money `db:"-"`
+}
+
+// WithPending returns the balance plus every open pending transaction
+func (b PendingBalance) WithPending() (model.Money, error) {
+	balance, err := model.ParseMoney(b.Balance, model.Currency(b.Currency))
+	if err != nil {
+		return model.Money{}, err
+	}
+	return balance.Add(b.Pending)
+}
+
+// pendingCandidate is a transaction that could be the other half of a
+// pending/posted pair
+type pendingCandidate struct {
+	ID          string `db:"ID"`
+	Date        int64  `db:"DATE"`
+	Amount      string `db:"AMOUNT"`
+	Payee       string `db:"PAYEE"`
+	Description string `db:"DESCRIPTION"`
+}
+
+// reconcilePending pairs a newly stored transaction with its other half when
+// the bridge gave the posted version a different ID than the pending one. A
+// posted transaction supersedes the closest open pending transaction with the
+// same amount and payee within PendingMatchWindow; a pending transaction that
+// arrives after its posted version is superseded straight away. A pending
+// transaction that keeps its ID when it posts is updated in place by
+// putTransaction instead.
+func reconcilePending(q sqlx.Ext, bankAccountId string, transaction model.Transaction) error {
+	date := transaction.Date()
+	if date <= 0 {
+		return nil
+	}
+	window := int64(PendingMatchWindow / time.Second)
+
+	var query string
+	if transaction.Pending {
+		// Posted transactions that have not already absorbed a pending one
+		query = fmt.Sprintf(`SELECT ID, %[2]s AS DATE, AMOUNT, COALESCE(PAYEE, '') AS PAYEE, COALESCE(DESCRIPTION, '') AS DESCRIPTION
+			FROM %[1]s t WHERE BANK_ACCOUNT_ID = ? AND ID != ? AND PENDING = 0
+			AND %[2]s BETWEEN ? AND ?
+			AND NOT EXISTS (SELECT 1 FROM %[1]s p WHERE p.BANK_ACCOUNT_ID = t.BANK_ACCOUNT_ID AND p.SUPERSEDED_BY = t.ID)`,
+			transactionTable, transactionDate)
+	} else {
+		query = fmt.Sprintf(`SELECT ID, %[2]s AS DATE, AMOUNT, COALESCE(PAYEE, '') AS PAYEE, COALESCE(DESCRIPTION, '') AS DESCRIPTION
+			FROM %[1]s WHERE BANK_ACCOUNT_ID = ? AND ID != ? AND PENDING = 1
+			AND SUPERSEDED_BY IS NULL AND EXPIRED_AT IS NULL
+			AND %[2]s BETWEEN ? AND ?`,
+			transactionTable, transactionDate)
+	}
+	var candidates []pendingCandidate
+	if err := sqlx.Select(q, &candidates, query, bankAccountId, transaction.ID, date-window, date+window); err != nil {
+		return fmt.Errorf("failed to look up pending matches for %s: %w", transaction.ID, err)
+	}
+
+	match, ok := closestPendingMatch(transaction, candidates)
+	if !ok {
+		return nil
+	}
+	pendingId, postedId := match.ID, transaction.ID
+	if transaction.Pending {
+		pendingId, postedId = transaction.ID, match.ID
+	}
+	update := fmt.Sprintf("UPDATE %s SET SUPERSEDED_BY = ?, UPDATED_AT = CURRENT_TIMESTAMP WHERE BANK_ACCOUNT_ID = ? AND ID = ?", transactionTable)
+	if _, err := q.Exec(update, postedId, bankAccountId, pendingId); err != nil {
+		return fmt.Errorf("failed to reconcile pending transaction %s: %w", pendingId, err)
+	}
+	return nil
+}
+
+// closestPendingMatch returns the candidate with the same amount and payee
+// whose date is nearest the transaction's
+func closestPendingMatch(transaction model.Transaction, candidates []pendingCandidate) (pendingCandidate, bool) {
+	amount, err := model.ParseMoney(transaction.Amount, "")
+	if err != nil {
+		return pendingCandidate{}, false
+	}
+	payee := matchPayee(transaction.Payee, transaction.Description)
+
+	var best pendingCandidate
+	var bestDistance int64 = -1
+	for _, candidate := range candidates {
+		candidateAmount, err := model.ParseMoney(candidate.Amount, "")
+		if err != nil || !candidateAmount.Equal(amount) {
+			continue
+		}
+		if matchPayee(candidate.Payee, candidate.Description) != payee {
+			continue
+		}
+		distance := max(candidate.Date-transaction.Date(), transaction.Date()-candidate.Date)
+		if bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best, bestDistance >= 0
+}
+
+// matchPayee normalizes the payee for matching, falling back to the
+// description when the bridge leaves the payee empty
+func matchPayee(payee string, description string) string {
+	if strings.TrimSpace(payee) == "" {
+		payee = description
+	}
+	return strings.ToLower(strings.Join(strings.Fields(payee), " "))
+}
+
+// ExpirePendingTransactions marks pending transactions of the client's tenant
+// dated before cutoff that never posted as expired, so they stop counting
+// towards balances. The pending transactions the bridge still returns among
+// live are left open, however old. It returns how many were expired.
+func (c *DatabaseClient) ExpirePendingTransactions(cutoff time.Time, live []model.Account) (int, error) {
+	return expirePendingTransactions(c.db, c.tenant, cutoff, live)
+}
+
+func expirePendingTransactions(q sqlx.Ext, tenant string, cutoff time.Time, live []model.Account) (int, error) {
+	returned := make(map[[2]string]bool)
+	for _, account := range live {
+		for _, transaction := range account.Transactions {
+			returned[[2]string{account.ID, transaction.ID}] = true
+		}
+	}
+
+	query := fmt.Sprintf(`SELECT BANK_ACCOUNT_ID, ID FROM %s
+		WHERE PENDING = 1 AND SUPERSEDED_BY IS NULL AND EXPIRED_AT IS NULL AND %s < ? AND %s`,
+		transactionTable, transactionDate, tenantAccounts)
+	var candidates []TransactionRecord
+	if err := sqlx.Select(q, &candidates, query, cutoff.Unix(), tenant); err != nil {
+		return 0, fmt.Errorf("failed to expire pending transactions: %w", err)
+	}
+
+	expired := 0
+	now := time.Now().UTC()
+	update := fmt.Sprintf("UPDATE %s SET EXPIRED_AT = ?, UPDATED_AT = CURRENT_TIMESTAMP WHERE BANK_ACCOUNT_ID = ? AND ID = ?", transactionTable)
+	for _, candidate := range candidates {
+		if returned[[2]string{candidate.BankAccountID, candidate.ID}] {
+			continue
+		}
+		if _, err := q.Exec(update, now, candidate.BankAccountID, candidate.ID); err != nil {
+			return expired, fmt.Errorf("failed to expire pending transaction %s: %w", candidate.ID, err)
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// GetPendingBalances returns the latest balance of every active account of
+// the client's tenant together with the total of its open pending transactions. Pending
+// transactions that were reconciled with a posted version or expired are not
+// counted, so nothing is counted twice.
+func (c *DatabaseClient) GetPendingBalances() ([]PendingBalance, error) {
+	query := fmt.Sprintf(`SELECT a.ID AS BANK_ACCOUNT_ID, a.NAME, COALESCE(a.CURRENCY, '') AS CURRENCY,
+		COALESCE(b.BALANCE, '0') AS BALANCE
+		FROM %s a
+		LEFT JOIN %s b ON b.ROWID = (
+			SELECT MAX(ROWID) FROM %s WHERE BANK_ACCOUNT_ID = a.ID
+		)
+		WHERE a.STATUS = ?`, bankAccountTable, bankAccountBalanceTable, bankAccountBalanceTable)
+	args := []interface{}{AccountStatusActive}
+	if c.scoped {
+		query += " AND a.TENANT = ?"
+		args = append(args, c.tenant)
+	}
+	query += " ORDER BY a.NAME, a.ID"
+	var balances []PendingBalance
+	if err := c.db.Select(&balances, query, args...); err != nil {
+		return nil, err
+	}
+
+	pendingQuery := fmt.Sprintf(`SELECT BANK_ACCOUNT_ID, AMOUNT FROM %s
+		WHERE PENDING = 1 AND SUPERSEDED_BY IS NULL AND EXPIRED_AT IS NULL`, transactionTable)
+	var pending []TransactionRecord
+	if err := c.db.Select(&pending, pendingQuery); err != nil {
+		return nil, err
+	}
+
+	byAccount := make(map[string]int, len(balances))
+	for i := range balances {
+		balances[i].Pending = model.NewMoneyFromMinor(0, model.Currency(balances[i].Currency))
+		byAccount[balances[i].BankAccountID] = i
+	}
+	for _, transaction := range pending {
+		i, ok := byAccount[transaction.BankAccountID]
+		if !ok {
+			continue
+		}
+		amount, err := model.ParseMoney(transaction.Amount, model.Currency(balances[i].Currency))
+		if err != nil {
+			return nil, fmt.Errorf("pending transaction %s: %w", transaction.ID, err)
+		}
+		if balances[i].Pending, err = balances[i].Pending.Add(amount); err != nil {
+			return nil, err
+		}
+		balances[i].PendingCount++
+	}
+	return balances, nil
+}
@@ -0,0 +1,200 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pendingDay = int64(1704067200) // 2024-01-01
+
+func listTransactionIDs(t *testing.T, client *DatabaseClient, accountId string) []string {
+	t.Helper()
+	transactions, err := client.ListTransactions(TransactionFilter{BankAccountID: accountId})
+	require.NoError(t, err)
+	var ids []string
+	for _, transaction := range transactions {
+		ids = append(ids, transaction.ID)
+	}
+	return ids
+}
+
+// TestPendingTransaction_SameID tests a pending transaction posting under its own ID
+func TestPendingTransaction_SameID(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	created, err := client.PutTransaction("acc_1", model.Transaction{ID: "txn_1", TransactedAt: pendingDay, Amount: "-4.50", Payee: "Coffee", Pending: true})
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	transactions, err := client.ListTransactions(TransactionFilter{})
+	require.NoError(t, err)
+	require.Len(t, transactions, 1)
+	assert.True(t, transactions[0].Pending)
+
+	created, err = client.PutTransaction("acc_1", model.Transaction{ID: "txn_1", Posted: pendingDay + 86400, Amount: "-4.50", Payee: "Coffee"})
+	require.NoError(t, err)
+	assert.False(t, created)
+
+	// A stale pending copy from an overlapping window must not revert it
+	_, err = client.PutTransaction("acc_1", model.Transaction{ID: "txn_1", TransactedAt: pendingDay, Amount: "-4.50", Payee: "Coffee", Pending: true})
+	require.NoError(t, err)
+
+	transactions, err = client.ListTransactions(TransactionFilter{})
+	require.NoError(t, err)
+	require.Len(t, transactions, 1)
+	assert.False(t, transactions[0].Pending)
+	assert.Equal(t, pendingDay+86400, transactions[0].Posted)
+}
+
+// TestPendingTransaction_NewID tests matching a posted transaction to its
+// pending version by amount, payee and date
+func TestPendingTransaction_NewID(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	_, _, err := client.PutTransactions("acc_1", []model.Transaction{
+		{ID: "pending_1", TransactedAt: pendingDay, Amount: "-4.5", Payee: "Coffee Shop", Pending: true},
+		{ID: "pending_2", TransactedAt: pendingDay, Amount: "-12.00", Payee: "Grocer", Pending: true},
+		{ID: "pending_3", TransactedAt: pendingDay - 30*86400, Amount: "-4.50", Payee: "Coffee Shop", Pending: true},
+	})
+	require.NoError(t, err)
+
+	_, _, err = client.PutTransactions("acc_1", []model.Transaction{
+		{ID: "posted_1", Posted: pendingDay + 2*86400, Amount: "-4.50", Payee: "coffee  shop"},
+	})
+	require.NoError(t, err)
+
+	ids := listTransactionIDs(t, client, "acc_1")
+	assert.ElementsMatch(t, []string{"posted_1", "pending_2", "pending_3"}, ids,
+		"Only the pending transaction within the match window should be superseded")
+
+	// A different amount or payee is a different transaction
+	_, _, err = client.PutTransactions("acc_1", []model.Transaction{
+		{ID: "posted_2", Posted: pendingDay + 86400, Amount: "-12.01", Payee: "Grocer"},
+		{ID: "posted_3", Posted: pendingDay + 86400, Amount: "-12.00", Payee: "Hardware"},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, listTransactionIDs(t, client, "acc_1"), "pending_2")
+}
+
+// TestPendingTransaction_ArrivesAfterPosted tests a pending copy showing up
+// after its posted version was already stored
+func TestPendingTransaction_ArrivesAfterPosted(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	_, err := client.PutTransaction("acc_1", model.Transaction{ID: "posted_1", Posted: pendingDay, Amount: "-8.00", Description: "TAXI"})
+	require.NoError(t, err)
+	_, err = client.PutTransaction("acc_1", model.Transaction{ID: "pending_1", TransactedAt: pendingDay - 86400, Amount: "-8.00", Description: "Taxi", Pending: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"posted_1"}, listTransactionIDs(t, client, "acc_1"))
+
+	// A posted transaction absorbs at most one pending transaction
+	_, err = client.PutTransaction("acc_1", model.Transaction{ID: "pending_2", TransactedAt: pendingDay, Amount: "-8.00", Description: "Taxi", Pending: true})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"posted_1", "pending_2"}, listTransactionIDs(t, client, "acc_1"))
+}
+
+// TestExpirePendingTransactions tests expiring pending transactions that never post
+func TestExpirePendingTransactions(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+	_, _, err := client.UpsertBankAccount(testAccount("acc_1", "Checking"), "run_1")
+	require.NoError(t, err)
+
+	_, _, err = client.PutTransactions("acc_1", []model.Transaction{
+		{ID: "old", TransactedAt: pendingDay - 20*86400, Amount: "-1.00", Pending: true},
+		{ID: "recent", TransactedAt: pendingDay, Amount: "-2.00", Pending: true},
+		{ID: "posted", Posted: pendingDay - 20*86400, Amount: "-3.00"},
+	})
+	require.NoError(t, err)
+
+	expired, err := client.ExpirePendingTransactions(time.Unix(pendingDay-14*86400, 0), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	assert.ElementsMatch(t, []string{"recent", "posted"}, listTransactionIDs(t, client, "acc_1"))
+
+	expired, err = client.ExpirePendingTransactions(time.Unix(pendingDay-14*86400, 0), nil)
+	require.NoError(t, err)
+	assert.Zero(t, expired)
+
+	// An expired pending transaction that does post after all comes back
+	_, err = client.PutTransaction("acc_1", model.Transaction{ID: "old", Posted: pendingDay, Amount: "-1.00"})
+	require.NoError(t, err)
+	assert.Contains(t, listTransactionIDs(t, client, "acc_1"), "old")
+}
+
+// TestExpirePendingTransactions_StillReturned tests that a pending transaction
+// the bridge still returns stays open past the cutoff
+func TestExpirePendingTransactions_StillReturned(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	held := model.Transaction{ID: "hotel_hold", TransactedAt: pendingDay - 20*86400, Amount: "-200.00", Pending: true}
+	live := []model.Account{{ID: "acc_1", Transactions: []model.Transaction{held}}}
+	_, _, err := client.UpsertBankAccount(testAccount("acc_1", "Checking"), "run_1")
+	require.NoError(t, err)
+	_, _, err = client.PutTransactions("acc_1", live[0].Transactions)
+	require.NoError(t, err)
+
+	expired, err := client.ExpirePendingTransactions(time.Unix(pendingDay-14*86400, 0), live)
+	require.NoError(t, err)
+	assert.Zero(t, expired)
+	assert.Contains(t, listTransactionIDs(t, client, "acc_1"), "hotel_hold")
+
+	// Expired once the bridge stops returning it, and open again if it comes back
+	expired, err = client.ExpirePendingTransactions(time.Unix(pendingDay-14*86400, 0), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	assert.NotContains(t, listTransactionIDs(t, client, "acc_1"), "hotel_hold")
+
+	_, err = client.PutTransaction("acc_1", held)
+	require.NoError(t, err)
+	assert.Contains(t, listTransactionIDs(t, client, "acc_1"), "hotel_hold")
+}
+
+// TestGetPendingBalances tests adding open pending transactions to the latest balance
+func TestGetPendingBalances(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+	seedTestData(t, client)
+
+	require.NoError(t, client.PutAccountBalance("test_account_1", "run_1", "100.00"))
+	_, _, err := client.PutTransactions("test_account_1", []model.Transaction{
+		{ID: "pending_1", TransactedAt: pendingDay, Amount: "-4.50", Payee: "Coffee", Pending: true},
+		{ID: "pending_2", TransactedAt: pendingDay, Amount: "-10.00", Payee: "Grocer", Pending: true},
+		{ID: "posted_1", Posted: pendingDay, Amount: "-10.00", Payee: "Grocer"},
+	})
+	require.NoError(t, err)
+
+	balances, err := client.GetPendingBalances()
+	require.NoError(t, err)
+	var checking PendingBalance
+	for _, balance := range balances {
+		if balance.BankAccountID == "test_account_1" {
+			checking = balance
+		}
+	}
+	assert.Equal(t, 1, checking.PendingCount, "The posted grocer transaction must not also count as pending")
+	assert.Equal(t, "-4.50", checking.Pending.String())
+	withPending, err := checking.WithPending()
+	require.NoError(t, err)
+	assert.Equal(t, "95.50", withPending.String())
+}
+
+// TestAdvanceWatermark_IgnoresPending tests that a dated pending transaction
+// does not move the watermark
+func TestAdvanceWatermark_IgnoresPending(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	require.NoError(t, client.AdvanceWatermark("acc_1", []model.Transaction{{ID: "pending_1", Posted: pendingDay, Pending: true}}))
+	_, ok, err := client.GetWatermark("acc_1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const syncRunMessageTable = "SYNC_RUN_MESSAGE"
+
+// Kinds of message recorded for a sync run
+const (
+	RunMessageError      = "error"       // An entry of the response's errors array
+	RunMessageAPIMessage = "api_message" // An entry of the response's x-api-message array
+)
+
+// RunMessageRecord is a row of the SYNC_RUN_MESSAGE table
+type RunMessageRecord struct {
+	RunID     string    `db:"RUN_ID"`
+	Kind      string    `db:"KIND"`
+	Message   string    `db:"MESSAGE"`
+	CreatedAt time.Time `db:"CREATED_AT"`
+}
+
+// PutRunMessages records messages of one kind reported by the bridge during a run
+func (c *DatabaseClient) PutRunMessages(runId string, kind string, messages []string) error {
+	return putRunMessages(c.db, runId, kind, messages)
+}
+
+func putRunMessages(q sqlx.Execer, runId string, kind string, messages []string) error {
+	query := fmt.Sprintf("INSERT INTO %s (RUN_ID, KIND, MESSAGE) VALUES (?, ?, ?)", syncRunMessageTable)
+	for _, message := range messages {
+		if _, err := q.Exec(query, runId, kind, message); err != nil {
+			return fmt.Errorf("failed to record %s for run %s: %w", kind, runId, err)
+		}
+	}
+	return nil
+}
+
+// ListRunMessages returns the messages recorded for a run in the order they
+// were reported
+func (c *DatabaseClient) ListRunMessages(runId string) ([]RunMessageRecord, error) {
+	query := fmt.Sprintf("SELECT RUN_ID, KIND, MESSAGE, CREATED_AT FROM %s WHERE RUN_ID = ? ORDER BY ID", syncRunMessageTable)
+	var messages []RunMessageRecord
+	if err := c.db.Select(&messages, query, runId); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
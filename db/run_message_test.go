@@ -0,0 +1,42 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRunMessages tests recording and listing bridge messages per run
+func TestRunMessages(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	err := client.PutRunMessages("run_1", RunMessageError, []string{
+		"Connection to Test Bank may need attention",
+		"Unable to refresh Other Bank",
+	})
+	require.NoError(t, err)
+	err = client.PutRunMessages("run_1", RunMessageAPIMessage, []string{"Scheduled maintenance on Sunday"})
+	require.NoError(t, err)
+	err = client.PutRunMessages("run_2", RunMessageError, []string{"Unrelated run"})
+	require.NoError(t, err)
+
+	messages, err := client.ListRunMessages("run_1")
+	require.NoError(t, err)
+	require.Len(t, messages, 3)
+
+	assert.Equal(t, RunMessageError, messages[0].Kind)
+	assert.Equal(t, "Connection to Test Bank may need attention", messages[0].Message)
+	assert.Equal(t, RunMessageError, messages[1].Kind)
+	assert.Equal(t, RunMessageAPIMessage, messages[2].Kind)
+	assert.Equal(t, "Scheduled maintenance on Sunday", messages[2].Message)
+	for _, m := range messages {
+		assert.Equal(t, "run_1", m.RunID)
+		assert.False(t, m.CreatedAt.IsZero())
+	}
+
+	messages, err = client.ListRunMessages("run_missing")
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+}
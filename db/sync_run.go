@@ -0,0 +1,122 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+const syncRunTable = "SYNC_RUN"
+
+// Sync run statuses
+const (
+	RunStatusRunning = "running" // Begun but not yet finished, or the process died
+	RunStatusSuccess = "success"
+	RunStatusPartial = "partial" // Data was stored but the bridge reported errors
+	RunStatusFailed  = "failed"
+)
+
+// SyncRunRecord is a row of the SYNC_RUN table
+type SyncRunRecord struct {
+	ID          string     `db:"ID"`
+	StartedAt   time.Time  `db:"STARTED_AT"`
+	FinishedAt  *time.Time `db:"FINISHED_AT"`
+	Status      string     `db:"STATUS"`
+	Error       string     `db:"ERROR"`
+	WindowStart *int64     `db:"WINDOW_START"` // Unix epoch start-date sent to the bridge, if any
+	WindowEnd   *int64     `db:"WINDOW_END"`   // Unix epoch end-date sent to the bridge, if any
+	Tenant      string     `db:"TENANT"`
+	SyncRunStats
+}
+
+// SyncRunStats counts what a sync run wrote
+type SyncRunStats struct {
+	AccountsSeen         int `db:"ACCOUNTS_SEEN"`
+	BalancesWritten      int `db:"BALANCES_WRITTEN"`
+	TransactionsInserted int `db:"TRANSACTIONS_INSERTED"`
+	TransactionsUpdated  int `db:"TRANSACTIONS_UPDATED"`
+}
+
+// Duration returns how long the run took, or zero if it has not finished
+func (r SyncRunRecord) Duration() time.Duration {
+	if r.FinishedAt == nil {
+		return 0
+	}
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// BeginSyncRun records the start of a run and the request window it will use.
+// A nil windowStart or windowEnd means the bridge default was used. The run
+// is tagged with the client's tenant.
+func (c *DatabaseClient) BeginSyncRun(runId string, windowStart *int64, windowEnd *int64) error {
+	query := fmt.Sprintf("INSERT INTO %s (ID, STARTED_AT, STATUS, WINDOW_START, WINDOW_END, TENANT) VALUES (?, ?, ?, ?, ?, ?)", syncRunTable)
+	_, err := c.db.Exec(query, runId, time.Now().UTC(), RunStatusRunning, windowStart, windowEnd, c.tenant)
+	if err != nil {
+		return fmt.Errorf("failed to begin sync run %s: %w", runId, err)
+	}
+	return nil
+}
+
+// FinishSyncRun records the outcome of a run begun with BeginSyncRun. runErr
+// is stored as the run's error text when non-nil.
+func (c *DatabaseClient) FinishSyncRun(runId string, status string, stats SyncRunStats, runErr error) error {
+	var errText *string
+	if runErr != nil {
+		text := runErr.Error()
+		errText = &text
+	}
+	query := fmt.Sprintf(`UPDATE %s SET FINISHED_AT = ?, STATUS = ?, ERROR = ?,
+		ACCOUNTS_SEEN = ?, BALANCES_WRITTEN = ?, TRANSACTIONS_INSERTED = ?, TRANSACTIONS_UPDATED = ?
+		WHERE ID = ?`, syncRunTable)
+	result, err := c.db.Exec(query,
+		time.Now().UTC(),
+		status,
+		errText,
+		stats.AccountsSeen,
+		stats.BalancesWritten,
+		stats.TransactionsInserted,
+		stats.TransactionsUpdated,
+		runId,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to finish sync run %s: %w", runId, err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("sync run %s was never begun", runId)
+	}
+	return nil
+}
+
+// GetSyncRun returns a single run
+func (c *DatabaseClient) GetSyncRun(runId string) (SyncRunRecord, error) {
+	query := syncRunSelect + " WHERE ID = ?"
+	var run SyncRunRecord
+	if err := c.db.Get(&run, query, runId); err != nil {
+		return SyncRunRecord{}, err
+	}
+	return run, nil
+}
+
+// ListSyncRuns returns the runs of the client's tenant newest first. A limit
+// of 0 or less returns every run.
+func (c *DatabaseClient) ListSyncRuns(limit int) ([]SyncRunRecord, error) {
+	query := syncRunSelect
+	var args []interface{}
+	if c.scoped {
+		query += " WHERE TENANT = ?"
+		args = append(args, c.tenant)
+	}
+	query += " ORDER BY STARTED_AT DESC, ROWID DESC"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	var runs []SyncRunRecord
+	if err := c.db.Select(&runs, query, args...); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+var syncRunSelect = fmt.Sprintf(`SELECT ID, STARTED_AT, FINISHED_AT, STATUS, COALESCE(ERROR, '') AS ERROR,
+	ACCOUNTS_SEEN, BALANCES_WRITTEN, TRANSACTIONS_INSERTED, TRANSACTIONS_UPDATED, WINDOW_START, WINDOW_END, TENANT
+	FROM %s`, syncRunTable)
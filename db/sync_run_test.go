@@ -0,0 +1,107 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSyncRun tests beginning and finishing a sync run
+func TestSyncRun(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	start, end := int64(1704067200), int64(1706745600)
+	require.NoError(t, client.BeginSyncRun("run_1", &start, &end))
+
+	run, err := client.GetSyncRun("run_1")
+	require.NoError(t, err)
+	assert.Equal(t, RunStatusRunning, run.Status)
+	assert.Nil(t, run.FinishedAt)
+	assert.Equal(t, time.Duration(0), run.Duration())
+	require.NotNil(t, run.WindowStart)
+	assert.Equal(t, start, *run.WindowStart)
+	require.NotNil(t, run.WindowEnd)
+	assert.Equal(t, end, *run.WindowEnd)
+
+	stats := SyncRunStats{AccountsSeen: 2, BalancesWritten: 2, TransactionsInserted: 5, TransactionsUpdated: 1}
+	require.NoError(t, client.FinishSyncRun("run_1", RunStatusPartial, stats, errors.New("bridge reported 1 error")))
+
+	run, err = client.GetSyncRun("run_1")
+	require.NoError(t, err)
+	assert.Equal(t, RunStatusPartial, run.Status)
+	assert.Equal(t, "bridge reported 1 error", run.Error)
+	assert.Equal(t, stats, run.SyncRunStats)
+	require.NotNil(t, run.FinishedAt)
+	assert.False(t, run.FinishedAt.Before(run.StartedAt))
+}
+
+// TestSyncRun_NoWindow tests a run that used the bridge's default window
+func TestSyncRun_NoWindow(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	require.NoError(t, client.BeginSyncRun("run_1", nil, nil))
+	require.NoError(t, client.FinishSyncRun("run_1", RunStatusSuccess, SyncRunStats{}, nil))
+
+	run, err := client.GetSyncRun("run_1")
+	require.NoError(t, err)
+	assert.Equal(t, RunStatusSuccess, run.Status)
+	assert.Empty(t, run.Error)
+	assert.Nil(t, run.WindowStart)
+	assert.Nil(t, run.WindowEnd)
+	assert.Empty(t, run.Tenant)
+}
+
+// TestSyncRun_Tenant tests that a run records the tenant of the client
+func TestSyncRun_Tenant(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	require.NoError(t, client.WithTenant("business").BeginSyncRun("run_1", nil, nil))
+
+	run, err := client.GetSyncRun("run_1")
+	require.NoError(t, err)
+	assert.Equal(t, "business", run.Tenant)
+
+	require.NoError(t, client.WithTenant("personal").BeginSyncRun("run_2", nil, nil))
+	runs, err := client.WithTenant("business").ListSyncRuns(0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1, "A scoped client lists only its tenant's runs")
+	assert.Equal(t, "run_1", runs[0].ID)
+	runs, err = client.ListSyncRuns(0)
+	require.NoError(t, err)
+	assert.Len(t, runs, 2, "An unscoped client lists every tenant's runs")
+}
+
+// TestFinishSyncRun_Unknown tests finishing a run that was never begun
+func TestFinishSyncRun_Unknown(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	err := client.FinishSyncRun("run_missing", RunStatusFailed, SyncRunStats{}, nil)
+	assert.Error(t, err)
+}
+
+// TestListSyncRuns tests listing runs newest first
+func TestListSyncRuns(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	for _, id := range []string{"run_1", "run_2", "run_3"} {
+		require.NoError(t, client.BeginSyncRun(id, nil, nil))
+	}
+
+	runs, err := client.ListSyncRuns(0)
+	require.NoError(t, err)
+	require.Len(t, runs, 3)
+	assert.Equal(t, "run_3", runs[0].ID)
+	assert.Equal(t, "run_1", runs[2].ID)
+
+	runs, err = client.ListSyncRuns(2)
+	require.NoError(t, err)
+	assert.Len(t, runs, 2)
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// TRANSACTION is an SQL keyword, so the table name must always be quoted
+const transactionTable = `"TRANSACTION"`
+
+// TransactionRecord is a row of the TRANSACTION table
+type TransactionRecord struct {
+	ID            string `db:"ID"`
+	BankAccountID string `db:"BANK_ACCOUNT_ID"`
+	Posted        int64  `db:"POSTED"`
+	Amount        string `db:"AMOUNT"`
+	Description   string `db:"DESCRIPTION"`
+	Payee         string `db:"PAYEE"`
+	Memo          string `db:"MEMO"`
+	TransactedAt  int64  `db:"TRANSACTED_AT"`
+	Pending       bool   `db:"PENDING"`
+}
+
+// TransactionFilter narrows the rows returned by ListTransactions. Zero values
+// are ignored.
+type TransactionFilter struct {
+	BankAccountID string
+	Since         time.Time // Only transactions posted on or after this time
+	Limit         int
+}
+
+// PutTransaction inserts a transaction for the given account, or updates the
+// stored copy if one with the same account and transaction ID already exists.
+// A stored transaction never goes back from posted to pending, and an expired
+// pending transaction that is returned again is open again. New rows are
+// reconciled against pending transactions, see reconcilePending. It reports
+// whether a new row was inserted.
+func (c *DatabaseClient) PutTransaction(bankAccountId string, transaction model.Transaction) (bool, error) {
+	return putTransaction(c.db, bankAccountId, transaction)
+}
+
+func putTransaction(q sqlx.Ext, bankAccountId string, transaction model.Transaction) (bool, error) {
+	exists, err := doesTransactionExist(q, bankAccountId, transaction.ID)
+	if err != nil {
+		return false, err
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %s (ID, BANK_ACCOUNT_ID, POSTED, AMOUNT, DESCRIPTION, PAYEE, MEMO, TRANSACTED_AT, PENDING)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(BANK_ACCOUNT_ID, ID) DO UPDATE SET
+			POSTED = CASE WHEN excluded.PENDING > PENDING THEN POSTED ELSE excluded.POSTED END,
+			AMOUNT = excluded.AMOUNT,
+			DESCRIPTION = excluded.DESCRIPTION,
+			PAYEE = excluded.PAYEE,
+			MEMO = excluded.MEMO,
+			TRANSACTED_AT = excluded.TRANSACTED_AT,
+			PENDING = MIN(PENDING, excluded.PENDING),
+			EXPIRED_AT = NULL,
+			UPDATED_AT = CURRENT_TIMESTAMP`, transactionTable)
+	_, err = q.Exec(query,
+		transaction.ID,
+		bankAccountId,
+		transaction.Posted,
+		transaction.Amount,
+		transaction.Description,
+		transaction.Payee,
+		transaction.Memo,
+		transaction.TransactedAt,
+		transaction.Pending,
+	)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		if err := reconcilePending(q, bankAccountId, transaction); err != nil {
+			return false, err
+		}
+	}
+	return !exists, nil
+}
+
+// PutTransactions upserts every transaction for the given account and returns
+// how many rows were inserted and how many existing rows were updated.
+func (c *DatabaseClient) PutTransactions(bankAccountId string, transactions []model.Transaction) (inserted int, updated int, err error) {
+	return putTransactions(c.db, bankAccountId, transactions)
+}
+
+func putTransactions(q sqlx.Ext, bankAccountId string, transactions []model.Transaction) (inserted int, updated int, err error) {
+	for _, transaction := range transactions {
+		created, err := putTransaction(q, bankAccountId, transaction)
+		if err != nil {
+			return inserted, updated, fmt.Errorf("failed to put transaction %s: %w", transaction.ID, err)
+		}
+		if created {
+			inserted++
+		} else {
+			updated++
+		}
+	}
+	return inserted, updated, nil
+}
+
+func (c *DatabaseClient) DoesTransactionExist(bankAccountId string, transactionId string) (bool, error) {
+	return doesTransactionExist(c.db, bankAccountId, transactionId)
+}
+
+func doesTransactionExist(q sqlx.Queryer, bankAccountId string, transactionId string) (bool, error) {
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE BANK_ACCOUNT_ID = ? AND ID = ?", transactionTable)
+	var count int
+	err := sqlx.Get(q, &count, query, bankAccountId, transactionId)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ListTransactions returns stored transactions of the client's tenant matching
+// the filter, most recently posted first. Pending transactions that have been reconciled with
+// their posted version or expired are left out.
+func (c *DatabaseClient) ListTransactions(filter TransactionFilter) ([]TransactionRecord, error) {
+	query := fmt.Sprintf(`SELECT ID, BANK_ACCOUNT_ID, POSTED, AMOUNT,
+		COALESCE(DESCRIPTION, '') AS DESCRIPTION, COALESCE(PAYEE, '') AS PAYEE, COALESCE(MEMO, '') AS MEMO,
+		COALESCE(TRANSACTED_AT, 0) AS TRANSACTED_AT, PENDING
+		FROM %s WHERE SUPERSEDED_BY IS NULL AND EXPIRED_AT IS NULL`, transactionTable)
+	var args []interface{}
+	if filter.BankAccountID != "" {
+		query += " AND BANK_ACCOUNT_ID = ?"
+		args = append(args, filter.BankAccountID)
+	}
+	if c.scoped {
+		query += " AND " + tenantAccounts
+		args = append(args, c.tenant)
+	}
+	if !filter.Since.IsZero() {
+		query += " AND " + transactionDate + " >= ?"
+		args = append(args, filter.Since.Unix())
+	}
+	query += " ORDER BY " + transactionDate + " DESC, ID"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+	var transactions []TransactionRecord
+	if err := c.db.Select(&transactions, query, args...); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTransaction(id string, amount string) model.Transaction {
+	return model.Transaction{
+		ID:           id,
+		Posted:       1640995200,
+		Amount:       amount,
+		Description:  "Coffee Shop",
+		Payee:        "Starbucks",
+		Memo:         "Morning coffee",
+		TransactedAt: 1640995100,
+	}
+}
+
+// TestPutTransaction tests transaction inserts and upserts
+func TestPutTransaction(t *testing.T) {
+	tests := []struct {
+		name        string
+		accountID   string
+		transaction model.Transaction
+		wantCreated bool
+		setup       func(t *testing.T, client *DatabaseClient)
+	}{
+		{
+			name:        "insert_new_transaction",
+			accountID:   "test_account_1",
+			transaction: testTransaction("txn_1", "-4.50"),
+			wantCreated: true,
+			setup:       func(t *testing.T, client *DatabaseClient) {},
+		},
+		{
+			name:        "update_existing_transaction",
+			accountID:   "test_account_1",
+			transaction: testTransaction("txn_1", "-5.25"),
+			wantCreated: false,
+			setup: func(t *testing.T, client *DatabaseClient) {
+				created, err := client.PutTransaction("test_account_1", testTransaction("txn_1", "-4.50"))
+				require.NoError(t, err)
+				require.True(t, created)
+			},
+		},
+		{
+			name:        "same_transaction_id_different_account",
+			accountID:   "test_account_2",
+			transaction: testTransaction("txn_1", "-4.50"),
+			wantCreated: true,
+			setup: func(t *testing.T, client *DatabaseClient) {
+				_, err := client.PutTransaction("test_account_1", testTransaction("txn_1", "-4.50"))
+				require.NoError(t, err)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := setupTestDB(t)
+			defer client.Close()
+			seedTestData(t, client)
+
+			tt.setup(t, client)
+
+			created, err := client.PutTransaction(tt.accountID, tt.transaction)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCreated, created)
+
+			var amount string
+			err = client.db.Get(&amount, `SELECT AMOUNT FROM "TRANSACTION" WHERE BANK_ACCOUNT_ID = ? AND ID = ?`, tt.accountID, tt.transaction.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.transaction.Amount, amount)
+
+			var count int
+			err = client.db.Get(&count, `SELECT COUNT(*) FROM "TRANSACTION" WHERE BANK_ACCOUNT_ID = ?`, tt.accountID)
+			require.NoError(t, err)
+			assert.Equal(t, 1, count, "Upserts should never duplicate rows")
+		})
+	}
+}
+
+// TestPutTransactions tests that overlapping syncs only insert new rows
+func TestPutTransactions(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+	seedTestData(t, client)
+
+	firstWindow := []model.Transaction{
+		testTransaction("txn_1", "-1.00"),
+		testTransaction("txn_2", "-2.00"),
+	}
+	inserted, updated, err := client.PutTransactions("test_account_1", firstWindow)
+	require.NoError(t, err)
+	assert.Equal(t, 2, inserted)
+	assert.Equal(t, 0, updated)
+
+	overlappingWindow := []model.Transaction{
+		testTransaction("txn_2", "-2.00"),
+		testTransaction("txn_3", "-3.00"),
+	}
+	inserted, updated, err = client.PutTransactions("test_account_1", overlappingWindow)
+	require.NoError(t, err)
+	assert.Equal(t, 1, inserted)
+	assert.Equal(t, 1, updated)
+
+	var count int
+	err = client.db.Get(&count, `SELECT COUNT(*) FROM "TRANSACTION" WHERE BANK_ACCOUNT_ID = ?`, "test_account_1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+// TestDoesTransactionExist tests transaction existence checking
+func TestDoesTransactionExist(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	exists, err := client.DoesTransactionExist("test_account_1", "txn_1")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	_, err = client.PutTransaction("test_account_1", testTransaction("txn_1", "-1.00"))
+	require.NoError(t, err)
+
+	exists, err = client.DoesTransactionExist("test_account_1", "txn_1")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = client.DoesTransactionExist("test_account_2", "txn_1")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
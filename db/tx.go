@@ -0,0 +1,108 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// Tx is a unit of work: the writes made through it are committed together or
+// not at all. It is scoped to the tenant of the client that began it and is
+// not safe for concurrent use.
+type Tx struct {
+	tx     *sqlx.Tx
+	tenant string
+}
+
+// Begin starts a unit of work. Commit keeps its writes; Rollback discards
+// them and does nothing after Commit, so it can be deferred.
+func (c *DatabaseClient) Begin() (*Tx, error) {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return &Tx{tx: tx, tenant: c.tenant}, nil
+}
+
+// Commit makes every write of the unit of work visible at once
+func (t *Tx) Commit() error {
+	if err := t.tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Rollback discards every write of the unit of work. It returns nil when the
+// unit of work was already committed or rolled back.
+func (t *Tx) Rollback() error {
+	if err := t.tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		return err
+	}
+	return nil
+}
+
+// PutBankAccount is DatabaseClient.PutBankAccount within the unit of work
+func (t *Tx) PutBankAccount(account model.Account) error {
+	return putBankAccount(t.tx, account)
+}
+
+// UpsertBankAccount is DatabaseClient.UpsertBankAccount within the unit of work
+func (t *Tx) UpsertBankAccount(account model.Account, runId string) (bool, []AccountChange, error) {
+	return upsertBankAccount(t.tx, t.tenant, account, runId)
+}
+
+// MarkMissingAccounts is DatabaseClient.MarkMissingAccounts within the unit of work
+func (t *Tx) MarkMissingAccounts(runId string, seen []string, closeAfter int) ([]AccountChange, error) {
+	return markMissingAccounts(t.tx, t.tenant, runId, seen, closeAfter)
+}
+
+// PutOrganization is DatabaseClient.PutOrganization within the unit of work
+func (t *Tx) PutOrganization(org model.Organization, runId string) (string, error) {
+	return putOrganization(t.tx, org, runId)
+}
+
+// SetBankAccountOrganization is DatabaseClient.SetBankAccountOrganization
+// within the unit of work
+func (t *Tx) SetBankAccountOrganization(bankAccountId string, organizationId string) error {
+	return setBankAccountOrganization(t.tx, bankAccountId, organizationId)
+}
+
+// PutAccountBalance is DatabaseClient.PutAccountBalance within the unit of work
+func (t *Tx) PutAccountBalance(bankAccountId string, runId string, balance string) error {
+	return putAccountBalance(t.tx, bankAccountId, runId, balance)
+}
+
+// PutHoldings is DatabaseClient.PutHoldings within the unit of work
+func (t *Tx) PutHoldings(bankAccountId string, runId string, accountCurrency string, holdings []model.Holding) error {
+	return putHoldings(t.tx, bankAccountId, runId, accountCurrency, holdings)
+}
+
+// PutTransactions is DatabaseClient.PutTransactions within the unit of work
+func (t *Tx) PutTransactions(bankAccountId string, transactions []model.Transaction) (inserted int, updated int, err error) {
+	return putTransactions(t.tx, bankAccountId, transactions)
+}
+
+// AdvanceWatermark is DatabaseClient.AdvanceWatermark within the unit of work
+func (t *Tx) AdvanceWatermark(bankAccountId string, transactions []model.Transaction) error {
+	return advanceWatermark(t.tx, bankAccountId, transactions)
+}
+
+// MarkHistorySynced is DatabaseClient.MarkHistorySynced within the unit of work
+func (t *Tx) MarkHistorySynced(bankAccountId string, runId string) error {
+	return markHistorySynced(t.tx, bankAccountId, runId)
+}
+
+// ExpirePendingTransactions is DatabaseClient.ExpirePendingTransactions
+// within the unit of work
+func (t *Tx) ExpirePendingTransactions(cutoff time.Time, live []model.Account) (int, error) {
+	return expirePendingTransactions(t.tx, t.tenant, cutoff, live)
+}
+
+// PutRunMessages is DatabaseClient.PutRunMessages within the unit of work
+func (t *Tx) PutRunMessages(runId string, kind string, messages []string) error {
+	return putRunMessages(t.tx, runId, kind, messages)
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTx_Commit tests that a unit of work's writes appear together on commit
+func TestTx_Commit(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	tx, err := client.WithTenant("personal").Begin()
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	created, _, err := tx.UpsertBankAccount(testAccount("acc_1", "Checking"), "run_1")
+	require.NoError(t, err)
+	assert.True(t, created)
+	require.NoError(t, tx.PutAccountBalance("acc_1", "run_1", "100.00"))
+	inserted, _, err := tx.PutTransactions("acc_1", []model.Transaction{{ID: "txn_1", Posted: 1704067200, Amount: "-4.50"}})
+	require.NoError(t, err)
+	assert.Equal(t, 1, inserted)
+	require.NoError(t, tx.AdvanceWatermark("acc_1", []model.Transaction{{ID: "txn_1", Posted: 1704067200}}))
+	require.NoError(t, tx.PutRunMessages("run_1", RunMessageAPIMessage, []string{"hello"}))
+
+	require.NoError(t, tx.Commit())
+	assert.NoError(t, tx.Rollback(), "Rollback after Commit does nothing")
+
+	balances, err := client.GetBalanceHistory("acc_1", 0)
+	require.NoError(t, err)
+	assert.Len(t, balances, 1)
+	posted, ok, err := client.GetWatermark("acc_1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1704067200), posted)
+
+	changes, err := client.WithTenant("personal").MarkMissingAccounts("run_2", nil, 1)
+	require.NoError(t, err)
+	assert.Len(t, changes, 1, "The account is tagged with the tenant of the client that began the unit of work")
+}
+
+// TestTx_Rollback tests that a rolled back unit of work leaves nothing behind
+func TestTx_Rollback(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	tx, err := client.Begin()
+	require.NoError(t, err)
+	require.NoError(t, tx.PutBankAccount(testAccount("acc_1", "Checking")))
+	require.NoError(t, tx.PutAccountBalance("acc_1", "run_1", "100.00"))
+	require.NoError(t, tx.PutHoldings("acc_1", "run_1", "USD", []model.Holding{{ID: "h_1", Shares: "1", MarketValue: "10.00"}}))
+	require.NoError(t, tx.Rollback())
+
+	accounts, err := client.ListBankAccounts()
+	require.NoError(t, err)
+	assert.Empty(t, accounts)
+	balances, err := client.GetBalanceHistory("acc_1", 0)
+	require.NoError(t, err)
+	assert.Empty(t, balances)
+	holdings, err := client.GetHoldings("acc_1", "run_1")
+	require.NoError(t, err)
+	assert.Empty(t, holdings)
+
+	assert.Error(t, tx.Commit(), "A rolled back unit of work cannot be committed")
+}
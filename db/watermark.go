@@ -0,0 +1,125 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/jmoiron/sqlx"
+)
+
+const accountWatermarkTable = "ACCOUNT_WATERMARK"
+
+// GetWatermark returns the posted time of the newest transaction stored for
+// an account. ok is false when the account has no posted transactions yet.
+func (c *DatabaseClient) GetWatermark(bankAccountId string) (posted int64, ok bool, err error) {
+	query := fmt.Sprintf("SELECT LAST_POSTED FROM %s WHERE BANK_ACCOUNT_ID = ?", accountWatermarkTable)
+	err = c.db.Get(&posted, query, bankAccountId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return posted, true, nil
+}
+
+// AdvanceWatermark moves an account's watermark forward to the newest posted
+// transaction in transactions. It never moves backwards, and pending
+// transactions are ignored.
+func (c *DatabaseClient) AdvanceWatermark(bankAccountId string, transactions []model.Transaction) error {
+	return advanceWatermark(c.db, bankAccountId, transactions)
+}
+
+func advanceWatermark(q sqlx.Execer, bankAccountId string, transactions []model.Transaction) error {
+	var newest int64
+	for _, transaction := range transactions {
+		if !transaction.Pending {
+			newest = max(newest, transaction.Posted)
+		}
+	}
+	if newest <= 0 {
+		return nil
+	}
+	query := fmt.Sprintf(`INSERT INTO %s (BANK_ACCOUNT_ID, LAST_POSTED) VALUES (?, ?)
+		ON CONFLICT(BANK_ACCOUNT_ID) DO UPDATE SET
+			LAST_POSTED = MAX(LAST_POSTED, excluded.LAST_POSTED),
+			UPDATED_AT = CURRENT_TIMESTAMP`, accountWatermarkTable)
+	if _, err := q.Exec(query, bankAccountId, newest); err != nil {
+		return fmt.Errorf("failed to advance watermark for %s: %w", bankAccountId, err)
+	}
+	return nil
+}
+
+// MarkHistorySynced records that runId fetched the account's transactions
+// from the bridge's default window, so its history is complete and later
+// syncs may be incremental. Only the first such run is kept.
+func (c *DatabaseClient) MarkHistorySynced(bankAccountId string, runId string) error {
+	return markHistorySynced(c.db, bankAccountId, runId)
+}
+
+func markHistorySynced(q sqlx.Execer, bankAccountId string, runId string) error {
+	query := fmt.Sprintf("UPDATE %s SET HISTORY_SYNCED_RUN_ID = ? WHERE ID = ? AND HISTORY_SYNCED_RUN_ID IS NULL", bankAccountTable)
+	if _, err := q.Exec(query, runId, bankAccountId); err != nil {
+		return fmt.Errorf("failed to mark history synced for %s: %w", bankAccountId, err)
+	}
+	return nil
+}
+
+// SyncWindowStart returns the start-date for an incremental sync: the oldest
+// watermark among the tenant's accounts that are not closed, less overlap so
+// that pending transactions that have since posted are fetched again. Missing
+// accounts count so that an institution coming back after an outage gets the
+// transactions it missed, while accounts with no posted transactions, such as
+// brokerage accounts, do not hold the window back. It returns nil, meaning
+// the bridge's default window, when an account's history has not been synced
+// yet (see MarkHistorySynced) or no account has a watermark.
+func (c *DatabaseClient) SyncWindowStart(overlap time.Duration) (*int64, error) {
+	var window struct {
+		Oldest   sql.NullInt64 `db:"OLDEST"`
+		Unsynced int           `db:"UNSYNCED"`
+	}
+	query := fmt.Sprintf(`SELECT MIN(w.LAST_POSTED) AS OLDEST, COUNT(*) - COUNT(a.HISTORY_SYNCED_RUN_ID) AS UNSYNCED
+		FROM %s a
+		LEFT JOIN %s w ON w.BANK_ACCOUNT_ID = a.ID
+		WHERE a.STATUS != ? AND a.TENANT = ?`, bankAccountTable, accountWatermarkTable)
+	if err := c.db.Get(&window, query, AccountStatusClosed, c.tenant); err != nil {
+		return nil, err
+	}
+	if !window.Oldest.Valid || window.Unsynced > 0 {
+		return nil, nil
+	}
+	start := window.Oldest.Int64 - int64(overlap/time.Second)
+	return &start, nil
+}
+
+// AccountWindowStarts returns the start-date for an incremental fetch of each
+// of the tenant's accounts whose history has been synced: the account's own
+// watermark less overlap, or fallback when it has no posted transactions.
+// Accounts missing from the result have not had their history synced and
+// need the bridge's default window.
+func (c *DatabaseClient) AccountWindowStarts(overlap time.Duration, fallback *int64) (map[string]*int64, error) {
+	var accounts []struct {
+		ID         string        `db:"ID"`
+		LastPosted sql.NullInt64 `db:"LAST_POSTED"`
+	}
+	query := fmt.Sprintf(`SELECT a.ID, w.LAST_POSTED
+		FROM %s a
+		LEFT JOIN %s w ON w.BANK_ACCOUNT_ID = a.ID
+		WHERE a.TENANT = ? AND a.HISTORY_SYNCED_RUN_ID IS NOT NULL`, bankAccountTable, accountWatermarkTable)
+	if err := c.db.Select(&accounts, query, c.tenant); err != nil {
+		return nil, err
+	}
+	starts := make(map[string]*int64, len(accounts))
+	for _, account := range accounts {
+		if !account.LastPosted.Valid {
+			starts[account.ID] = fallback
+			continue
+		}
+		start := account.LastPosted.Int64 - int64(overlap/time.Second)
+		starts[account.ID] = &start
+	}
+	return starts, nil
+}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/criswit/chi-chi-moni/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAdvanceWatermark tests that watermarks only move forward
+func TestAdvanceWatermark(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+	seedTestData(t, client)
+
+	_, ok, err := client.GetWatermark("test_account_1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, client.AdvanceWatermark("test_account_1", []model.Transaction{
+		{ID: "txn_1", Posted: 1704067200},
+		{ID: "txn_2", Posted: 1704153600},
+		{ID: "txn_pending", Posted: 0},
+	}))
+	posted, ok, err := client.GetWatermark("test_account_1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1704153600), posted)
+
+	require.NoError(t, client.AdvanceWatermark("test_account_1", []model.Transaction{{ID: "txn_old", Posted: 1600000000}}))
+	posted, _, err = client.GetWatermark("test_account_1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1704153600), posted, "Watermark must never move backwards")
+
+	require.NoError(t, client.AdvanceWatermark("test_account_2", []model.Transaction{{ID: "txn_pending", Posted: 0}}))
+	_, ok, err = client.GetWatermark("test_account_2")
+	require.NoError(t, err)
+	assert.False(t, ok, "Pending transactions should not set a watermark")
+}
+
+// TestSyncWindowStart tests picking the incremental start-date
+func TestSyncWindowStart(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	start, err := client.SyncWindowStart(time.Hour)
+	require.NoError(t, err)
+	assert.Nil(t, start, "No accounts means the default window")
+
+	seedTestData(t, client)
+	require.NoError(t, client.MarkHistorySynced("test_account_1", "run_0"))
+	require.NoError(t, client.MarkHistorySynced("test_account_2", "run_0"))
+	start, err = client.SyncWindowStart(time.Hour)
+	require.NoError(t, err)
+	assert.Nil(t, start, "No watermarks means the default window")
+
+	require.NoError(t, client.AdvanceWatermark("test_account_1", []model.Transaction{{Posted: 1704153600}}))
+	start, err = client.SyncWindowStart(time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, start)
+	assert.Equal(t, int64(1704153600-3600), *start, "Accounts without transactions do not hold the window back")
+
+	require.NoError(t, client.AdvanceWatermark("test_account_2", []model.Transaction{{Posted: 1704067200}}))
+	start, err = client.SyncWindowStart(24 * time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, start)
+	assert.Equal(t, int64(1704067200-86400), *start, "Oldest watermark less the overlap")
+
+	// A newly linked account gets the default window once, for its history
+	require.NoError(t, client.PutBankAccount(model.Account{ID: "new_account", Name: "New Card"}))
+	start, err = client.SyncWindowStart(24 * time.Hour)
+	require.NoError(t, err)
+	assert.Nil(t, start, "An account whose history was never synced means the default window")
+	require.NoError(t, client.MarkHistorySynced("new_account", "run_1"))
+	start, err = client.SyncWindowStart(24 * time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, start)
+	assert.Equal(t, int64(1704067200-86400), *start)
+
+	// Closed accounts no longer hold the window back
+	_, err = client.MarkMissingAccounts("run_1", []string{"test_account_1"}, 1)
+	require.NoError(t, err)
+	start, err = client.SyncWindowStart(0)
+	require.NoError(t, err)
+	require.NotNil(t, start)
+	assert.Equal(t, int64(1704153600), *start)
+}
+
+// TestSyncWindowStart_MissingAccountReturns tests that an account coming back
+// after an outage is fetched from its own watermark
+func TestSyncWindowStart_MissingAccountReturns(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+
+	accounts := []model.Account{{ID: "acc_1", Name: "Checking"}, {ID: "acc_2", Name: "Card"}}
+	for _, account := range accounts {
+		_, _, err := client.UpsertBankAccount(account, "run_1")
+		require.NoError(t, err)
+		require.NoError(t, client.MarkHistorySynced(account.ID, "run_1"))
+		require.NoError(t, client.AdvanceWatermark(account.ID, []model.Transaction{{Posted: 1704067200}}))
+	}
+
+	// acc_2's institution drops out for a few runs while acc_1 keeps syncing
+	for i, posted := range []int64{1704672000, 1705276800, 1705881600} {
+		runId := fmt.Sprintf("run_%d", i+2)
+		_, _, err := client.UpsertBankAccount(accounts[0], runId)
+		require.NoError(t, err)
+		require.NoError(t, client.AdvanceWatermark("acc_1", []model.Transaction{{Posted: posted}}))
+		_, err = client.MarkMissingAccounts(runId, []string{"acc_1"}, 10)
+		require.NoError(t, err)
+	}
+
+	start, err := client.SyncWindowStart(24 * time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, start)
+	assert.Equal(t, int64(1704067200-86400), *start, "The missing account's watermark should hold the window back")
+}
+
+// TestAccountWindowStarts tests that each account is fetched from its own
+// watermark
+func TestAccountWindowStarts(t *testing.T) {
+	client := setupTestDB(t)
+	defer client.Close()
+	seedTestData(t, client)
+	require.NoError(t, client.PutBankAccount(model.Account{ID: "new_account", Name: "New Card"}))
+
+	require.NoError(t, client.MarkHistorySynced("test_account_1", "run_0"))
+	require.NoError(t, client.MarkHistorySynced("test_account_2", "run_0"))
+	require.NoError(t, client.AdvanceWatermark("test_account_1", []model.Transaction{{Posted: 1704153600}}))
+
+	fallback := int64(1704000000)
+	starts, err := client.AccountWindowStarts(time.Hour, &fallback)
+	require.NoError(t, err)
+	require.Len(t, starts, 2, "An account whose history was never synced gets the default window")
+	require.NotNil(t, starts["test_account_1"])
+	assert.Equal(t, int64(1704153600-3600), *starts["test_account_1"])
+	assert.Equal(t, &fallback, starts["test_account_2"], "An account without transactions uses the fallback")
+	assert.NotContains(t, starts, "new_account")
+
+	starts, err = client.WithTenant("business").AccountWindowStarts(time.Hour, nil)
+	require.NoError(t, err)
+	assert.Empty(t, starts, "Other tenants' accounts are left out")
+}
@@ -112,58 +112,6 @@ func TestGetAccessToken(t *testing.T) {
 	})
 }
 
-// TestGetDbFilePath tests the getDbFilePath function
-func TestGetDbFilePath(t *testing.T) {
-	tests := []struct {
-		name      string
-		setupEnv  func()
-		cleanup   func()
-		wantPath  string
-		wantErr   bool
-	}{
-		{
-			name: "valid_home_directory",
-			setupEnv: func() {
-				// Use actual home directory
-			},
-			cleanup: func() {},
-			wantErr: false,
-		},
-		{
-			name: "custom_home_directory",
-			setupEnv: func() {
-				os.Setenv("HOME", "/custom/home")
-			},
-			cleanup: func() {
-				os.Unsetenv("HOME")
-			},
-			wantPath: "/custom/home/data/monk.db",
-			wantErr:  false,
-		},
-	}
-	
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			tt.setupEnv()
-			defer tt.cleanup()
-			
-			path, err := getDbFilePath()
-			
-			if tt.wantErr {
-				assert.Error(t, err)
-			} else {
-				assert.NoError(t, err)
-				assert.NotEmpty(t, path)
-				assert.Contains(t, path, "data/monk.db")
-				
-				if tt.wantPath != "" {
-					assert.Equal(t, tt.wantPath, path)
-				}
-			}
-		})
-	}
-}
-
 // TestMainFlow tests the main application flow with mocks
 func TestMainFlow(t *testing.T) {
 	// This test demonstrates how the main function flow would be tested
@@ -328,13 +276,6 @@ func TestMainFlow(t *testing.T) {
 	}
 }
 
-// TestConstants tests the package constants
-func TestConstants(t *testing.T) {
-	assert.Equal(t, "monkstorage", ssoProfile)
-	assert.Equal(t, "monk-monies", accessTokenSecretName)
-	assert.Equal(t, "data/monk.db", dbFilePath)
-}
-
 // TestDatabaseInitialization tests database initialization
 func TestDatabaseInitialization(t *testing.T) {
 	t.Run("valid_database_path", func(t *testing.T) {
@@ -429,12 +370,6 @@ func TestIntegrationPoints(t *testing.T) {
 }
 
 // Benchmark tests
-func BenchmarkGetDbFilePath(b *testing.B) {
-	for i := 0; i < b.N; i++ {
-		_, _ = getDbFilePath()
-	}
-}
-
 func BenchmarkMainFlow(b *testing.B) {
 	// Setup mocks
 	finClient := &mockSimpleFinClient{
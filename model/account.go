@@ -20,6 +20,7 @@ type Transaction struct {
 	Payee        string `json:"payee"`
 	Memo         string `json:"memo"`
 	TransactedAt int64  `json:"transacted_at"`
+	Pending      bool   `json:"pending"`
 }
 
 // Account represents a financial account
@@ -32,7 +33,20 @@ type Account struct {
 	AvailableBalance string        `json:"available-balance"`
 	BalanceDate      int64         `json:"balance-date"`
 	Transactions     []Transaction `json:"transactions"`
-	Holdings         []interface{} `json:"holdings"` // Empty array in the data, using interface{} for flexibility
+	Holdings         []Holding     `json:"holdings"`
+}
+
+// Holding represents an investment position in a brokerage or retirement account
+type Holding struct {
+	ID            string `json:"id"`
+	Created       int64  `json:"created"`
+	Currency      string `json:"currency"` // Empty means the account's currency
+	CostBasis     string `json:"cost_basis"`
+	Description   string `json:"description"`
+	MarketValue   string `json:"market_value"`
+	PurchasePrice string `json:"purchase_price"`
+	Shares        string `json:"shares"`
+	Symbol        string `json:"symbol"`
 }
 
 // GetAccountsResponse represents the complete API response
@@ -51,6 +65,19 @@ func (t *Transaction) TransactedTime() time.Time {
 	return time.Unix(t.TransactedAt, 0)
 }
 
+// Date returns when the transaction happened: its posted time, or for a
+// pending transaction that has none yet, its transacted time
+func (t *Transaction) Date() int64 {
+	if t.Posted > 0 {
+		return t.Posted
+	}
+	return t.TransactedAt
+}
+
 func (a *Account) BalanceTime() time.Time {
 	return time.Unix(a.BalanceDate, 0)
 }
+
+func (h *Holding) CreatedTime() time.Time {
+	return time.Unix(h.Created, 0)
+}
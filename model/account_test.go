@@ -40,7 +40,7 @@ var (
 		Transactions: []Transaction{
 			validTransaction,
 		},
-		Holdings: []interface{}{},
+		Holdings: []Holding{},
 	}
 )
 
@@ -174,6 +174,22 @@ func TestTransactionJSONMarshaling(t *testing.T) {
 				TransactedAt: 1704067200,
 			},
 		},
+		{
+			name: "pending_transaction",
+			transaction: Transaction{
+				ID:           "txn_pending",
+				Amount:       "-12.00",
+				Payee:        "Grocer",
+				TransactedAt: 1704067200,
+				Pending:      true,
+			},
+			validateJSON: func(t *testing.T, data []byte) {
+				var result map[string]interface{}
+				err := json.Unmarshal(data, &result)
+				require.NoError(t, err)
+				assert.Equal(t, true, result["pending"])
+			},
+		},
 		{
 			name: "transaction_with_unicode",
 			transaction: Transaction{
@@ -265,7 +281,7 @@ func TestAccountJSONMarshaling(t *testing.T) {
 				AvailableBalance: "5000.00",
 				BalanceDate:      1704067200,
 				Transactions:     []Transaction{},
-				Holdings:         []interface{}{},
+				Holdings:         []Holding{},
 			},
 		},
 		{
@@ -290,7 +306,7 @@ func TestAccountJSONMarshaling(t *testing.T) {
 						TransactedAt: 1704153600,
 					},
 				},
-				Holdings: []interface{}{},
+				Holdings: []Holding{},
 			},
 		},
 		{
@@ -304,7 +320,7 @@ func TestAccountJSONMarshaling(t *testing.T) {
 				AvailableBalance: "0.00",
 				BalanceDate:      1704067200,
 				Transactions:     []Transaction{},
-				Holdings:         []interface{}{},
+				Holdings:         []Holding{},
 			},
 		},
 		{
@@ -318,7 +334,7 @@ func TestAccountJSONMarshaling(t *testing.T) {
 				AvailableBalance: "-50.00",
 				BalanceDate:      1704067200,
 				Transactions:     []Transaction{},
-				Holdings:         []interface{}{},
+				Holdings:         []Holding{},
 			},
 		},
 	}
@@ -417,7 +433,7 @@ func TestGetAccountsResponseJSONMarshaling(t *testing.T) {
 						AvailableBalance: "5000.00",
 						BalanceDate:      1704067200,
 						Transactions:     []Transaction{},
-						Holdings:         []interface{}{},
+						Holdings:         []Holding{},
 					},
 				},
 				XAPIMessage: []string{},
@@ -497,6 +513,55 @@ func TestTransactionHelperMethods(t *testing.T) {
 	}
 }
 
+// TestTransactionDate tests falling back to the transacted time for pending transactions
+func TestTransactionDate(t *testing.T) {
+	posted := Transaction{Posted: 1704153600, TransactedAt: 1704067200}
+	assert.Equal(t, int64(1704153600), posted.Date())
+
+	pending := Transaction{TransactedAt: 1704067200, Pending: true}
+	assert.Equal(t, int64(1704067200), pending.Date())
+}
+
+// TestHoldingJSONUnmarshaling tests decoding holdings as the bridge sends them
+func TestHoldingJSONUnmarshaling(t *testing.T) {
+	data := `{
+		"id": "acc_brokerage",
+		"currency": "USD",
+		"balance": "4300.50",
+		"holdings": [{
+			"id": "HOL-1",
+			"created": 1704067200,
+			"currency": "",
+			"cost_basis": "1500.00",
+			"description": "Vanguard Total Stock Market ETF",
+			"market_value": "2500.50",
+			"purchase_price": "150.00",
+			"shares": "10.5",
+			"symbol": "VTI"
+		}]
+	}`
+
+	var account Account
+	require.NoError(t, json.Unmarshal([]byte(data), &account))
+	require.Len(t, account.Holdings, 1)
+
+	holding := account.Holdings[0]
+	assert.Equal(t, "HOL-1", holding.ID)
+	assert.Equal(t, "VTI", holding.Symbol)
+	assert.Equal(t, "Vanguard Total Stock Market ETF", holding.Description)
+	assert.Equal(t, "10.5", holding.Shares)
+	assert.Equal(t, "1500.00", holding.CostBasis)
+	assert.Equal(t, "2500.50", holding.MarketValue)
+	assert.Equal(t, "150.00", holding.PurchasePrice)
+	assert.Equal(t, time.Unix(1704067200, 0), holding.CreatedTime())
+
+	roundTrip, err := json.Marshal(holding)
+	require.NoError(t, err)
+	var decoded Holding
+	require.NoError(t, json.Unmarshal(roundTrip, &decoded))
+	assert.Equal(t, holding, decoded)
+}
+
 // TestAccountHelperMethods tests the helper methods for Account
 func TestAccountHelperMethods(t *testing.T) {
 	tests := []struct {
@@ -621,7 +686,7 @@ func TestComplexNestedStructures(t *testing.T) {
 						TransactedAt: 1704153600,
 					},
 				},
-				Holdings: []interface{}{},
+				Holdings: []Holding{},
 			},
 			{
 				Org: Organization{
@@ -638,7 +703,7 @@ func TestComplexNestedStructures(t *testing.T) {
 				AvailableBalance: "25000.00",
 				BalanceDate:      1704067200,
 				Transactions:     []Transaction{},
-				Holdings:         []interface{}{},
+				Holdings:         []Holding{},
 			},
 		},
 		XAPIMessage: []string{
@@ -0,0 +1,409 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ErrCurrencyMismatch is returned when combining or comparing amounts in
+// different currencies
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// Currency is an ISO-4217 code such as "USD", or for SimpleFIN custom
+// currencies (points, miles, ...) the URL describing the currency
+type Currency string
+
+// IsCustom reports whether the currency is a SimpleFIN custom-currency URL
+func (c Currency) IsCustom() bool {
+	return strings.HasPrefix(string(c), "https://") || strings.HasPrefix(string(c), "http://")
+}
+
+// MinorUnits returns the number of decimal places the currency is normally
+// shown with
+func (c Currency) MinorUnits() int32 {
+	switch strings.ToUpper(string(c)) {
+	case "JPY", "KRW", "VND", "CLP", "ISK":
+		return 0
+	case "BHD", "KWD", "OMR", "JOD", "TND":
+		return 3
+	}
+	if c.IsCustom() {
+		return 0
+	}
+	return 2
+}
+
+// Symbol returns the currency's display symbol, falling back to the code
+func (c Currency) Symbol() string {
+	if symbol, ok := currencySymbols[strings.ToUpper(string(c))]; ok {
+		return symbol
+	}
+	return string(c)
+}
+
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+	"CAD": "CA$",
+	"AUD": "A$",
+	"CHF": "CHF",
+	"INR": "₹",
+	"MXN": "MX$",
+}
+
+// Money is an exact decimal amount in a currency. The value is held as an
+// unscaled integer and a number of decimal places, so "10.10" and "10.1"
+// compare equal but keep the precision they were parsed with. The zero value
+// is zero with no currency.
+type Money struct {
+	units    *big.Int
+	scale    int32
+	Currency Currency
+}
+
+// ParseMoney parses a decimal string such as "-1234.56" as SimpleFIN sends
+// balances and amounts
+func ParseMoney(amount string, currency Currency) (Money, error) {
+	units, scale, err := parseDecimal(amount)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{units: units, scale: scale, Currency: currency}, nil
+}
+
+// MustParseMoney is like ParseMoney but panics on invalid input. It is meant
+// for constants and tests.
+func MustParseMoney(amount string, currency Currency) Money {
+	m, err := ParseMoney(amount, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// NewMoneyFromMinor builds an amount from an integer count of minor units,
+// e.g. 1234 cents becomes 12.34 USD
+func NewMoneyFromMinor(minor int64, currency Currency) Money {
+	return Money{units: big.NewInt(minor), scale: currency.MinorUnits(), Currency: currency}
+}
+
+func parseDecimal(s string) (*big.Int, int32, error) {
+	text := strings.TrimSpace(s)
+	digits := strings.TrimLeft(text, "+-")
+	if len(text)-len(digits) > 1 {
+		return nil, 0, fmt.Errorf("invalid decimal amount %q", s)
+	}
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" && fraction == "" {
+		return nil, 0, fmt.Errorf("invalid decimal amount %q", s)
+	}
+	for _, r := range whole + fraction {
+		if r < '0' || r > '9' {
+			return nil, 0, fmt.Errorf("invalid decimal amount %q", s)
+		}
+	}
+	units, ok := new(big.Int).SetString(whole+fraction, 10)
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid decimal amount %q", s)
+	}
+	if strings.HasPrefix(text, "-") {
+		units.Neg(units)
+	}
+	return units, int32(len(fraction)), nil
+}
+
+func (m Money) unscaled() *big.Int {
+	if m.units == nil {
+		return new(big.Int)
+	}
+	return m.units
+}
+
+// rescale returns the unscaled value expressed with more decimal places
+func (m Money) rescale(scale int32) *big.Int {
+	units := new(big.Int).Set(m.unscaled())
+	if scale > m.scale {
+		units.Mul(units, pow10(scale-m.scale))
+	}
+	return units
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func (m Money) checkCurrency(other Money) error {
+	if m.Currency != other.Currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	return nil
+}
+
+// Add returns m + other. Both amounts must be in the same currency.
+func (m Money) Add(other Money) (Money, error) {
+	if err := m.checkCurrency(other); err != nil {
+		return Money{}, err
+	}
+	scale := max(m.scale, other.scale)
+	units := m.rescale(scale)
+	units.Add(units, other.rescale(scale))
+	return Money{units: units, scale: scale, Currency: m.Currency}, nil
+}
+
+// Sub returns m - other. Both amounts must be in the same currency.
+func (m Money) Sub(other Money) (Money, error) {
+	return m.Add(other.Neg())
+}
+
+// Cmp compares m and other, returning -1, 0 or +1. Both amounts must be in
+// the same currency.
+func (m Money) Cmp(other Money) (int, error) {
+	if err := m.checkCurrency(other); err != nil {
+		return 0, err
+	}
+	scale := max(m.scale, other.scale)
+	return m.rescale(scale).Cmp(other.rescale(scale)), nil
+}
+
+// Equal reports whether m and other are the same amount in the same currency
+func (m Money) Equal(other Money) bool {
+	cmp, err := m.Cmp(other)
+	return err == nil && cmp == 0
+}
+
+// Neg returns -m
+func (m Money) Neg() Money {
+	return Money{units: new(big.Int).Neg(m.unscaled()), scale: m.scale, Currency: m.Currency}
+}
+
+// Abs returns |m|
+func (m Money) Abs() Money {
+	return Money{units: new(big.Int).Abs(m.unscaled()), scale: m.scale, Currency: m.Currency}
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of m
+func (m Money) Sign() int {
+	return m.unscaled().Sign()
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Sign() == 0
+}
+
+// Round returns m rounded half away from zero to the given number of decimal places
+func (m Money) Round(places int32) Money {
+	if places >= m.scale {
+		return Money{units: m.rescale(places), scale: places, Currency: m.Currency}
+	}
+	divisor := pow10(m.scale - places)
+	quotient, remainder := new(big.Int).QuoRem(m.unscaled(), divisor, new(big.Int))
+	remainder.Abs(remainder).Mul(remainder, big.NewInt(2))
+	if remainder.Cmp(divisor) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(m.Sign())))
+	}
+	return Money{units: quotient, scale: places, Currency: m.Currency}
+}
+
+// String returns the plain decimal amount, e.g. "-1234.56", without the currency
+func (m Money) String() string {
+	digits := new(big.Int).Abs(m.unscaled()).String()
+	if m.scale > 0 {
+		if pad := int(m.scale) + 1 - len(digits); pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		point := len(digits) - int(m.scale)
+		digits = digits[:point] + "." + digits[point:]
+	}
+	if m.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Locale describes how amounts are written in a region
+type Locale struct {
+	GroupSeparator   string
+	DecimalSeparator string
+	SymbolAfter      bool // Write "12,34 €" rather than "€12,34", separated by a no-break space
+}
+
+// Locales known to FormatLocale, keyed by BCP 47 tag
+var Locales = map[string]Locale{
+	"en-US": {GroupSeparator: ",", DecimalSeparator: "."},
+	"en-GB": {GroupSeparator: ",", DecimalSeparator: "."},
+	"en-CA": {GroupSeparator: ",", DecimalSeparator: "."},
+	"ja-JP": {GroupSeparator: ",", DecimalSeparator: "."},
+	"de-DE": {GroupSeparator: ".", DecimalSeparator: ",", SymbolAfter: true},
+	"es-ES": {GroupSeparator: ".", DecimalSeparator: ",", SymbolAfter: true},
+	"fr-FR": {GroupSeparator: "\u202f", DecimalSeparator: ",", SymbolAfter: true},
+	"de-CH": {GroupSeparator: "’", DecimalSeparator: "."},
+}
+
+// DefaultLocale is used for unknown locale tags
+const DefaultLocale = "en-US"
+
+// FormatLocale formats the amount for display in the given locale, rounded
+// to the currency's minor units, e.g. "-$1,234.56" or "-1.234,56 €". Custom
+// currencies are written as the number followed by the currency URL.
+func (m Money) FormatLocale(tag string) string {
+	locale, ok := Locales[strings.ReplaceAll(tag, "_", "-")]
+	if !ok {
+		locale = Locales[DefaultLocale]
+	}
+
+	rounded := m
+	if !m.Currency.IsCustom() {
+		rounded = m.Round(m.Currency.MinorUnits())
+	}
+	whole, fraction, _ := strings.Cut(rounded.Abs().String(), ".")
+	number := groupDigits(whole, locale.GroupSeparator)
+	if fraction != "" {
+		number += locale.DecimalSeparator + fraction
+	}
+
+	sign := ""
+	if rounded.Sign() < 0 {
+		sign = "-"
+	}
+	switch {
+	case m.Currency == "":
+		return sign + number
+	case m.Currency.IsCustom():
+		return sign + number + " " + string(m.Currency)
+	case locale.SymbolAfter:
+		return sign + number + "\u00a0" + m.Currency.Symbol()
+	default:
+		return sign + m.Currency.Symbol() + number
+	}
+}
+
+func groupDigits(digits string, separator string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+	var b strings.Builder
+	head := len(digits) % 3
+	if head > 0 {
+		b.WriteString(digits[:head])
+	}
+	for i := head; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(separator)
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
+
+type moneyJSON struct {
+	Amount   string   `json:"amount"`
+	Currency Currency `json:"currency,omitempty"`
+}
+
+// MarshalJSON writes {"amount":"-1234.56","currency":"USD"}, keeping the
+// amount as a string so no precision is lost
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.String(), Currency: m.Currency})
+}
+
+// UnmarshalJSON reads the object form written by MarshalJSON, or a bare
+// amount string or number, which leaves the currency unchanged
+func (m *Money) UnmarshalJSON(data []byte) error {
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "{") {
+		var v moneyJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		parsed, err := ParseMoney(v.Amount, v.Currency)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	}
+	amount, err := unquoteAmount(trimmed)
+	if err != nil {
+		return err
+	}
+	parsed, err := ParseMoney(amount, m.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func unquoteAmount(data string) (string, error) {
+	if strings.HasPrefix(data, `"`) {
+		var s string
+		if err := json.Unmarshal([]byte(data), &s); err != nil {
+			return "", err
+		}
+		return s, nil
+	}
+	var n json.Number
+	if err := json.Unmarshal([]byte(data), &n); err != nil {
+		return "", fmt.Errorf("invalid money value %s", data)
+	}
+	return n.String(), nil
+}
+
+// Value stores the amount as its exact decimal string. The currency is not
+// stored; it belongs in its own column.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Scan reads a decimal amount stored as TEXT, INTEGER or REAL, keeping the
+// receiver's currency
+func (m *Money) Scan(src interface{}) error {
+	var amount string
+	switch v := src.(type) {
+	case string:
+		amount = v
+	case []byte:
+		amount = string(v)
+	case int64:
+		amount = fmt.Sprint(v)
+	case float64:
+		amount = big.NewFloat(v).Text('f', -1)
+	case nil:
+		return errors.New("cannot scan NULL into Money")
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	parsed, err := ParseMoney(amount, m.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// BalanceMoney returns the account balance in the account's currency
+func (a *Account) BalanceMoney() (Money, error) {
+	return ParseMoney(a.Balance, Currency(a.Currency))
+}
+
+// AvailableBalanceMoney returns the available balance in the account's
+// currency. Accounts without an available balance return zero.
+func (a *Account) AvailableBalanceMoney() (Money, error) {
+	if a.AvailableBalance == "" {
+		return Money{Currency: Currency(a.Currency)}, nil
+	}
+	return ParseMoney(a.AvailableBalance, Currency(a.Currency))
+}
+
+// AmountMoney returns the transaction amount in the given currency, which
+// is the currency of the account the transaction belongs to
+func (t *Transaction) AmountMoney(currency Currency) (Money, error) {
+	return ParseMoney(t.Amount, currency)
+}
@@ -0,0 +1,222 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "1234.56", want: "1234.56"},
+		{input: "-150.50", want: "-150.50"},
+		{input: "+7", want: "7"},
+		{input: "0.001", want: "0.001"},
+		{input: ".5", want: "0.5"},
+		{input: " 42.10 ", want: "42.10"},
+		{input: "123456789012345678901234567890.12", want: "123456789012345678901234567890.12"},
+		{input: "", wantErr: true},
+		{input: "-", wantErr: true},
+		{input: "1,234.56", wantErr: true},
+		{input: "1e5", wantErr: true},
+		{input: "--1", wantErr: true},
+		{input: "1.2.3", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			m, err := ParseMoney(tt.input, "USD")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, m.String())
+			assert.Equal(t, Currency("USD"), m.Currency)
+		})
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	a := MustParseMoney("0.1", "USD")
+	b := MustParseMoney("0.20", "USD")
+
+	sum, err := a.Add(b)
+	require.NoError(t, err)
+	assert.Equal(t, "0.30", sum.String(), "Decimal addition must be exact")
+
+	diff, err := a.Sub(b)
+	require.NoError(t, err)
+	assert.Equal(t, "-0.10", diff.String())
+	assert.Equal(t, -1, diff.Sign())
+	assert.Equal(t, "0.10", diff.Abs().String())
+
+	cmp, err := a.Cmp(b)
+	require.NoError(t, err)
+	assert.Equal(t, -1, cmp)
+
+	assert.True(t, MustParseMoney("10.1", "USD").Equal(MustParseMoney("10.10", "USD")))
+	assert.False(t, MustParseMoney("10.1", "USD").Equal(MustParseMoney("10.1", "EUR")))
+
+	var zero Money
+	assert.True(t, zero.IsZero())
+	assert.Equal(t, "0", zero.String())
+	total, err := zero.Add(Money{})
+	require.NoError(t, err)
+	assert.True(t, total.IsZero())
+
+	// Operands are never modified
+	assert.Equal(t, "0.1", a.String())
+	assert.Equal(t, "0.20", b.String())
+}
+
+func TestMoneyCurrencyMismatch(t *testing.T) {
+	usd := MustParseMoney("1.00", "USD")
+	eur := MustParseMoney("1.00", "EUR")
+
+	_, err := usd.Add(eur)
+	assert.True(t, errors.Is(err, ErrCurrencyMismatch))
+	_, err = usd.Sub(eur)
+	assert.True(t, errors.Is(err, ErrCurrencyMismatch))
+	_, err = usd.Cmp(eur)
+	assert.True(t, errors.Is(err, ErrCurrencyMismatch))
+}
+
+func TestMoneyRound(t *testing.T) {
+	tests := []struct {
+		input  string
+		places int32
+		want   string
+	}{
+		{input: "1.005", places: 2, want: "1.01"},
+		{input: "1.004", places: 2, want: "1.00"},
+		{input: "-1.005", places: 2, want: "-1.01"},
+		{input: "2.5", places: 0, want: "3"},
+		{input: "7", places: 2, want: "7.00"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, MustParseMoney(tt.input, "USD").Round(tt.places).String(), tt.input)
+	}
+}
+
+func TestMoneyFormatLocale(t *testing.T) {
+	customCurrency := Currency("https://example.com/points")
+
+	tests := []struct {
+		name   string
+		amount Money
+		locale string
+		want   string
+	}{
+		{name: "en-US", amount: MustParseMoney("-1234567.891", "USD"), locale: "en-US", want: "-$1,234,567.89"},
+		{name: "de-DE", amount: MustParseMoney("1234.5", "EUR"), locale: "de-DE", want: "1.234,50\u00a0€"},
+		{name: "fr-FR", amount: MustParseMoney("1234.5", "EUR"), locale: "fr_FR", want: "1\u202f234,50\u00a0€"},
+		{name: "yen has no minor units", amount: MustParseMoney("1234.56", "JPY"), locale: "ja-JP", want: "¥1,235"},
+		{name: "unknown currency uses code", amount: MustParseMoney("5", "SEK"), locale: "en-US", want: "SEK5.00"},
+		{name: "unknown locale", amount: MustParseMoney("999.999", "USD"), locale: "xx-XX", want: "$1,000.00"},
+		{name: "custom currency", amount: MustParseMoney("1500", customCurrency), locale: "en-US", want: "1,500 https://example.com/points"},
+		{name: "no currency", amount: MustParseMoney("12", ""), locale: "en-US", want: "12.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.amount.FormatLocale(tt.locale))
+		})
+	}
+}
+
+func TestCurrency(t *testing.T) {
+	assert.False(t, Currency("USD").IsCustom())
+	assert.True(t, Currency("https://example.com/flight-miles").IsCustom())
+	assert.Equal(t, int32(2), Currency("USD").MinorUnits())
+	assert.Equal(t, int32(0), Currency("JPY").MinorUnits())
+	assert.Equal(t, "$", Currency("usd").Symbol())
+}
+
+func TestMoneyJSON(t *testing.T) {
+	original := MustParseMoney("-0.10", "USD")
+
+	data, err := json.Marshal(original)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"-0.10","currency":"USD"}`, string(data))
+
+	var decoded Money
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "-0.10", decoded.String())
+	assert.Equal(t, Currency("USD"), decoded.Currency)
+
+	// Bare amounts keep the currency already set on the receiver
+	bare := Money{Currency: "EUR"}
+	require.NoError(t, json.Unmarshal([]byte(`"12.30"`), &bare))
+	assert.Equal(t, "12.30", bare.String())
+	assert.Equal(t, Currency("EUR"), bare.Currency)
+
+	require.NoError(t, json.Unmarshal([]byte(`12.5`), &bare))
+	assert.Equal(t, "12.5", bare.String())
+
+	assert.Error(t, json.Unmarshal([]byte(`"abc"`), &bare))
+	assert.Error(t, json.Unmarshal([]byte(`{"amount":"x"}`), &bare))
+}
+
+func TestMoneySQL(t *testing.T) {
+	value, err := MustParseMoney("1234.50", "USD").Value()
+	require.NoError(t, err)
+	assert.Equal(t, "1234.50", value)
+
+	tests := []struct {
+		name    string
+		src     interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "text", src: "1234.50", want: "1234.50"},
+		{name: "bytes", src: []byte("-3.25"), want: "-3.25"},
+		{name: "integer", src: int64(42), want: "42"},
+		{name: "real", src: 0.1, want: "0.1"},
+		{name: "null", src: nil, wantErr: true},
+		{name: "invalid", src: "abc", wantErr: true},
+		{name: "unsupported", src: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Money{Currency: "USD"}
+			err := m.Scan(tt.src)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, m.String())
+			assert.Equal(t, Currency("USD"), m.Currency)
+		})
+	}
+}
+
+func TestAccountMoneyHelpers(t *testing.T) {
+	balance, err := validAccount.BalanceMoney()
+	require.NoError(t, err)
+	assert.Equal(t, "1234.56", balance.String())
+	assert.Equal(t, Currency("USD"), balance.Currency)
+
+	available, err := validAccount.AvailableBalanceMoney()
+	require.NoError(t, err)
+	assert.Equal(t, "1200.00", available.String())
+
+	noAvailable := Account{Currency: "USD"}
+	available, err = noAvailable.AvailableBalanceMoney()
+	require.NoError(t, err)
+	assert.True(t, available.IsZero())
+
+	amount, err := validTransaction.AmountMoney(Currency(validAccount.Currency))
+	require.NoError(t, err)
+	assert.Equal(t, "-150.50", amount.String())
+}